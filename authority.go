@@ -0,0 +1,97 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// authorityPins stores the authority keys, or their fingerprints, trusted by the client.
+type authorityPins struct {
+	keys         []ssh.PublicKey
+	fingerprints []string
+}
+
+// parseAuthorityPins parses authorities either formatted as authorized keys or SHA256 fingerprints.
+func parseAuthorityPins(authorities []string) (authorityPins, error) {
+	var pins authorityPins
+
+	for _, authority := range authorities {
+		authority = strings.TrimSpace(authority)
+
+		if strings.HasPrefix(authority, "SHA256:") {
+			pins.fingerprints = append(pins.fingerprints, authority)
+			continue
+		}
+
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authority))
+		if err != nil {
+			return authorityPins{}, fmt.Errorf("unable to parse pinned authority %q: %v", authority, err)
+		}
+		pins.keys = append(pins.keys, key)
+	}
+
+	return pins, nil
+}
+
+func (pins authorityPins) empty() bool {
+	return len(pins.keys) == 0 && len(pins.fingerprints) == 0
+}
+
+// match returns whenever the provided key is one of the pinned keys.
+func (pins authorityPins) match(key ssh.PublicKey) bool {
+	if key == nil {
+		return false
+	}
+
+	for _, pinned := range pins.keys {
+		if bytes.Equal(pinned.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+
+	fingerprint := ssh.FingerprintSHA256(key)
+	for _, pinned := range pins.fingerprints {
+		if pinned == fingerprint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkAuthority returns ErrAuthorityMismatch if authorities are pinned and the provided key is not one of them.
+func (pins authorityPins) checkAuthority(key ssh.PublicKey) error {
+	if pins.empty() || pins.match(key) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is not pinned", ErrAuthorityMismatch, ssh.FingerprintSHA256(key))
+}
+
+// AuthorityKnownHosts returns known_hosts lines trusting the authority for the provided host patterns.
+// If authority keys are pinned, a line is returned for each of them to ease rotations,
+// otherwise the authority is fetched from the CASSH server, and checked against pinned fingerprints if any.
+func (c *Client) AuthorityKnownHosts(ctx context.Context, hostPatterns ...string) ([]byte, error) {
+	if len(hostPatterns) == 0 {
+		hostPatterns = []string{"*"}
+	}
+
+	authorities := c.authorityPins.keys
+	if len(authorities) == 0 {
+		authority, err := c.AuthorityPublicKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		authorities = []ssh.PublicKey{authority}
+	}
+
+	var knownHosts bytes.Buffer
+	for _, authority := range authorities {
+		fmt.Fprintf(&knownHosts, "@cert-authority %s %s", strings.Join(hostPatterns, ","), ssh.MarshalAuthorizedKey(authority))
+	}
+
+	return knownHosts.Bytes(), nil
+}
@@ -0,0 +1,189 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_parseAuthorityPins(t *testing.T) {
+	key := newVerificationTestSigner(t).PublicKey()
+
+	pins, err := parseAuthorityPins([]string{
+		string(ssh.MarshalAuthorizedKey(key)),
+		" SHA256:abc ",
+	})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(pins.keys, 1))
+	assert.Check(t, cmp.DeepEqual(pins.fingerprints, []string{"SHA256:abc"}))
+	assert.Check(t, !pins.empty())
+
+	pins, err = parseAuthorityPins(nil)
+	assert.NilError(t, err)
+	assert.Check(t, pins.empty())
+
+	_, err = parseAuthorityPins([]string{"ssh-ed25519 nope"})
+	assert.Check(t, cmp.ErrorContains(err, `unable to parse pinned authority "ssh-ed25519 nope"`))
+}
+
+func Test_authorityPins_checkAuthority(t *testing.T) {
+	pinnedKey := newVerificationTestSigner(t).PublicKey()
+	pinnedFingerprint := newVerificationTestSigner(t).PublicKey()
+	other := newVerificationTestSigner(t).PublicKey()
+
+	pins := authorityPins{
+		keys:         []ssh.PublicKey{pinnedKey},
+		fingerprints: []string{ssh.FingerprintSHA256(pinnedFingerprint)},
+	}
+
+	assert.Check(t, pins.checkAuthority(pinnedKey))
+	assert.Check(t, pins.checkAuthority(pinnedFingerprint))
+	assert.Check(t, cmp.ErrorIs(pins.checkAuthority(other), ErrAuthorityMismatch))
+	assert.Check(t, cmp.ErrorContains(pins.checkAuthority(other), ssh.FingerprintSHA256(other)+" is not pinned"))
+	assert.Check(t, !pins.match(nil))
+
+	assert.Check(t, authorityPins{}.checkAuthority(other), "nothing pinned means everything is accepted")
+}
+
+type authorityTestDoer struct {
+	authority ssh.Signer
+	krlSigner ssh.Signer
+	requests  []string
+}
+
+func (d *authorityTestDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requests = append(d.requests, req.URL.Path)
+
+	var body []byte
+	switch req.URL.Path {
+	case "/ca":
+		body = ssh.MarshalAuthorizedKey(d.authority.PublicKey())
+	case "/krl":
+		var signers []ssh.Signer
+		if d.krlSigner != nil {
+			signers = append(signers, d.krlSigner)
+		}
+		raw, err := new(krl.KRL).Marshal(rand.Reader, signers...)
+		if err != nil {
+			return nil, err
+		}
+		body = raw
+	}
+
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func Test_Client_pinnedAuthority(t *testing.T) {
+	ctx := context.Background()
+	authority := newVerificationTestSigner(t)
+	rotated := newVerificationTestSigner(t)
+	doer := &authorityTestDoer{authority: authority}
+
+	_, err := NewClient("https://cassh.local", ClientOptionPinnedAuthority("nope"))
+	assert.Check(t, cmp.ErrorContains(err, "unable to parse pinned authority"))
+
+	client, err := NewClient("https://cassh.local",
+		ClientOptionHTTPClient(doer),
+		ClientOptionPinnedAuthority(string(ssh.MarshalAuthorizedKey(authority.PublicKey())), ssh.FingerprintSHA256(rotated.PublicKey())),
+	)
+	assert.NilError(t, err)
+
+	t.Run("authority public key", func(t *testing.T) {
+		_, err := client.AuthorityPublicKey(ctx)
+		assert.NilError(t, err)
+
+		doer.authority = rotated
+		_, err = client.AuthorityPublicKey(ctx)
+		assert.NilError(t, err)
+
+		doer.authority = newVerificationTestSigner(t)
+		_, err = client.AuthorityPublicKey(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrAuthorityMismatch))
+
+		doer.authority = authority
+	})
+
+	t.Run("key revocation list", func(t *testing.T) {
+		doer.krlSigner = nil
+		_, err := client.KeyRevocationList(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrAuthorityMismatch))
+		assert.Check(t, cmp.ErrorContains(err, "key revocation list is not signed"))
+
+		doer.krlSigner = rotated
+		_, err = client.KeyRevocationList(ctx)
+		assert.NilError(t, err)
+
+		doer.krlSigner = newVerificationTestSigner(t)
+		_, err = client.KeyRevocationList(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrAuthorityMismatch))
+
+		t.Run("unsigned lists allowed", func(t *testing.T) {
+			client, err := NewClient("https://cassh.local",
+				ClientOptionHTTPClient(doer),
+				ClientOptionPinnedAuthority(ssh.FingerprintSHA256(authority.PublicKey())),
+				ClientOptionAllowUnsignedKRL(),
+			)
+			assert.NilError(t, err)
+
+			doer.krlSigner = nil
+			_, err = client.KeyRevocationList(ctx)
+			assert.NilError(t, err)
+
+			doer.krlSigner = newVerificationTestSigner(t)
+			_, err = client.KeyRevocationList(ctx)
+			assert.Check(t, cmp.ErrorIs(err, ErrAuthorityMismatch), "signed lists must still be signed by a pinned authority")
+		})
+
+		doer.krlSigner = authority
+	})
+
+	t.Run("certificate verification", func(t *testing.T) {
+		doer.requests = nil
+
+		pins, err := client.certificateAuthorities(ctx, rotated.PublicKey())
+		assert.NilError(t, err)
+		assert.Check(t, pins.match(rotated.PublicKey()))
+		assert.Check(t, pins.match(authority.PublicKey()))
+		assert.Check(t, !pins.match(newVerificationTestSigner(t).PublicKey()))
+		assert.Check(t, cmp.Len(doer.requests, 0), "pinned authorities must not be fetched")
+	})
+
+	t.Run("known hosts", func(t *testing.T) {
+		knownHosts, err := client.AuthorityKnownHosts(ctx, "*.corp")
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(string(knownHosts), "@cert-authority *.corp "+string(ssh.MarshalAuthorizedKey(authority.PublicKey()))))
+	})
+}
+
+func Test_Client_AuthorityKnownHosts(t *testing.T) {
+	ctx := context.Background()
+	doer := &authorityTestDoer{authority: newVerificationTestSigner(t)}
+
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	knownHosts, err := client.AuthorityKnownHosts(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, strings.HasPrefix(string(knownHosts), "@cert-authority * "+doer.authority.PublicKey().Type()))
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"/ca"}))
+
+	client, err = NewClient("https://cassh.local", ClientOptionHTTPClient(doer), ClientOptionPinnedAuthority("SHA256:nope"))
+	assert.NilError(t, err)
+
+	_, err = client.AuthorityKnownHosts(ctx)
+	assert.Check(t, cmp.ErrorIs(err, ErrAuthorityMismatch))
+}
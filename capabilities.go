@@ -0,0 +1,215 @@
+package cassh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ServerFeature defines the features not available on all CASSH server versions.
+type ServerFeature string
+
+const (
+	// ServerFeaturePrincipals is the management of user principals, using the /admin/{username}/principals endpoint.
+	ServerFeaturePrincipals ServerFeature = "principals"
+	// ServerFeatureExpiryUpdate is the update of the expiry of user keys, using PATCH /admin/{username}.
+	ServerFeatureExpiryUpdate ServerFeature = "expiry update"
+	// ServerFeatureAdminListing is the listing of all users, using the /admin/all endpoint.
+	ServerFeatureAdminListing ServerFeature = "admin listing"
+)
+
+// String implements stringer for ServerFeature.
+func (f ServerFeature) String() string { return string(f) }
+
+// SemanticVersion stores a version following the semantic versioning specification.
+type SemanticVersion struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+}
+
+// ParseSemanticVersion parses versions like "1.12.0", "v2.0.0-rc.1", or "1.4"; build metadata is ignored.
+func ParseSemanticVersion(version string) (SemanticVersion, error) {
+	raw := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	raw, _, _ = strings.Cut(raw, "+")
+	raw, preRelease, _ := strings.Cut(raw, "-")
+
+	parts := strings.Split(raw, ".")
+	if len(parts) > 3 {
+		return SemanticVersion{}, fmt.Errorf("invalid semantic version %q", version)
+	}
+
+	var numbers [3]int
+	for i, part := range parts {
+		number, err := strconv.ParseUint(part, 10, 31)
+		if err != nil {
+			return SemanticVersion{}, fmt.Errorf("invalid semantic version %q", version)
+		}
+		numbers[i] = int(number)
+	}
+
+	return SemanticVersion{Major: numbers[0], Minor: numbers[1], Patch: numbers[2], PreRelease: preRelease}, nil
+}
+
+// String implements stringer for SemanticVersion.
+func (v SemanticVersion) String() string {
+	version := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		version += "-" + v.PreRelease
+	}
+	return version
+}
+
+// IsZero returns whenever the version is the zero value, meaning it is unknown.
+func (v SemanticVersion) IsZero() bool { return v == SemanticVersion{} }
+
+// Compare returns -1, 0, or +1 depending on whenever v is lower, equal, or greater than other.
+// Pre-releases are lower than their release, and compared lexically between themselves.
+func (v SemanticVersion) Compare(other SemanticVersion) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		switch {
+		case diff < 0:
+			return -1
+		case diff > 0:
+			return 1
+		}
+	}
+
+	switch {
+	case v.PreRelease == other.PreRelease:
+		return 0
+	case v.PreRelease == "":
+		return 1
+	case other.PreRelease == "":
+		return -1
+	default:
+		return strings.Compare(v.PreRelease, other.PreRelease)
+	}
+}
+
+// ServerCapabilities describes the CASSH server and the features it supports.
+type ServerCapabilities struct {
+	Name string
+	// RawVersion is the version sent by the server, empty for servers not exposing it, like older releases.
+	RawVersion string
+	// Version is RawVersion parsed, zero if it is not a semantic version.
+	Version SemanticVersion
+
+	PrincipalsEndpoint bool
+	ExpiryUpdate       bool
+	AdminListing       bool
+}
+
+// Supports returns whenever the server supports the provided feature.
+func (c ServerCapabilities) Supports(feature ServerFeature) bool {
+	switch feature {
+	case ServerFeaturePrincipals:
+		return c.PrincipalsEndpoint
+	case ServerFeatureExpiryUpdate:
+		return c.ExpiryUpdate
+	case ServerFeatureAdminListing:
+		return c.AdminListing
+	default:
+		return false
+	}
+}
+
+// Capabilities returns the name and version of the CASSH server, negotiated once using Health, and the features it supports.
+// Features are considered supported until the server answers a request using them with a 405 or 501 status; this answer
+// is cached, and further calls using the feature return ErrUnsupportedByServer without contacting the server.
+// Requests answered with a 404 status also return ErrUnsupportedByServer, but are not cached as CASSH answers
+// unknown users with a 404 too.
+func (c *Client) Capabilities(ctx context.Context) (*ServerCapabilities, error) {
+	name, version, err := c.serverVersion.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := &ServerCapabilities{
+		Name:               name,
+		RawVersion:         version,
+		PrincipalsEndpoint: c.serverFeatures.supported(ServerFeaturePrincipals),
+		ExpiryUpdate:       c.serverFeatures.supported(ServerFeatureExpiryUpdate),
+		AdminListing:       c.serverFeatures.supported(ServerFeatureAdminListing),
+	}
+
+	if parsed, err := ParseSemanticVersion(version); err == nil {
+		capabilities.Version = parsed
+	}
+
+	return capabilities, nil
+}
+
+// serverFeatures records the features the server answered it does not support.
+type serverFeatures struct {
+	m           sync.Mutex
+	unsupported map[ServerFeature]bool
+}
+
+func newServerFeatures() *serverFeatures {
+	return &serverFeatures{unsupported: make(map[ServerFeature]bool)}
+}
+
+func (f *serverFeatures) supported(feature ServerFeature) bool {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return !f.unsupported[feature]
+}
+
+// use calls the provided function making a request using the feature, unless the server answered it does not support it.
+// Errors meaning the server does not know the endpoint or the method are returned as ErrUnsupportedByServer;
+// only the ones which cannot mean something else are cached.
+func (f *serverFeatures) use(feature ServerFeature, request func() error) error {
+	if !f.supported(feature) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedByServer, feature)
+	}
+
+	err := request()
+
+	switch unsupportedByServer(err) {
+	case unsupportedByServerNot:
+		return err
+	case unsupportedByServerSurely:
+		f.m.Lock()
+		f.unsupported[feature] = true
+		f.m.Unlock()
+	}
+
+	return fmt.Errorf("%w: %s: %w", ErrUnsupportedByServer, feature, err)
+}
+
+type unsupportedByServerAnswer int
+
+const (
+	unsupportedByServerNot unsupportedByServerAnswer = iota
+	unsupportedByServerProbably
+	unsupportedByServerSurely
+)
+
+// unsupportedByServer tells whenever the error is the answer of a server not knowing the endpoint or the method.
+// A 404 only probably means so: some servers answer it for other reasons. CASSH answers unknown users with a 404
+// on some versions, which does not mean the endpoint is unknown.
+func unsupportedByServer(err error) unsupportedByServerAnswer {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return unsupportedByServerNot
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return unsupportedByServerSurely
+	case http.StatusNotFound:
+		if errors.Is(apiErr, ErrUserNotFound) {
+			return unsupportedByServerNot
+		}
+		return unsupportedByServerProbably
+	default:
+		return unsupportedByServerNot
+	}
+}
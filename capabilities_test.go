@@ -0,0 +1,203 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_ParseSemanticVersion(t *testing.T) {
+	for raw, expected := range map[string]SemanticVersion{
+		"1.12.0":          {Major: 1, Minor: 12},
+		" v2.0.1\n":       {Major: 2, Patch: 1},
+		"1.4":             {Major: 1, Minor: 4},
+		"3":               {Major: 3},
+		"2.0.0-rc.1":      {Major: 2, PreRelease: "rc.1"},
+		"2.0.0-rc.1+abcd": {Major: 2, PreRelease: "rc.1"},
+	} {
+		version, err := ParseSemanticVersion(raw)
+		assert.Check(t, err, raw)
+		assert.Check(t, cmp.Equal(version, expected), raw)
+	}
+
+	for _, raw := range []string{"", "1.2.3.4", "one.two", "1.-2.0", "1..0"} {
+		_, err := ParseSemanticVersion(raw)
+		assert.Check(t, cmp.ErrorContains(err, "invalid semantic version"), raw)
+	}
+}
+
+func Test_SemanticVersion_String(t *testing.T) {
+	assert.Check(t, cmp.Equal(SemanticVersion{Major: 1, Minor: 12}.String(), "1.12.0"))
+	assert.Check(t, cmp.Equal(SemanticVersion{Major: 2, PreRelease: "rc.1"}.String(), "2.0.0-rc.1"))
+	assert.Check(t, SemanticVersion{}.IsZero())
+	assert.Check(t, !SemanticVersion{Patch: 1}.IsZero())
+}
+
+func Test_SemanticVersion_Compare(t *testing.T) {
+	for _, test := range []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "1.12.0", b: "1.12.0", expected: 0},
+		{a: "1.12.0", b: "1.9.0", expected: 1},
+		{a: "1.9.9", b: "2.0.0", expected: -1},
+		{a: "1.0.1", b: "1.0.0", expected: 1},
+		{a: "2.0.0-rc.1", b: "2.0.0", expected: -1},
+		{a: "2.0.0", b: "2.0.0-rc.1", expected: 1},
+		{a: "2.0.0-rc.2", b: "2.0.0-rc.1", expected: 1},
+	} {
+		a, err := ParseSemanticVersion(test.a)
+		assert.NilError(t, err)
+		b, err := ParseSemanticVersion(test.b)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(a.Compare(b), test.expected), "%s %s", test.a, test.b)
+	}
+}
+
+func Test_ServerCapabilities_Supports(t *testing.T) {
+	capabilities := ServerCapabilities{PrincipalsEndpoint: true, AdminListing: true}
+	assert.Check(t, capabilities.Supports(ServerFeaturePrincipals))
+	assert.Check(t, !capabilities.Supports(ServerFeatureExpiryUpdate))
+	assert.Check(t, capabilities.Supports(ServerFeatureAdminListing))
+	assert.Check(t, !capabilities.Supports(ServerFeature("unknown")))
+}
+
+func Test_unsupportedByServer(t *testing.T) {
+	for err, expected := range map[error]unsupportedByServerAnswer{
+		errors.New("boom"): unsupportedByServerNot,
+		newAPIError(http.MethodPost, "/", http.StatusMethodNotAllowed, ""):              unsupportedByServerSurely,
+		newAPIError(http.MethodPost, "/", http.StatusNotImplemented, ""):                unsupportedByServerSurely,
+		newAPIError(http.MethodPost, "/", http.StatusNotFound, "Not Found"):             unsupportedByServerProbably,
+		newAPIError(http.MethodPost, "/", http.StatusNotFound, "User does not exists."): unsupportedByServerNot,
+		newAPIError(http.MethodPost, "/", http.StatusBadRequest, ""):                    unsupportedByServerNot,
+	} {
+		assert.Check(t, cmp.Equal(unsupportedByServer(err), expected), err.Error())
+	}
+	assert.Check(t, cmp.Equal(unsupportedByServer(nil), unsupportedByServerNot))
+}
+
+func Test_serverFeatures_use(t *testing.T) {
+	t.Run("unsupported answers are cached", func(t *testing.T) {
+		features := newServerFeatures()
+
+		var calls int
+		request := func() error {
+			calls++
+			return newAPIError(http.MethodPatch, "/admin/john", http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+
+		err := features.use(ServerFeatureExpiryUpdate, request)
+		assert.Check(t, cmp.ErrorIs(err, ErrUnsupportedByServer))
+		assert.Check(t, cmp.Error(err, "unsupported by server: expiry update: request PATCH /admin/john failed with status 405: Method Not Allowed"))
+
+		err = features.use(ServerFeatureExpiryUpdate, request)
+		assert.Check(t, cmp.Error(err, "unsupported by server: expiry update"))
+		assert.Check(t, cmp.Equal(calls, 1))
+		assert.Check(t, !features.supported(ServerFeatureExpiryUpdate))
+		assert.Check(t, features.supported(ServerFeaturePrincipals))
+	})
+
+	t.Run("not found answers are not cached", func(t *testing.T) {
+		features := newServerFeatures()
+
+		var calls int
+		request := func() error {
+			calls++
+			return newAPIError(http.MethodPost, "/admin/john/principals", http.StatusNotFound, "Not Found")
+		}
+
+		for i := 0; i < 2; i++ {
+			err := features.use(ServerFeaturePrincipals, request)
+			assert.Check(t, cmp.ErrorIs(err, ErrUnsupportedByServer))
+			assert.Check(t, cmp.Error(err, "unsupported by server: principals: request POST /admin/john/principals failed with status 404: Not Found"))
+		}
+		assert.Check(t, cmp.Equal(calls, 2))
+		assert.Check(t, features.supported(ServerFeaturePrincipals))
+	})
+
+	t.Run("other errors are returned as is", func(t *testing.T) {
+		features := newServerFeatures()
+		cause := newAPIError(http.MethodPatch, "/admin/john", http.StatusNotFound, "User does not exists.")
+
+		err := features.use(ServerFeatureExpiryUpdate, func() error { return cause })
+		assert.Check(t, cmp.Equal(err, error(cause)))
+		assert.Check(t, features.supported(ServerFeatureExpiryUpdate))
+
+		assert.Check(t, features.use(ServerFeatureExpiryUpdate, func() error { return nil }))
+	})
+}
+
+type capabilitiesTestDoer struct {
+	version  string
+	statuses map[string]int
+	calls    map[string]int
+}
+
+func (d *capabilitiesTestDoer) Do(req *http.Request) (*http.Response, error) {
+	route := req.Method + " " + req.URL.Path
+	d.calls[route]++
+
+	statusCode, body := http.StatusOK, ""
+	if status, exists := d.statuses[route]; exists {
+		statusCode, body = status, http.StatusText(status)
+	}
+	if route == "GET /health" {
+		body = `{"name":"cassh","version":"` + d.version + `"}`
+	}
+
+	return &http.Response{
+		StatusCode:    statusCode,
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func Test_Client_Capabilities(t *testing.T) {
+	ctx := context.Background()
+	doer := &capabilitiesTestDoer{
+		version: "v1.12.0",
+		statuses: map[string]int{
+			"POST /admin/john/principals": http.StatusNotFound,
+			"PATCH /admin/john":           http.StatusMethodNotAllowed,
+		},
+		calls: make(map[string]int),
+	}
+
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	capabilities, err := client.Capabilities(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(capabilities, &ServerCapabilities{
+		Name:               "cassh",
+		RawVersion:         "v1.12.0",
+		Version:            SemanticVersion{Major: 1, Minor: 12},
+		PrincipalsEndpoint: true,
+		ExpiryUpdate:       true,
+		AdminListing:       true,
+	}))
+
+	user := client.SessionAdmin().User("john")
+	for i := 0; i < 2; i++ {
+		assert.Check(t, cmp.ErrorIs(user.Principals().Add(ctx, "root"), ErrUnsupportedByServer))
+		assert.Check(t, cmp.ErrorIs(user.Key().SetExpiry(ctx, time.Hour), ErrUnsupportedByServer))
+	}
+	assert.Check(t, cmp.Equal(doer.calls["POST /admin/john/principals"], 2), "requests must be sent whatever the version")
+	assert.Check(t, cmp.Equal(doer.calls["PATCH /admin/john"], 1))
+	assert.Check(t, user.Key().Activate(ctx))
+
+	capabilities, err = client.Capabilities(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, capabilities.Supports(ServerFeaturePrincipals))
+	assert.Check(t, !capabilities.Supports(ServerFeatureExpiryUpdate))
+	assert.Check(t, capabilities.Supports(ServerFeatureAdminListing))
+	assert.Check(t, cmp.Equal(doer.calls["GET /health"], 1))
+}
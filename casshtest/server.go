@@ -0,0 +1,251 @@
+// Package casshtest provides an in-memory CASSH server to test code built on top of the cassh package.
+package casshtest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/krostar/cassh"
+)
+
+// Server is a stateful in-memory CASSH server.
+// It signs real certificates with its authority key and generates real key revocation lists.
+// It is safe to use it concurrently.
+type Server struct {
+	httpServer *httptest.Server
+	o          *serverOptions
+
+	m              sync.Mutex
+	users          map[cassh.Username]*User
+	revokedKeys    []ssh.PublicKey
+	krlVersion     uint64
+	krlModified    time.Time
+	certificateSN  uint64
+	failures       []*Failure
+	requests       []Request
+	requestsByPath map[string]int
+}
+
+// Request stores what the server received for a single request.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Form   url.Values
+}
+
+// Failure describes a failure the server will answer to matching requests instead of handling them.
+type Failure struct {
+	// Method matches the request method, any method matches if empty.
+	Method string
+	// Path matches the request path, any path matches if empty.
+	Path string
+	// StatusCode is the status code answered.
+	StatusCode int
+	// Message is the body answered.
+	Message string
+	// Count is the number of matching requests that will fail, zero means once and negative means forever.
+	Count int
+}
+
+// NewServer starts a new CASSH server; it should be closed once done.
+func NewServer(opts ...ServerOption) (*Server, error) {
+	o := serverOptionsDefaults()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.authority == nil {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate authority key: %v", err)
+		}
+
+		if o.authority, err = ssh.NewSignerFromKey(privateKey); err != nil {
+			return nil, fmt.Errorf("unable to create authority signer: %v", err)
+		}
+	}
+
+	s := &Server{
+		o:              o,
+		users:          make(map[cassh.Username]*User),
+		krlVersion:     1,
+		krlModified:    o.now(),
+		requestsByPath: make(map[string]int),
+	}
+
+	if o.insecureProtocol {
+		s.httpServer = httptest.NewServer(s.handler())
+	} else {
+		s.httpServer = httptest.NewTLSServer(s.handler())
+	}
+
+	return s, nil
+}
+
+// Close shuts down the server.
+func (s *Server) Close() { s.httpServer.Close() }
+
+// URL returns the base url of the server.
+func (s *Server) URL() string { return s.httpServer.URL }
+
+// HTTPClient returns an http client configured to trust the server.
+func (s *Server) HTTPClient() *http.Client { return s.httpServer.Client() }
+
+// NewClient creates a cassh client configured to talk to the server.
+func (s *Server) NewClient(opts ...cassh.ClientOption) (*cassh.Client, error) {
+	opts = append([]cassh.ClientOption{
+		cassh.ClientOptionHTTPClient(s.HTTPClient()),
+		cassh.ClientOptionServerTimezone(s.o.timezone),
+	}, opts...)
+
+	if s.o.insecureProtocol {
+		opts = append(opts, cassh.ClientOptionTolerateInsecureProtocols())
+	}
+
+	return cassh.NewClient(s.URL(), opts...)
+}
+
+// Authority returns the public key of the server certificate authority.
+func (s *Server) Authority() ssh.PublicKey { return s.o.authority.PublicKey() }
+
+// SeedUser creates or replaces a user.
+func (s *Server) SeedUser(user User) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if user.State == "" {
+		user.State = cassh.KeyStatePending
+	}
+
+	if user.Expiration.IsZero() {
+		user.Expiration = s.o.now()
+	}
+
+	s.users[user.Name] = &user
+}
+
+// User returns a copy of the stored user.
+func (s *Server) User(username cassh.Username) (User, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		return User{}, false
+	}
+
+	return user.clone(), true
+}
+
+// RevokeKey adds the provided key to the key revocation list.
+func (s *Server) RevokeKey(key ssh.PublicKey) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.revokeKey(key)
+}
+
+func (s *Server) revokeKey(key ssh.PublicKey) {
+	s.revokedKeys = append(s.revokedKeys, key)
+	s.krlVersion++
+	s.krlModified = s.o.now()
+}
+
+// KeyRevocationList returns the current key revocation list.
+func (s *Server) KeyRevocationList() *krl.KRL {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.keyRevocationList()
+}
+
+func (s *Server) keyRevocationList() *krl.KRL {
+	list := &krl.KRL{
+		Version: s.krlVersion,
+		Comment: "casshtest",
+	}
+
+	if len(s.revokedKeys) > 0 {
+		section := append(krl.KRLExplicitKeySection(nil), s.revokedKeys...)
+		list.Sections = append(list.Sections, &section)
+	}
+
+	return list
+}
+
+// InjectFailure makes the server fail matching requests.
+func (s *Server) InjectFailure(failure Failure) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if failure.Count == 0 {
+		failure.Count = 1
+	}
+
+	s.failures = append(s.failures, &failure)
+}
+
+// Requests returns all the requests received by the server, in order.
+func (s *Server) Requests() []Request {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestsCount returns the number of requests received for the provided path.
+func (s *Server) RequestsCount(path string) int {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.requestsByPath[path]
+}
+
+// ResetRequests forgets all received requests.
+func (s *Server) ResetRequests() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.requests = nil
+	s.requestsByPath = make(map[string]int)
+}
+
+func (s *Server) recordRequest(r *http.Request) *Failure {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Form:   r.PostForm,
+	})
+	s.requestsByPath[r.URL.Path]++
+
+	for i, failure := range s.failures {
+		if (failure.Method != "" && failure.Method != r.Method) || (failure.Path != "" && failure.Path != r.URL.Path) {
+			continue
+		}
+
+		if failure.Count > 0 {
+			failure.Count--
+			if failure.Count == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+
+		return failure
+	}
+
+	return nil
+}
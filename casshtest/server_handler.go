@@ -0,0 +1,510 @@
+package casshtest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/krostar/cassh"
+	"github.com/krostar/httpclient"
+)
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/ping", s.handlePing)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/ca", s.handleAuthority)
+	mux.HandleFunc("/krl", s.handleKeyRevocationList)
+	mux.HandleFunc("/client", s.handleClient)
+	mux.HandleFunc("/client/status", s.handleClientStatus)
+	mux.HandleFunc("/admin/", s.handleAdmin)
+	mux.HandleFunc("/test_auth", s.handleTestAuth)
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if err := httpclient.ParsePostForm(r); err != nil {
+			writeText(rw, http.StatusBadRequest, "Error: "+err.Error())
+			return
+		}
+
+		if failure := s.recordRequest(r); failure != nil {
+			writeText(rw, failure.StatusCode, failure.Message)
+			return
+		}
+
+		mux.ServeHTTP(rw, r)
+	})
+}
+
+func writeText(rw http.ResponseWriter, status int, message string) {
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.WriteHeader(status)
+	_, _ = io.WriteString(rw, message)
+}
+
+func writeJSON(rw http.ResponseWriter, obj any) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		writeText(rw, http.StatusInternalServerError, "Error: "+err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(raw)
+}
+
+func methodNotAllowed(rw http.ResponseWriter) {
+	writeText(rw, http.StatusMethodNotAllowed, "Error: method not allowed")
+}
+
+func (s *Server) handlePing(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(rw)
+		return
+	}
+
+	writeText(rw, http.StatusOK, "pong")
+}
+
+func (s *Server) handleHealth(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(rw)
+		return
+	}
+
+	writeJSON(rw, map[string]string{"name": s.o.name, "version": s.o.version})
+}
+
+func (s *Server) handleAuthority(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(rw)
+		return
+	}
+
+	writeText(rw, http.StatusOK, string(ssh.MarshalAuthorizedKey(s.o.authority.PublicKey())))
+}
+
+func (s *Server) handleKeyRevocationList(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(rw)
+		return
+	}
+
+	s.m.Lock()
+	list := s.keyRevocationList()
+	modified := s.krlModified
+	s.m.Unlock()
+
+	etag := fmt.Sprintf("%q", strconv.FormatUint(list.Version, 10))
+	rw.Header().Set("ETag", etag)
+	rw.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+
+	if r.Header.Get("If-None-Match") == etag {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var signers []ssh.Signer
+	if s.o.signKRL {
+		signers = append(signers, s.o.authority)
+	}
+
+	raw, err := list.Marshal(rand.Reader, signers...)
+	if err != nil {
+		writeText(rw, http.StatusInternalServerError, "Error: "+err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(raw)
+}
+
+// authenticate checks LDAP credentials and returns the realname of the caller.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	realname := r.PostForm.Get("realname")
+
+	if s.o.ldapCredentials == nil {
+		return realname, true
+	}
+
+	password, exists := s.o.ldapCredentials[realname]
+	return realname, exists && password == r.PostForm.Get("password")
+}
+
+func (s *Server) authenticateAdmin(r *http.Request) bool {
+	realname, authenticated := s.authenticate(r)
+	if !authenticated {
+		return false
+	}
+
+	if s.o.admins == nil {
+		return true
+	}
+
+	for _, admin := range s.o.admins {
+		if admin == realname {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) handleClient(rw http.ResponseWriter, r *http.Request) {
+	realname, authenticated := s.authenticate(r)
+	if !authenticated {
+		writeText(rw, http.StatusUnauthorized, "Error: authentication failed")
+		return
+	}
+
+	username := cassh.Username(r.PostForm.Get("username"))
+	if username == "" {
+		writeText(rw, http.StatusBadRequest, "Error: No username option given.")
+		return
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(r.PostForm.Get("pubkey")))
+	if err != nil {
+		writeText(rw, http.StatusBadRequest, "Error: Invalid pubkey.")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.handleClientSetKey(rw, username, realname, publicKey)
+	case http.MethodPost:
+		s.handleClientSign(rw, username, publicKey)
+	default:
+		methodNotAllowed(rw)
+	}
+}
+
+func (s *Server) handleClientSetKey(rw http.ResponseWriter, username cassh.Username, realname string, publicKey ssh.PublicKey) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if realname == "" {
+		realname = username.String()
+	}
+
+	user, exists := s.users[username]
+	if !exists {
+		s.users[username] = &User{
+			Name:       username,
+			RealName:   realname,
+			State:      cassh.KeyStatePending,
+			PublicKey:  publicKey,
+			Principals: cassh.Principals{cassh.Principal(username)},
+			Expiration: s.o.now(),
+		}
+		writeText(rw, http.StatusOK, fmt.Sprintf("Create user=%s. Pending request.", username))
+		return
+	}
+
+	if user.State == cassh.KeyStateRevoked {
+		writeText(rw, http.StatusForbidden, "Status: "+cassh.KeyStateRevoked.String())
+		return
+	}
+
+	if user.PublicKey == nil || !bytes.Equal(user.PublicKey.Marshal(), publicKey.Marshal()) {
+		user.PublicKey = publicKey
+		user.State = cassh.KeyStatePending
+	}
+
+	writeText(rw, http.StatusOK, fmt.Sprintf("Update user=%s. Pending request.", username))
+}
+
+func (s *Server) handleClientSign(rw http.ResponseWriter, username cassh.Username, publicKey ssh.PublicKey) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		writeText(rw, http.StatusBadRequest, "Error : User absent, please create an account.")
+		return
+	}
+
+	if user.PublicKey == nil || !bytes.Equal(user.PublicKey.Marshal(), publicKey.Marshal()) {
+		writeText(rw, http.StatusBadRequest, "Error : User or Key absent, add your key again.")
+		return
+	}
+
+	if user.State != cassh.KeyStateActive {
+		writeText(rw, http.StatusForbidden, "Status: "+user.State.String())
+		return
+	}
+
+	expiry := user.Expiry
+	if expiry == 0 {
+		expiry = s.o.defaultExpiry
+	}
+
+	now := s.o.now()
+	s.certificateSN++
+
+	certificate := &ssh.Certificate{
+		Key:             publicKey,
+		Serial:          s.certificateSN,
+		CertType:        ssh.UserCert,
+		KeyId:           user.Name.String(),
+		ValidPrincipals: make([]string, len(user.Principals)),
+		ValidAfter:      uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(expiry).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-X11-forwarding":   "",
+				"permit-agent-forwarding": "",
+				"permit-port-forwarding":  "",
+				"permit-pty":              "",
+				"permit-user-rc":          "",
+			},
+		},
+	}
+
+	for i, principal := range user.Principals {
+		certificate.ValidPrincipals[i] = principal.String()
+	}
+
+	if err := certificate.SignCert(rand.Reader, s.o.authority); err != nil {
+		writeText(rw, http.StatusInternalServerError, "Error: "+err.Error())
+		return
+	}
+
+	user.Expiration = time.Unix(int64(certificate.ValidBefore), 0)
+
+	writeText(rw, http.StatusOK, string(ssh.MarshalAuthorizedKey(certificate)))
+}
+
+func (s *Server) handleClientStatus(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(rw)
+		return
+	}
+
+	if _, authenticated := s.authenticate(r); !authenticated {
+		writeText(rw, http.StatusUnauthorized, "Error: authentication failed")
+		return
+	}
+
+	s.writeUserStatus(rw, cassh.Username(r.PostForm.Get("username")))
+}
+
+func (s *Server) writeUserStatus(rw http.ResponseWriter, username cassh.Username) {
+	s.m.Lock()
+	user, exists := s.users[username]
+	var status apiUserStatus
+	if exists {
+		status = user.apiStatus(s.o.timezone, s.o.defaultExpiry)
+	}
+	s.m.Unlock()
+
+	if !exists {
+		writeText(rw, http.StatusBadRequest, "User does not exists.")
+		return
+	}
+
+	writeJSON(rw, status)
+}
+
+// writeUsersStatus writes the status of all users, indexed by username as the CASSH server does.
+func (s *Server) writeUsersStatus(rw http.ResponseWriter) {
+	s.m.Lock()
+	statuses := make(map[cassh.Username]apiUserStatus, len(s.users))
+	for username, user := range s.users {
+		statuses[username] = user.apiStatus(s.o.timezone, s.o.defaultExpiry)
+	}
+	s.m.Unlock()
+
+	writeJSON(rw, statuses)
+}
+
+func (s *Server) handleTestAuth(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(rw)
+		return
+	}
+
+	if !s.authenticateAdmin(r) {
+		writeText(rw, http.StatusUnauthorized, "Error: authentication failed")
+		return
+	}
+
+	writeText(rw, http.StatusOK, "OK")
+}
+
+func (s *Server) handleAdmin(rw http.ResponseWriter, r *http.Request) {
+	if !s.authenticateAdmin(r) {
+		writeText(rw, http.StatusUnauthorized, "Error: authentication failed")
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/"), "/")
+
+	switch {
+	case len(path) == 1 && path[0] != "":
+		s.handleAdminUser(rw, r, cassh.Username(path[0]))
+	case len(path) == 2 && path[0] != "" && path[1] == "principals":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(rw)
+			return
+		}
+		s.handleAdminUserPrincipals(rw, r, cassh.Username(path[0]))
+	default:
+		writeText(rw, http.StatusNotFound, "Error: not found")
+	}
+}
+
+func (s *Server) handleAdminUser(rw http.ResponseWriter, r *http.Request, username cassh.Username) {
+	switch r.Method {
+	case http.MethodPost:
+		switch {
+		case r.PostForm.Get("status") == strconv.FormatBool(true) && username == "all":
+			s.writeUsersStatus(rw)
+		case r.PostForm.Get("status") == strconv.FormatBool(true):
+			s.writeUserStatus(rw, username)
+		case r.PostForm.Get("revoke") == strconv.FormatBool(true):
+			s.handleAdminUserRevoke(rw, username)
+		default:
+			s.handleAdminUserActivate(rw, username)
+		}
+	case http.MethodPatch:
+		s.handleAdminUserSetExpiry(rw, r, username)
+	case http.MethodDelete:
+		s.handleAdminUserDelete(rw, username)
+	default:
+		methodNotAllowed(rw)
+	}
+}
+
+func (s *Server) handleAdminUserActivate(rw http.ResponseWriter, username cassh.Username) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	user, exists := s.users[username]
+	switch {
+	case !exists:
+		writeText(rw, http.StatusBadRequest, "User does not exists.")
+	case user.State == cassh.KeyStateRevoked:
+		writeText(rw, http.StatusBadRequest, fmt.Sprintf("user=%s has been REVOKED, delete it first.", username))
+	case user.State == cassh.KeyStateActive:
+		writeText(rw, http.StatusOK, fmt.Sprintf("user=%s already active. Nothing done.", username))
+	default:
+		user.State = cassh.KeyStateActive
+		writeText(rw, http.StatusOK, fmt.Sprintf("Active user=%s. SSH Key active but need to be signed.", username))
+	}
+}
+
+func (s *Server) handleAdminUserRevoke(rw http.ResponseWriter, username cassh.Username) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		writeText(rw, http.StatusBadRequest, "User does not exists.")
+		return
+	}
+
+	if user.State != cassh.KeyStateRevoked && user.PublicKey != nil {
+		s.revokeKey(user.PublicKey)
+	}
+
+	user.State = cassh.KeyStateRevoked
+	writeText(rw, http.StatusOK, fmt.Sprintf("Revoke user=%s.", username))
+}
+
+func (s *Server) handleAdminUserSetExpiry(rw http.ResponseWriter, r *http.Request, username cassh.Username) {
+	expiry, err := time.ParseDuration(strings.TrimPrefix(r.PostForm.Get("expiry"), "+"))
+	if err != nil || expiry < time.Hour {
+		writeText(rw, http.StatusBadRequest, "Error: invalid expiry.")
+		return
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		writeText(rw, http.StatusBadRequest, "User does not exists.")
+		return
+	}
+
+	user.Expiry = expiry
+	writeText(rw, http.StatusOK, fmt.Sprintf("OK: expiry=%s for %s", r.PostForm.Get("expiry"), username))
+}
+
+func (s *Server) handleAdminUserDelete(rw http.ResponseWriter, username cassh.Username) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, exists := s.users[username]; !exists {
+		writeText(rw, http.StatusBadRequest, "User does not exists.")
+		return
+	}
+
+	delete(s.users, username)
+	writeText(rw, http.StatusOK, "OK")
+}
+
+func (s *Server) handleAdminUserPrincipals(rw http.ResponseWriter, r *http.Request, username cassh.Username) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		writeText(rw, http.StatusBadRequest, "User does not exists.")
+		return
+	}
+
+	principals := user.Principals
+
+	switch {
+	case r.PostForm.Get("purge") == strconv.FormatBool(true):
+		principals = nil
+	case len(r.PostForm["update"]) > 0:
+		principals = nil
+		for _, principal := range r.PostForm["update"] {
+			principals = addPrincipal(principals, cassh.Principal(principal))
+		}
+	case len(r.PostForm["add"]) > 0:
+		for _, principal := range r.PostForm["add"] {
+			principals = addPrincipal(principals, cassh.Principal(principal))
+		}
+	case len(r.PostForm["remove"]) > 0:
+		for _, principal := range r.PostForm["remove"] {
+			principals = removePrincipal(principals, cassh.Principal(principal))
+		}
+	default:
+		writeText(rw, http.StatusBadRequest, "Error: No principals action given.")
+		return
+	}
+
+	user.Principals = principals
+	writeText(rw, http.StatusOK, "OK")
+}
+
+func addPrincipal(principals cassh.Principals, principal cassh.Principal) cassh.Principals {
+	if principals.Has(principal) == nil {
+		return principals
+	}
+	return append(principals, principal)
+}
+
+func removePrincipal(principals cassh.Principals, principal cassh.Principal) cassh.Principals {
+	filtered := make(cassh.Principals, 0, len(principals))
+	for _, p := range principals {
+		if p != principal {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
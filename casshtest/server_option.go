@@ -0,0 +1,100 @@
+package casshtest
+
+import (
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ServerOption defines the signature of all options usable on NewServer.
+type ServerOption func(o *serverOptions)
+
+type serverOptions struct {
+	authority        ssh.Signer
+	ldapCredentials  map[string]string
+	admins           []string
+	timezone         *time.Location
+	now              func() time.Time
+	defaultExpiry    time.Duration
+	name             string
+	version          string
+	signKRL          bool
+	insecureProtocol bool
+}
+
+func serverOptionsDefaults() *serverOptions {
+	return &serverOptions{
+		timezone:      time.UTC,
+		now:           time.Now,
+		defaultExpiry: 24 * time.Hour,
+		name:          "cassh",
+		version:       "1.12.0",
+	}
+}
+
+// ServerOptionAuthority sets the key used by the server to sign certificates.
+// By default, a new ed25519 key is generated for each server.
+func ServerOptionAuthority(authority ssh.Signer) ServerOption {
+	return func(o *serverOptions) {
+		o.authority = authority
+	}
+}
+
+// ServerOptionLDAPCredentials sets the realname/password pairs accepted by the server.
+// Once set, every user and admin request must provide valid credentials.
+func ServerOptionLDAPCredentials(credentials map[string]string) ServerOption {
+	return func(o *serverOptions) {
+		o.ldapCredentials = credentials
+	}
+}
+
+// ServerOptionAdmins sets the realnames allowed to make admin requests.
+// By default, any caller is considered an admin.
+func ServerOptionAdmins(realnames ...string) ServerOption {
+	return func(o *serverOptions) {
+		o.admins = realnames
+	}
+}
+
+// ServerOptionTimezone sets the timezone used to format expiration times in user status responses.
+func ServerOptionTimezone(timezone *time.Location) ServerOption {
+	return func(o *serverOptions) {
+		o.timezone = timezone
+	}
+}
+
+// ServerOptionClock sets the function used by the server to get the current time.
+func ServerOptionClock(now func() time.Time) ServerOption {
+	return func(o *serverOptions) {
+		o.now = now
+	}
+}
+
+// ServerOptionDefaultExpiry sets the validity duration of certificates for users without a specific expiry.
+func ServerOptionDefaultExpiry(expiry time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.defaultExpiry = expiry
+	}
+}
+
+// ServerOptionVersion sets the name and version returned by the /health endpoint.
+func ServerOptionVersion(name, version string) ServerOption {
+	return func(o *serverOptions) {
+		o.name = name
+		o.version = version
+	}
+}
+
+// ServerOptionSignKRL makes the server sign the key revocation list with its authority key.
+func ServerOptionSignKRL() ServerOption {
+	return func(o *serverOptions) {
+		o.signKRL = true
+	}
+}
+
+// ServerOptionInsecureProtocol makes the server listen using http instead of https.
+func ServerOptionInsecureProtocol() ServerOption {
+	return func(o *serverOptions) {
+		o.insecureProtocol = true
+	}
+}
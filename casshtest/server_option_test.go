@@ -0,0 +1,83 @@
+package casshtest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_serverOptionsDefaults(t *testing.T) {
+	opts := serverOptionsDefaults()
+	assert.Check(t, opts.authority == nil)
+	assert.Check(t, opts.timezone == time.UTC)
+	assert.Check(t, opts.now != nil)
+	assert.Check(t, opts.defaultExpiry > 0)
+	assert.Check(t, !opts.signKRL)
+	assert.Check(t, !opts.insecureProtocol)
+}
+
+func Test_ServerOptionAuthority(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	assert.NilError(t, err)
+
+	opts := serverOptionsDefaults()
+	ServerOptionAuthority(signer)(opts)
+	assert.Check(t, opts.authority == signer)
+}
+
+func Test_ServerOptionLDAPCredentials(t *testing.T) {
+	opts := serverOptionsDefaults()
+	ServerOptionLDAPCredentials(map[string]string{"foo": "bar"})(opts)
+	assert.Check(t, cmp.DeepEqual(opts.ldapCredentials, map[string]string{"foo": "bar"}))
+}
+
+func Test_ServerOptionAdmins(t *testing.T) {
+	opts := serverOptionsDefaults()
+	ServerOptionAdmins("foo", "bar")(opts)
+	assert.Check(t, cmp.DeepEqual(opts.admins, []string{"foo", "bar"}))
+}
+
+func Test_ServerOptionTimezone(t *testing.T) {
+	opts := serverOptionsDefaults()
+	ServerOptionTimezone(time.Local)(opts)
+	assert.Check(t, opts.timezone == time.Local)
+}
+
+func Test_ServerOptionClock(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := serverOptionsDefaults()
+	ServerOptionClock(func() time.Time { return now })(opts)
+	assert.Check(t, opts.now().Equal(now))
+}
+
+func Test_ServerOptionDefaultExpiry(t *testing.T) {
+	opts := serverOptionsDefaults()
+	ServerOptionDefaultExpiry(time.Minute)(opts)
+	assert.Check(t, cmp.Equal(opts.defaultExpiry, time.Minute))
+}
+
+func Test_ServerOptionVersion(t *testing.T) {
+	opts := serverOptionsDefaults()
+	ServerOptionVersion("foo", "1.2.3")(opts)
+	assert.Check(t, cmp.Equal(opts.name, "foo"))
+	assert.Check(t, cmp.Equal(opts.version, "1.2.3"))
+}
+
+func Test_ServerOptionSignKRL(t *testing.T) {
+	opts := serverOptionsDefaults()
+	ServerOptionSignKRL()(opts)
+	assert.Check(t, opts.signKRL)
+}
+
+func Test_ServerOptionInsecureProtocol(t *testing.T) {
+	opts := serverOptionsDefaults()
+	ServerOptionInsecureProtocol()(opts)
+	assert.Check(t, opts.insecureProtocol)
+}
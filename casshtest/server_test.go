@@ -0,0 +1,324 @@
+package casshtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/krostar/cassh"
+)
+
+func newTestKey(t *testing.T) ssh.PublicKey {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	assert.NilError(t, err)
+	return sshPublicKey
+}
+
+func newTestServer(t *testing.T, opts ...ServerOption) (*Server, *cassh.Client) {
+	srv, err := NewServer(opts...)
+	assert.NilError(t, err)
+	t.Cleanup(srv.Close)
+
+	client, err := srv.NewClient()
+	assert.NilError(t, err)
+
+	return srv, client
+}
+
+func Test_Server_client(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newTestServer(t, ServerOptionVersion("cassh-test", "4.2.0"))
+
+	assert.NilError(t, client.Ping(ctx))
+
+	name, version, err := client.Health(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(name, "cassh-test"))
+	assert.Check(t, cmp.Equal(version, "4.2.0"))
+
+	authority, err := client.AuthorityPublicKey(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(authority.Marshal(), srv.Authority().Marshal()))
+
+	list, err := client.KeyRevocationList(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(list.Sections, 0))
+}
+
+func Test_Server_userLifecycle(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newTestServer(t)
+	key := newTestKey(t)
+
+	user := client.SessionUser("john")
+	admin := client.SessionAdmin().User("john")
+
+	_, err := user.Status(ctx)
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrUserNotFound))
+
+	assert.NilError(t, user.Key(key).Set(ctx))
+
+	status, err := user.Status(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(status.KeyState, cassh.KeyStatePending))
+	assert.Check(t, status.KeyMatches(key))
+	assert.Check(t, cmp.Equal(status.KeyAlgorithm, "ED25519"))
+	assert.Check(t, cmp.Equal(status.KeyBits, 256))
+	assert.Check(t, cmp.Equal(status.KeyStrength, cassh.KeyStrengthHigh))
+
+	_, err = user.Key(key).Sign(ctx)
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrKeyPending))
+
+	assert.NilError(t, admin.Key().Activate(ctx))
+	assert.NilError(t, admin.Key().SetExpiry(ctx, 2*time.Hour))
+	assert.NilError(t, admin.Principals().Set(ctx, "root", "john"))
+
+	certificate, err := user.Key(key).Sign(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(certificate.CertType, uint32(ssh.UserCert)))
+	assert.Check(t, cmp.DeepEqual(certificate.ValidPrincipals, []string{"root", "john"}))
+	assert.Check(t, cmp.DeepEqual(certificate.SignatureKey.Marshal(), srv.Authority().Marshal()))
+	assert.NilError(t, (&ssh.CertChecker{}).CheckCert("root", certificate))
+
+	status, err = admin.Status(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(status.KeyState, cassh.KeyStateActive))
+	assert.Check(t, cmp.Equal(status.KeyExpiration.Unix(), int64(certificate.ValidBefore)))
+	assert.Check(t, cmp.Equal(status.KeyExpiry, 2*time.Hour))
+
+	assert.NilError(t, admin.Key().Revoke(ctx))
+
+	_, err = user.Key(key).Sign(ctx)
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrKeyRevoked))
+
+	list, err := client.KeyRevocationList(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, list.IsRevoked(key))
+
+	assert.NilError(t, admin.Key().Delete(ctx))
+	_, exists := srv.User("john")
+	assert.Check(t, !exists)
+}
+
+func Test_Server_authentication(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newTestServer(t,
+		ServerOptionLDAPCredentials(map[string]string{"john@corp": "secret", "admin@corp": "admin"}),
+		ServerOptionAdmins("admin@corp"),
+	)
+	srv.SeedUser(User{Name: "john", RealName: "john@corp", PublicKey: newTestKey(t)})
+
+	_, err := client.SessionUser("john").Status(ctx)
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrInsufficientPrivileges))
+
+	_, err = client.SessionUser("john", cassh.SessionUserOptionAuthenticationMechanismLDAP("john@corp", "secret")).Status(ctx)
+	assert.NilError(t, err)
+
+	err = client.SessionAdmin(cassh.SessionAdminOptionAuthenticationMechanismLDAP("john@corp", "secret")).CheckAuthentication(ctx)
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrInsufficientPrivileges))
+
+	err = client.SessionAdmin(cassh.SessionAdminOptionAuthenticationMechanismLDAP("admin@corp", "admin")).CheckAuthentication(ctx)
+	assert.NilError(t, err)
+
+	requests := srv.Requests()
+	assert.Assert(t, cmp.Len(requests, 4))
+	assert.Check(t, cmp.Equal(requests[3].Path, "/test_auth"))
+	assert.Check(t, cmp.Equal(requests[3].Form.Get("realname"), "admin@corp"))
+	assert.Check(t, cmp.Equal(srv.RequestsCount("/client/status"), 2))
+
+	srv.ResetRequests()
+	assert.Check(t, cmp.Len(srv.Requests(), 0))
+}
+
+func Test_Server_InjectFailure(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newTestServer(t)
+
+	srv.InjectFailure(Failure{Path: "/ping", StatusCode: http.StatusBadGateway, Count: 2})
+	srv.InjectFailure(Failure{Method: http.MethodGet, StatusCode: http.StatusTeapot, Message: "short and stout", Count: -1})
+
+	assert.Check(t, cmp.ErrorIs(client.Ping(ctx), cassh.ErrServerFailure))
+	assert.Check(t, cmp.ErrorIs(client.Ping(ctx), cassh.ErrServerFailure))
+
+	for i := 0; i < 3; i++ {
+		assert.Check(t, cmp.ErrorContains(client.Ping(ctx), "failed with status 418: short and stout"))
+	}
+
+	_, err := client.SessionUser("john").Status(ctx)
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrUserNotFound))
+}
+
+func Test_Server_RevokeKey(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newTestServer(t, ServerOptionSignKRL())
+	key := newTestKey(t)
+
+	srv.RevokeKey(key)
+	assert.Check(t, srv.KeyRevocationList().IsRevoked(key))
+
+	list, err := client.KeyRevocationList(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, list.IsRevoked(key))
+	assert.Check(t, cmp.Equal(list.Version, uint64(2)))
+	assert.Assert(t, cmp.Len(list.SigningKeys, 1))
+	assert.Check(t, cmp.DeepEqual(list.SigningKeys[0].Marshal(), srv.Authority().Marshal()))
+}
+
+func Test_Server_KeyRevocationList_conditional(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	get := func(etag string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL()+"/krl", http.NoBody)
+		assert.NilError(t, err)
+		req.Header.Set("If-None-Match", etag)
+		resp, err := srv.HTTPClient().Do(req)
+		assert.NilError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get("")
+	assert.Check(t, cmp.Equal(resp.StatusCode, http.StatusOK))
+	assert.Check(t, resp.Header.Get("Last-Modified") != "")
+	etag := resp.Header.Get("ETag")
+	assert.Check(t, cmp.Equal(etag, `"1"`))
+
+	assert.Check(t, cmp.Equal(get(etag).StatusCode, http.StatusNotModified))
+
+	srv.RevokeKey(newTestKey(t))
+	resp = get(etag)
+	assert.Check(t, cmp.Equal(resp.StatusCode, http.StatusOK))
+	assert.Check(t, cmp.Equal(resp.Header.Get("ETag"), `"2"`))
+}
+
+func Test_Server_principals(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newTestServer(t)
+	srv.SeedUser(User{Name: "john", State: cassh.KeyStateActive, PublicKey: newTestKey(t), Principals: cassh.Principals{"john"}})
+
+	principals := client.SessionAdmin().User("john").Principals()
+
+	assert.NilError(t, principals.Add(ctx, "root", "john"))
+	user, _ := srv.User("john")
+	assert.Check(t, cmp.DeepEqual(user.Principals, cassh.Principals{"john", "root"}))
+
+	assert.NilError(t, principals.Remove(ctx, "john"))
+	user, _ = srv.User("john")
+	assert.Check(t, cmp.DeepEqual(user.Principals, cassh.Principals{"root"}))
+
+	assert.NilError(t, principals.Reset(ctx))
+	user, _ = srv.User("john")
+	assert.Check(t, cmp.Len(user.Principals, 0))
+}
+
+func Test_Server_users(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	srv, client := newTestServer(t)
+	srv.SeedUser(User{Name: "john", State: cassh.KeyStateActive, PublicKey: newTestKey(t), Principals: cassh.Principals{"john", "root"}, Expiration: now.Add(time.Hour)})
+	srv.SeedUser(User{Name: "jane", State: cassh.KeyStatePending, PublicKey: newTestKey(t), Expiration: now.Add(48 * time.Hour)})
+	srv.SeedUser(User{Name: "bob", State: cassh.KeyStateRevoked, Expiration: now.Add(time.Hour)})
+
+	usernames := func(filter cassh.UsersFilter) []cassh.Username {
+		users, err := client.SessionAdmin().Users(ctx, filter)
+		assert.NilError(t, err)
+
+		names := make([]cassh.Username, len(users))
+		for i, user := range users {
+			names[i] = user.Name
+		}
+		return names
+	}
+
+	assert.Check(t, cmp.DeepEqual(usernames(cassh.UsersFilter{}), []cassh.Username{"bob", "jane", "john"}))
+	assert.Check(t, cmp.DeepEqual(usernames(cassh.UsersFilter{KeyStates: []cassh.KeyState{cassh.KeyStatePending}}), []cassh.Username{"jane"}))
+	assert.Check(t, cmp.DeepEqual(usernames(cassh.UsersFilter{Principal: "root"}), []cassh.Username{"john"}))
+	assert.Check(t, cmp.DeepEqual(usernames(cassh.UsersFilter{ExpiringBefore: now.Add(2 * time.Hour)}), []cassh.Username{"bob", "john"}))
+	assert.Check(t, cmp.DeepEqual(usernames(cassh.UsersFilter{NamePattern: "j*"}), []cassh.Username{"jane", "john"}))
+}
+
+func Test_Server_timezone(t *testing.T) {
+	ctx := context.Background()
+	timezone := time.FixedZone("UTC+2", 2*60*60)
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	srv, client := newTestServer(t, ServerOptionTimezone(timezone))
+	srv.SeedUser(User{Name: "john", Expiration: expiration})
+
+	status, err := client.SessionUser("john").Status(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, status.KeyExpiration.Equal(expiration))
+}
+
+func Test_Server_insecureProtocol(t *testing.T) {
+	_, client := newTestServer(t, ServerOptionInsecureProtocol())
+	assert.NilError(t, client.Ping(context.Background()))
+}
+
+func Test_Server_timezoneDetection(t *testing.T) {
+	ctx := context.Background()
+	timezone := time.FixedZone("UTC+5:30", 5*60*60+30*60)
+	key := newTestKey(t)
+
+	srv, err := NewServer(ServerOptionTimezone(timezone))
+	assert.NilError(t, err)
+	t.Cleanup(srv.Close)
+	srv.SeedUser(User{Name: "john", State: cassh.KeyStateActive, PublicKey: key, Principals: cassh.Principals{"john"}})
+
+	var logs bytes.Buffer
+	client, err := cassh.NewClient(srv.URL(),
+		cassh.ClientOptionHTTPClient(srv.HTTPClient()),
+		cassh.ClientOptionServerTimezone(time.UTC),
+		cassh.ClientOptionServerTimezoneDetection(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+	assert.NilError(t, err)
+
+	certificate, err := client.SessionUser("john").Key(key).Sign(ctx)
+	assert.NilError(t, err)
+
+	status, err := client.SessionUser("john").Status(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(status.KeyExpiration.Unix(), int64(certificate.ValidBefore)))
+
+	status, err = client.SessionAdmin().User("john").Status(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(status.KeyExpiration.Unix(), int64(certificate.ValidBefore)))
+
+	assert.Check(t, cmp.Contains(logs.String(), "configured_offset=UTC+00:00 observed_offset=UTC+05:30"))
+}
+
+func Test_Server_timezoneConfigured(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+
+	srv, err := NewServer(ServerOptionTimezone(time.FixedZone("UTC+5:30", 5*60*60+30*60)))
+	assert.NilError(t, err)
+	t.Cleanup(srv.Close)
+	srv.SeedUser(User{Name: "john", State: cassh.KeyStateActive, PublicKey: key, Principals: cassh.Principals{"john"}})
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	client, err := cassh.NewClient(srv.URL(),
+		cassh.ClientOptionHTTPClient(srv.HTTPClient()),
+		cassh.ClientOptionServerTimezone(time.UTC),
+	)
+	assert.NilError(t, err)
+
+	_, err = client.SessionUser("john").Key(key).Sign(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(logs.String(), "configured_offset=UTC+00:00 observed_offset=UTC+05:30 detection=false"))
+}
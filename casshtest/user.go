@@ -0,0 +1,107 @@
+package casshtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/krostar/cassh"
+)
+
+// User stores the state the server keeps for each of its users.
+type User struct {
+	Name       cassh.Username
+	RealName   string
+	State      cassh.KeyState
+	PublicKey  ssh.PublicKey
+	Principals cassh.Principals
+	// Expiry is the validity duration of signed certificates, server default is used if unset.
+	Expiry time.Duration
+	// Expiration is the expiration of the last signed certificate.
+	Expiration time.Time
+}
+
+func (u *User) clone() User {
+	clone := *u
+	clone.Principals = append(cassh.Principals(nil), u.Principals...)
+	return clone
+}
+
+type apiUserStatus struct {
+	Expiration string               `json:"expiration"`
+	Expiry     string               `json:"expiry"`
+	Principals []string             `json:"principals"`
+	RealName   string               `json:"realname"`
+	SSHKeyHash apiUserStatusKeyHash `json:"ssh_key_hash"`
+	Status     string               `json:"status"`
+	Username   string               `json:"username"`
+}
+
+type apiUserStatusKeyHash struct {
+	AuthType string `json:"auth_type"`
+	Bits     int    `json:"bits"`
+	Hash     string `json:"hash"`
+	Rate     string `json:"rate"`
+}
+
+func (u *User) apiStatus(timezone *time.Location, defaultExpiry time.Duration) apiUserStatus {
+	expiry := u.Expiry
+	if expiry == 0 {
+		expiry = defaultExpiry
+	}
+
+	status := apiUserStatus{
+		Expiration: u.Expiration.In(timezone).Format("2006-01-02 15:04:05"),
+		Expiry:     "+" + strconv.FormatInt(int64(expiry.Hours()), 10) + "h",
+		Principals: make([]string, len(u.Principals)),
+		RealName:   u.RealName,
+		Status:     u.State.String(),
+		Username:   u.Name.String(),
+	}
+
+	for i, principal := range u.Principals {
+		status.Principals[i] = principal.String()
+	}
+
+	if u.PublicKey != nil {
+		status.SSHKeyHash = apiKeyHash(u.PublicKey)
+	}
+
+	return status
+}
+
+func apiKeyHash(key ssh.PublicKey) apiUserStatusKeyHash {
+	hash := apiUserStatusKeyHash{
+		AuthType: strings.ToUpper(strings.TrimPrefix(key.Type(), "ssh-")),
+		Hash:     ssh.FingerprintSHA256(key),
+		Rate:     "HIGH",
+	}
+
+	var cryptoKey any
+	if cryptoPublicKey, ok := key.(ssh.CryptoPublicKey); ok {
+		cryptoKey = cryptoPublicKey.CryptoPublicKey()
+	}
+
+	switch k := cryptoKey.(type) {
+	case *rsa.PublicKey:
+		hash.AuthType = "RSA"
+		hash.Bits = k.N.BitLen()
+		switch {
+		case hash.Bits < 2048:
+			hash.Rate = "LOW"
+		case hash.Bits < 4096:
+			hash.Rate = "MEDIUM"
+		}
+	case *ecdsa.PublicKey:
+		hash.AuthType = "ECDSA"
+		hash.Bits = k.Curve.Params().BitSize
+	default:
+		hash.Bits = 256
+	}
+
+	return hash
+}
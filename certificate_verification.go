@@ -0,0 +1,102 @@
+package cassh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// CertificateVerificationError is returned when a certificate returned by the CASSH server does not pass verification.
+// It matches ErrCertificateVerification using errors.Is.
+type CertificateVerificationError struct {
+	Certificate *ssh.Certificate
+	Reason      string
+}
+
+// Error implements error for CertificateVerificationError.
+func (err *CertificateVerificationError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrCertificateVerification.Error(), err.Reason)
+}
+
+// Is returns true if target is ErrCertificateVerification.
+func (*CertificateVerificationError) Is(target error) bool {
+	return target == ErrCertificateVerification
+}
+
+// certificateExpectations stores what a certificate signed for a user key must look like.
+type certificateExpectations struct {
+	authorities authorityPins
+	key         ssh.PublicKey
+	username    Username
+	principals  Principals // not checked if nil
+	now         time.Time
+	clockSkew   time.Duration
+}
+
+func verifyCertificate(certificate *ssh.Certificate, expected certificateExpectations) error {
+	fail := func(format string, args ...any) error {
+		return &CertificateVerificationError{Certificate: certificate, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if certificate.CertType != ssh.UserCert {
+		return fail("certificate type is %d, expected a user certificate", certificate.CertType)
+	}
+
+	if !bytes.Equal(certificate.Key.Marshal(), expected.key.Marshal()) {
+		return fail("certificate is for key %s, expected %s",
+			ssh.FingerprintSHA256(certificate.Key), ssh.FingerprintSHA256(expected.key))
+	}
+
+	if !expected.authorities.match(certificate.SignatureKey) {
+		return fail("certificate is signed by unknown authority %s", ssh.FingerprintSHA256(certificate.SignatureKey))
+	}
+
+	if err := verifyCertificateSignature(certificate); err != nil {
+		return fail("invalid signature: %v", err)
+	}
+
+	if certificate.KeyId != expected.username.String() {
+		return fail("certificate key id is %q, expected %q", certificate.KeyId, expected.username)
+	}
+
+	skew := int64(expected.clockSkew / time.Second)
+
+	if validAfter := int64(certificate.ValidAfter); validAfter < 0 || validAfter > expected.now.Unix()+skew {
+		return fail("certificate is not valid before %s", time.Unix(validAfter, 0).UTC())
+	}
+
+	if validBefore := int64(certificate.ValidBefore); certificate.ValidBefore != ssh.CertTimeInfinity &&
+		(validBefore < 0 || validBefore <= expected.now.Unix()-skew) {
+		return fail("certificate expired at %s", time.Unix(validBefore, 0).UTC())
+	}
+
+	if expected.principals != nil {
+		if len(certificate.ValidPrincipals) == 0 {
+			return fail("certificate is valid for any principal")
+		}
+		for _, principal := range certificate.ValidPrincipals {
+			if err := expected.principals.Has(Principal(principal)); err != nil {
+				return fail("certificate principal %s is not a principal of the user", principal)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyCertificateSignature checks the certificate has been signed by its signature key.
+func verifyCertificateSignature(certificate *ssh.Certificate) error {
+	if certificate.Signature == nil {
+		return errors.New("certificate is not signed")
+	}
+
+	unsigned := *certificate
+	unsigned.Signature = nil
+	raw := unsigned.Marshal()
+
+	// the signed content is the marshaled certificate without the trailing signature length
+	return certificate.SignatureKey.Verify(raw[:len(raw)-4], certificate.Signature)
+}
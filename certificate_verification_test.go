@@ -0,0 +1,214 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func newVerificationTestSigner(t *testing.T) ssh.Signer {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	assert.NilError(t, err)
+	return signer
+}
+
+func newVerificationTestCertificate(t *testing.T, authority ssh.Signer, key ssh.PublicKey, now time.Time, setups ...func(*ssh.Certificate)) *ssh.Certificate {
+	certificate := &ssh.Certificate{
+		Key:             key,
+		KeyId:           "john",
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"john", "admin"},
+		ValidAfter:      uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(time.Hour).Unix()),
+	}
+	for _, setup := range setups {
+		setup(certificate)
+	}
+	assert.NilError(t, certificate.SignCert(rand.Reader, authority))
+	return certificate
+}
+
+func Test_CertificateVerificationError(t *testing.T) {
+	var err error = &CertificateVerificationError{Reason: "boom"}
+	assert.Check(t, cmp.Error(err, "certificate verification failed: boom"))
+	assert.Check(t, errors.Is(err, ErrCertificateVerification))
+	assert.Check(t, !errors.Is(err, ErrServerFailure))
+}
+
+func Test_verifyCertificate(t *testing.T) {
+	now := time.Now()
+	authority := newVerificationTestSigner(t)
+	key := newVerificationTestSigner(t).PublicKey()
+
+	expected := certificateExpectations{
+		authorities: authorityPins{keys: []ssh.PublicKey{newVerificationTestSigner(t).PublicKey(), authority.PublicKey()}},
+		key:         key,
+		username:    "john",
+		principals:  Principals{"john", "admin", "other"},
+		now:         now,
+		clockSkew:   5 * time.Minute,
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Check(t, verifyCertificate(newVerificationTestCertificate(t, authority, key, now), expected))
+		assert.Check(t, verifyCertificate(newVerificationTestCertificate(t, authority, key, now, func(c *ssh.Certificate) {
+			c.ValidAfter = uint64(now.Add(4 * time.Minute).Unix())
+		}), expected), "certificate valid in the future within the clock skew")
+		assert.Check(t, verifyCertificate(newVerificationTestCertificate(t, authority, key, now, func(c *ssh.Certificate) {
+			c.ValidAfter = 0
+			c.ValidBefore = ssh.CertTimeInfinity
+			c.CriticalOptions = map[string]string{"force-command": "/bin/true"}
+		}), expected))
+
+		withoutPrincipals := expected
+		withoutPrincipals.principals = nil
+		assert.Check(t, verifyCertificate(newVerificationTestCertificate(t, authority, key, now, func(c *ssh.Certificate) {
+			c.ValidPrincipals = nil
+		}), withoutPrincipals))
+	})
+
+	t.Run("ko", func(t *testing.T) {
+		tampered := newVerificationTestCertificate(t, authority, key, now)
+		tampered.ValidPrincipals = []string{"john"}
+
+		impostor := newVerificationTestSigner(t)
+		impostorCertificate := newVerificationTestCertificate(t, impostor, key, now)
+		impostorCertificate.SignatureKey = authority.PublicKey()
+
+		for name, test := range map[string]struct {
+			certificate *ssh.Certificate
+			expectedErr string
+		}{
+			"host certificate": {
+				certificate: newVerificationTestCertificate(t, authority, key, now, func(c *ssh.Certificate) { c.CertType = ssh.HostCert }),
+				expectedErr: "expected a user certificate",
+			},
+			"other key": {
+				certificate: newVerificationTestCertificate(t, authority, authority.PublicKey(), now),
+				expectedErr: "certificate is for key " + ssh.FingerprintSHA256(authority.PublicKey()),
+			},
+			"unknown authority": {
+				certificate: newVerificationTestCertificate(t, impostor, key, now),
+				expectedErr: "certificate is signed by unknown authority",
+			},
+			"signature from another authority": {
+				certificate: impostorCertificate,
+				expectedErr: "invalid signature",
+			},
+			"tampered certificate": {
+				certificate: tampered,
+				expectedErr: "invalid signature",
+			},
+			"key id": {
+				certificate: newVerificationTestCertificate(t, authority, key, now, func(c *ssh.Certificate) { c.KeyId = "jane" }),
+				expectedErr: `certificate key id is "jane", expected "john"`,
+			},
+			"not yet valid": {
+				certificate: newVerificationTestCertificate(t, authority, key, now, func(c *ssh.Certificate) {
+					c.ValidAfter = uint64(now.Add(10 * time.Minute).Unix())
+				}),
+				expectedErr: "certificate is not valid before",
+			},
+			"expired": {
+				certificate: newVerificationTestCertificate(t, authority, key, now, func(c *ssh.Certificate) {
+					c.ValidBefore = uint64(now.Add(-10 * time.Minute).Unix())
+				}),
+				expectedErr: "certificate expired at",
+			},
+			"any principal": {
+				certificate: newVerificationTestCertificate(t, authority, key, now, func(c *ssh.Certificate) { c.ValidPrincipals = nil }),
+				expectedErr: "certificate is valid for any principal",
+			},
+			"unknown principal": {
+				certificate: newVerificationTestCertificate(t, authority, key, now, func(c *ssh.Certificate) { c.ValidPrincipals = []string{"root"} }),
+				expectedErr: "certificate principal root is not a principal of the user",
+			},
+		} {
+			err := verifyCertificate(test.certificate, expected)
+			assert.Check(t, cmp.ErrorIs(err, ErrCertificateVerification), name)
+			assert.Check(t, cmp.ErrorContains(err, test.expectedErr), name)
+		}
+	})
+}
+
+type verificationTestDoer struct {
+	t          *testing.T
+	authority  ssh.Signer
+	key        ssh.PublicKey
+	principals []string
+
+	authorityRequests int
+	statusRequests    int
+}
+
+func (d *verificationTestDoer) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	switch req.URL.Path {
+	case "/ca":
+		d.authorityRequests++
+		body = ssh.MarshalAuthorizedKey(d.authority.PublicKey())
+	case "/client/status":
+		d.statusRequests++
+		body = []byte(`{"username":"john","realname":"john","status":"ACTIVE","expiration":"2030-01-01 00:00:00","principals":["john","admin"]}`)
+	default:
+		body = ssh.MarshalAuthorizedKey(newVerificationTestCertificate(d.t, d.authority, d.key, time.Now(), func(c *ssh.Certificate) {
+			c.ValidPrincipals = d.principals
+		}))
+	}
+
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func Test_SessionUserKey_verifyCertificate(t *testing.T) {
+	ctx := context.Background()
+	key := newVerificationTestSigner(t).PublicKey()
+	doer := &verificationTestDoer{t: t, authority: newVerificationTestSigner(t), key: key, principals: []string{"john"}}
+
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+	session := client.SessionUser("john").Key(key)
+
+	_, err = session.Sign(ctx)
+	assert.NilError(t, err)
+	_, err = session.Sign(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(doer.authorityRequests, 1), "authority must be cached")
+
+	doer.authority = newVerificationTestSigner(t)
+	_, err = session.Sign(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(doer.authorityRequests, 2), "authority must be fetched again after a rotation")
+
+	_, err = session.Sign(ctx, SessionUserKeySignOptionAuthority(newVerificationTestSigner(t).PublicKey()))
+	assert.Check(t, cmp.ErrorIs(err, ErrCertificateVerification))
+	assert.Check(t, cmp.Equal(doer.authorityRequests, 2), "pinned authority must not be fetched")
+
+	_, err = session.Sign(ctx, SessionUserKeySignOptionVerifyPrincipals())
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(doer.statusRequests, 1))
+
+	doer.principals = []string{"root"}
+	_, err = session.Sign(ctx, SessionUserKeySignOptionVerifyPrincipals())
+	assert.Check(t, cmp.ErrorContains(err, "certificate principal root is not a principal of the user"))
+
+	_, err = session.Sign(ctx, SessionUserKeySignOptionSkipVerification(), SessionUserKeySignOptionVerifyPrincipals())
+	assert.NilError(t, err)
+}
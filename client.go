@@ -6,9 +6,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"time"
+	"sync"
 
 	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/krostar/httpclient"
 	"github.com/krostar/sshx"
@@ -17,42 +18,89 @@ import (
 // Client stores useful attributes to talk to the CASSH server.
 type Client struct {
 	api            *httpclient.API
-	serverTimezone *time.Location
+	serverTimezone *serverTimezone
+	serverVersion  *serverVersion
+	serverFeatures *serverFeatures
+	authorityPins  authorityPins
+	endpoints      *endpointsDoer
+
+	allowUnsignedKRL bool
+
+	authorityM   sync.Mutex
+	authorityKey ssh.PublicKey
 }
 
 // NewClient creates a new CASSH client to be used to contact the server.
 // Warning: server send time without timezone so some tweaking may be needed to interpret the right time if server and client timezone are not configured the same.
-// By default, time is interpreted with UTC timezone, to change it provide the appropriate timezone using ClientOptionServerTimezone.
+// By default, time is interpreted with UTC timezone, to change it provide the appropriate timezone using ClientOptionServerTimezone,
+// or let the client detect it using ClientOptionServerTimezoneDetection.
+// Replicas of the server can be provided using ClientOptionEndpoints.
 func NewClient(serverAddress string, opts ...ClientOption) (*Client, error) {
 	o := clientOptionsDefaults()
 	for _, opt := range opts {
 		opt(o)
 	}
 
-	serverAddressURL, err := url.Parse(serverAddress)
+	endpointURLs := make([]url.URL, 0, 1+len(o.endpoints))
+	for _, address := range append([]string{serverAddress}, o.endpoints...) {
+		addressURL, err := url.Parse(address)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse server address: %v", err)
+		}
+
+		if addressURL.Scheme != "https" && !o.tolerateInsecureProtocol {
+			return nil, fmt.Errorf("insecure protocol used: %s", address)
+		}
+
+		endpointURLs = append(endpointURLs, *addressURL)
+	}
+
+	pins, err := parseAuthorityPins(o.pinnedAuthorities)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse server address: %v", err)
+		return nil, err
 	}
 
-	if serverAddressURL.Scheme != "https" && !o.tolerateInsecureProtocol {
-		return nil, fmt.Errorf("insecure protocol used: %s", serverAddress)
+	httpDoer := o.httpDoer
+	if o.tls != nil {
+		if httpDoer, err = withTLS(httpDoer, o.tls); err != nil {
+			return nil, err
+		}
+	}
+
+	endpoints := newEndpointsDoer(httpDoer, endpointURLs[0], endpointURLs, o)
+
+	var doer httpclient.Doer = endpoints
+	if o.retryPolicy != nil {
+		doer = &retryDoer{doer: doer, o: o.retryPolicy}
+	}
+	if o.instrumentation != nil {
+		if doer, err = newInstrumentedDoer(doer, o.instrumentation); err != nil {
+			return nil, fmt.Errorf("unable to create instruments: %v", err)
+		}
 	}
 
 	api := httpclient.
-		NewAPI(o.httpDoer, *serverAddressURL).
+		NewAPI(apiErrorDoer{doer: doer}, endpointURLs[0]).
 		WithRequestHeaders(o.httpDefaultHeaders).
-		WithResponseHandler(http.StatusOK, func(*http.Response) error { return nil }).
-		WithResponseHandler(http.StatusUnauthorized, func(*http.Response) error { return ErrInsufficientPrivileges })
+		WithResponseHandler(http.StatusOK, func(*http.Response) error { return nil })
 
-	return &Client{
+	c := &Client{
 		api:            api,
-		serverTimezone: o.serverTimezone,
-	}, nil
+		serverTimezone: newServerTimezone(o),
+		authorityPins:  pins,
+		endpoints:      endpoints,
+		serverFeatures: newServerFeatures(),
+
+		allowUnsignedKRL: o.allowUnsignedKRL,
+	}
+	c.serverVersion = &serverVersion{health: c.Health}
+
+	return c, nil
 }
 
 // Ping checks whenever the server respond a 200 to /ping.
 func (c *Client) Ping(ctx context.Context) error {
-	return c.api.Execute(ctx, c.api.Get("/ping"))
+	return c.api.Execute(withOperation(ctx, operationPing), c.api.Get("/ping"))
 }
 
 // Health returns the name and version of the /health endpoint.
@@ -60,7 +108,7 @@ func (c *Client) Health(ctx context.Context) (string, string, error) {
 	var response apiHealthResponse
 
 	if err := c.api.
-		Do(ctx, c.api.Get("/health")).
+		Do(withOperation(ctx, operationHealth), c.api.Get("/health")).
 		ReceiveJSON(http.StatusOK, &response).
 		Error(); err != nil {
 		return "", "", err
@@ -69,12 +117,34 @@ func (c *Client) Health(ctx context.Context) (string, string, error) {
 	return response.Name, response.Version, nil
 }
 
+// ServerVersion returns the name and version of the CASSH server, negotiated once using Health and cached afterward.
+// Servers not exposing the /health endpoint, like older releases, have an empty name and version.
+// Once negotiated, the responses of the server are decoded according to its version; until then,
+// the formats of all releases are accepted.
+func (c *Client) ServerVersion(ctx context.Context) (string, string, error) {
+	return c.serverVersion.get(ctx)
+}
+
+// krlMaxSize is the maximum size of the downloaded key revocation lists, far above the lists of large organizations.
+const krlMaxSize = 64 << 20
+
 // KeyRevocationList return the list of keys revoked by the CASSH server.
 func (c *Client) KeyRevocationList(ctx context.Context) (*krl.KRL, error) {
-	var list *krl.KRL
+	list, _, err := c.RawKeyRevocationList(ctx)
+	return list, err
+}
+
+// RawKeyRevocationList return the list of keys revoked by the CASSH server, and the list as sent by the server.
+// Writing the raw list, instead of marshaling the parsed one, keeps its signatures and version untouched.
+func (c *Client) RawKeyRevocationList(ctx context.Context) (*krl.KRL, []byte, error) {
+	var (
+		list *krl.KRL
+		raw  []byte
+	)
 
 	if err := c.api.
-		Do(ctx, c.api.Get("/krl")).
+		Do(withOperation(ctx, operationKRL), c.api.Get("/krl")).
+		BodySizeReadLimit(krlMaxSize).
 		OnStatus(http.StatusOK,
 			func(resp *http.Response) error {
 				body, err := io.ReadAll(resp.Body)
@@ -86,22 +156,52 @@ func (c *Client) KeyRevocationList(ctx context.Context) (*krl.KRL, error) {
 				if err != nil {
 					return fmt.Errorf("unable to parse body as krl: %v", err)
 				}
+				raw = body
 
 				return nil
 			},
 		).Error(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return list, nil
+	if err := c.checkKeyRevocationListSigners(list); err != nil {
+		return nil, nil, err
+	}
+
+	return list, raw, nil
+}
+
+// checkKeyRevocationListSigners returns ErrAuthorityMismatch if authorities are pinned
+// and the provided list has not been signed by one of them. Unsigned lists are only accepted
+// with ClientOptionAllowUnsignedKRL.
+func (c *Client) checkKeyRevocationListSigners(list *krl.KRL) error {
+	if c.authorityPins.empty() {
+		return nil
+	}
+
+	if len(list.SigningKeys) == 0 {
+		if c.allowUnsignedKRL {
+			return nil
+		}
+		return fmt.Errorf("%w: key revocation list is not signed", ErrAuthorityMismatch)
+	}
+
+	for _, signingKey := range list.SigningKeys {
+		if c.authorityPins.match(signingKey) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: key revocation list is not signed by a pinned authority", ErrAuthorityMismatch)
 }
 
 // AuthorityPublicKey return the CASSH server public key of the key used to sign certificate.
+// If authorities are pinned, ErrAuthorityMismatch is returned when the key is not one of them.
 func (c *Client) AuthorityPublicKey(ctx context.Context) (sshx.PublicKey, error) {
 	var authorityPublicKey sshx.PublicKey
 
 	if err := c.api.
-		Do(ctx, c.api.Get("/ca")).
+		Do(withOperation(ctx, operationAuthority), c.api.Get("/ca")).
 		OnStatus(http.StatusOK,
 			func(resp *http.Response) error {
 				body, err := io.ReadAll(resp.Body)
@@ -121,5 +221,31 @@ func (c *Client) AuthorityPublicKey(ctx context.Context) (sshx.PublicKey, error)
 		return nil, err
 	}
 
+	if err := c.authorityPins.checkAuthority(authorityPublicKey); err != nil {
+		return nil, err
+	}
+
 	return authorityPublicKey, nil
 }
+
+// certificateAuthorities returns the authorities a certificate signed by signatureKey must be verified against.
+// Pinned authorities are used if any, otherwise the authority public key is fetched on first call and cached;
+// it is fetched again if signatureKey does not match it, in case it has been rotated.
+func (c *Client) certificateAuthorities(ctx context.Context, signatureKey ssh.PublicKey) (authorityPins, error) {
+	if !c.authorityPins.empty() {
+		return c.authorityPins, nil
+	}
+
+	c.authorityM.Lock()
+	defer c.authorityM.Unlock()
+
+	if c.authorityKey == nil || !(authorityPins{keys: []ssh.PublicKey{c.authorityKey}}).match(signatureKey) {
+		authorityKey, err := c.AuthorityPublicKey(ctx)
+		if err != nil {
+			return authorityPins{}, fmt.Errorf("unable to get authority public key: %w", err)
+		}
+		c.authorityKey = authorityKey
+	}
+
+	return authorityPins{keys: []ssh.PublicKey{c.authorityKey}}, nil
+}
@@ -2,6 +2,7 @@ package cassh
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
 	"runtime"
 	"time"
@@ -14,9 +15,24 @@ type ClientOption func(o *clientOptions)
 
 type clientOptions struct {
 	serverTimezone           *time.Location
+	serverTimezoneConfigured bool
+	serverTimezoneDetection  bool
+	serverTimezoneLogger     *slog.Logger
 	httpDoer                 httpclient.Doer
 	httpDefaultHeaders       http.Header
 	tolerateInsecureProtocol bool
+	pinnedAuthorities        []string
+	allowUnsignedKRL         bool
+	retryPolicy              *retryPolicyOptions
+	instrumentation          *instrumentationOptions
+	tls                      *tlsOptions
+
+	endpoints                    []string
+	endpointsStrategy            EndpointStrategy
+	endpointsHealthCheckInterval time.Duration
+	endpointsHealthCheckTimeout  time.Duration
+
+	now func() time.Time
 }
 
 func clientOptionsDefaults() *clientOptions {
@@ -31,6 +47,12 @@ func clientOptionsDefaults() *clientOptions {
 		httpDefaultHeaders:       defaultHeaders,
 		serverTimezone:           time.UTC,
 		tolerateInsecureProtocol: false,
+
+		endpointsStrategy:            EndpointStrategyPriority,
+		endpointsHealthCheckInterval: 30 * time.Second,
+		endpointsHealthCheckTimeout:  2 * time.Second,
+
+		now: time.Now,
 	}
 }
 
@@ -38,10 +60,23 @@ func clientOptionsDefaults() *clientOptions {
 func ClientOptionServerTimezone(serverTimezone *time.Location) ClientOption {
 	return func(o *clientOptions) {
 		o.serverTimezone = serverTimezone
+		o.serverTimezoneConfigured = true
+	}
+}
+
+// ClientOptionServerTimezoneDetection derives the offset of the CASSH server timezone instead of relying on ClientOptionServerTimezone,
+// by comparing the expiration of the first certificate signed with the expiration returned by the server afterward.
+// The offset is cached for the lifetime of the client; until then, the configured timezone is used.
+// A warning is logged with the provided logger, or slog.Default() if nil, when the offset disagrees with the configured timezone.
+func ClientOptionServerTimezoneDetection(logger *slog.Logger) ClientOption {
+	return func(o *clientOptions) {
+		o.serverTimezoneDetection = true
+		o.serverTimezoneLogger = logger
 	}
 }
 
 // ClientOptionHTTPClient sets the http client used on each request made to the CASSH server.
+// To log or dump requests, wrap the inspecting doer with DoerWrapRedacted to keep secrets out of it.
 func ClientOptionHTTPClient(httpDoer httpclient.Doer) ClientOption {
 	return func(o *clientOptions) {
 		o.httpDoer = httpDoer
@@ -61,3 +96,88 @@ func ClientOptionTolerateInsecureProtocols() ClientOption {
 		o.tolerateInsecureProtocol = true
 	}
 }
+
+// ClientOptionPinnedAuthority sets the authorities the CASSH server is expected to use,
+// formatted either as authorized keys ("ssh-ed25519 AAAA...") or as SHA256 fingerprints ("SHA256:...").
+// Several authorities can be provided to handle rotations.
+// Authority public keys not matching any of them are rejected with ErrAuthorityMismatch,
+// and certificates and key revocation lists must be signed by one of them.
+func ClientOptionPinnedAuthority(authorities ...string) ClientOption {
+	return func(o *clientOptions) {
+		o.pinnedAuthorities = append(o.pinnedAuthorities, authorities...)
+	}
+}
+
+// ClientOptionAllowUnsignedKRL accepts key revocation lists without signature when authorities are pinned,
+// for CASSH servers not signing their list. Signed lists must still be signed by a pinned authority.
+func ClientOptionAllowUnsignedKRL() ClientOption {
+	return func(o *clientOptions) {
+		o.allowUnsignedKRL = true
+	}
+}
+
+// ClientOptionRetryPolicy retries requests of idempotent calls (Ping, Health, AuthorityPublicKey, KeyRevocationList,
+// and SessionUser.Status) failing because of the network, a 5xx, or a 429 response, with an exponential backoff.
+// Retry-After headers are honored, and retries never outlast the context deadline.
+// Sign requests are only retried when RetryPolicyOptionRetrySign is used.
+func ClientOptionRetryPolicy(opts ...RetryPolicyOption) ClientOption {
+	return func(o *clientOptions) {
+		o.retryPolicy = retryPolicyOptionsDefaults()
+		for _, opt := range opts {
+			opt(o.retryPolicy)
+		}
+	}
+}
+
+// ClientOptionEndpoints sets other addresses of the CASSH server, for instance replicas in other regions.
+// Requests are sent to one of the endpoints, including the address provided to NewClient,
+// according to the strategy set by ClientOptionEndpointStrategy; they fail over to the others
+// when the endpoint cannot be reached or answers a 5xx. Non-idempotent requests, like Sign,
+// only fail over when the endpoint cannot be reached.
+func ClientOptionEndpoints(addresses ...string) ClientOption {
+	return func(o *clientOptions) {
+		o.endpoints = append(o.endpoints, addresses...)
+	}
+}
+
+// ClientOptionEndpointStrategy sets how the endpoint serving a request is selected. It defaults to EndpointStrategyPriority.
+func ClientOptionEndpointStrategy(strategy EndpointStrategy) ClientOption {
+	return func(o *clientOptions) {
+		o.endpointsStrategy = strategy
+	}
+}
+
+// ClientOptionEndpointsHealthCheck sets how often, and with which timeout, endpoints are checked using /ping
+// when several endpoints are used. Checks are started in the background by a request when the last one is older than
+// the interval, requests using the last known health of the endpoints; a zero interval disables them. It defaults to every 30s, with a 2s timeout.
+func ClientOptionEndpointsHealthCheck(interval, timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.endpointsHealthCheckInterval = interval
+		o.endpointsHealthCheckTimeout = timeout
+	}
+}
+
+// ClientOptionTLS configures the TLS connections made to the CASSH server, for instance to present a client certificate
+// to servers deployed behind a reverse proxy requiring mutual TLS, or to trust a private certificate authority.
+// The transport of the http client set with ClientOptionHTTPClient, which must be an *http.Client using an *http.Transport,
+// possibly wrapped with DoerWrapRedacted, is copied to apply the options on top of its TLS configuration.
+func ClientOptionTLS(opts ...TLSOption) ClientOption {
+	return func(o *clientOptions) {
+		o.tls = tlsOptionsDefaults()
+		for _, opt := range opts {
+			opt(o.tls)
+		}
+	}
+}
+
+// ClientOptionInstrumentation logs each call made to the CASSH server using log/slog, and creates OpenTelemetry spans
+// named after the operation (like "cassh.user.key.sign") and metrics for them.
+// Sensitive request parameters, like passwords and public keys, are redacted from logs (see IsSensitiveRequestParameter).
+func ClientOptionInstrumentation(opts ...InstrumentationOption) ClientOption {
+	return func(o *clientOptions) {
+		o.instrumentation = instrumentationOptionsDefaults()
+		for _, opt := range opts {
+			opt(o.instrumentation)
+		}
+	}
+}
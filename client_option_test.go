@@ -1,6 +1,9 @@
 package cassh
 
 import (
+	"crypto/tls"
+	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"testing"
@@ -23,6 +26,18 @@ func Test_ClientOptionServerTimezone(t *testing.T) {
 	opts.serverTimezone = nil
 	ClientOptionServerTimezone(time.UTC)(opts)
 	assert.Check(t, opts.serverTimezone != nil)
+	assert.Check(t, opts.serverTimezoneConfigured)
+}
+
+func Test_ClientOptionServerTimezoneDetection(t *testing.T) {
+	opts := clientOptionsDefaults()
+	assert.Check(t, !opts.serverTimezoneDetection)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ClientOptionServerTimezoneDetection(logger)(opts)
+	assert.Check(t, opts.serverTimezoneDetection)
+	assert.Check(t, opts.serverTimezoneLogger == logger)
+	assert.Check(t, !opts.serverTimezoneConfigured)
 }
 
 func Test_ClientOptionHTTPDoer(t *testing.T) {
@@ -47,3 +62,65 @@ func Test_ClientOptionTolerateInsecureProtocols(t *testing.T) {
 	ClientOptionTolerateInsecureProtocols()(opts)
 	assert.Check(t, opts.tolerateInsecureProtocol)
 }
+
+func Test_ClientOptionPinnedAuthority(t *testing.T) {
+	opts := clientOptionsDefaults()
+	assert.Check(t, len(opts.pinnedAuthorities) == 0)
+	ClientOptionPinnedAuthority("SHA256:abc")(opts)
+	ClientOptionPinnedAuthority("SHA256:def")(opts)
+	assert.Check(t, len(opts.pinnedAuthorities) == 2)
+}
+
+func Test_ClientOptionAllowUnsignedKRL(t *testing.T) {
+	opts := clientOptionsDefaults()
+	assert.Check(t, !opts.allowUnsignedKRL)
+	ClientOptionAllowUnsignedKRL()(opts)
+	assert.Check(t, opts.allowUnsignedKRL)
+}
+
+func Test_ClientOptionRetryPolicy(t *testing.T) {
+	opts := clientOptionsDefaults()
+	assert.Check(t, opts.retryPolicy == nil)
+	ClientOptionRetryPolicy(RetryPolicyOptionMaxAttempts(2))(opts)
+	assert.Assert(t, opts.retryPolicy != nil)
+	assert.Check(t, opts.retryPolicy.maxAttempts == 2)
+}
+
+func Test_ClientOptionEndpoints(t *testing.T) {
+	opts := clientOptionsDefaults()
+	assert.Check(t, len(opts.endpoints) == 0)
+	ClientOptionEndpoints("https://a.local")(opts)
+	ClientOptionEndpoints("https://b.local", "https://c.local")(opts)
+	assert.Check(t, len(opts.endpoints) == 3)
+}
+
+func Test_ClientOptionEndpointStrategy(t *testing.T) {
+	opts := clientOptionsDefaults()
+	assert.Check(t, opts.endpointsStrategy == EndpointStrategyPriority)
+	ClientOptionEndpointStrategy(EndpointStrategyLowestLatency)(opts)
+	assert.Check(t, opts.endpointsStrategy == EndpointStrategyLowestLatency)
+}
+
+func Test_ClientOptionEndpointsHealthCheck(t *testing.T) {
+	opts := clientOptionsDefaults()
+	assert.Check(t, opts.endpointsHealthCheckInterval == 30*time.Second)
+	assert.Check(t, opts.endpointsHealthCheckTimeout == 2*time.Second)
+	ClientOptionEndpointsHealthCheck(time.Minute, time.Second)(opts)
+	assert.Check(t, opts.endpointsHealthCheckInterval == time.Minute)
+	assert.Check(t, opts.endpointsHealthCheckTimeout == time.Second)
+}
+
+func Test_ClientOptionInstrumentation(t *testing.T) {
+	opts := clientOptionsDefaults()
+	assert.Check(t, opts.instrumentation == nil)
+	ClientOptionInstrumentation()(opts)
+	assert.Check(t, opts.instrumentation != nil)
+}
+
+func Test_ClientOptionTLS(t *testing.T) {
+	opts := clientOptionsDefaults()
+	assert.Check(t, opts.tls == nil)
+	ClientOptionTLS(TLSOptionMinVersion(tls.VersionTLS13))(opts)
+	assert.Assert(t, opts.tls != nil)
+	assert.Check(t, opts.tls.minVersion == tls.VersionTLS13)
+}
@@ -95,7 +95,7 @@ func Test_Client_Ping(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -151,7 +151,7 @@ func Test_Client_Health(t *testing.T) {
 				return nil
 			},
 			check: func(_, _ string, err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -223,7 +223,7 @@ func Test_Client_KeyRevocationList(t *testing.T) {
 				return nil
 			},
 			check: func(_ *krl.KRL, err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -301,7 +301,7 @@ func Test_Client_AuthorityPublicKey(t *testing.T) {
 				return nil
 			},
 			check: func(_ sshx.PublicKey, err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
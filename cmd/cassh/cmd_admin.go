@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/krostar/cassh"
+)
+
+func runAdmin(ctx context.Context, env *environment, args []string) error {
+	flags := newCommandFlags(env, "admin")
+	flags.Usage = func() {
+		fmt.Fprintf(env.stderr, `Usage: cassh admin <action> <username> [args...]
+
+Actions:
+  status <username>                                 show the user status
+  activate <username>                               activate the user key
+  revoke <username>                                 revoke the user key
+  delete <username>                                 delete the user key
+  expiry <username> <duration>                      set the validity duration of the user certificates
+  principals <username> add|remove|set <principal>  manage the user principals
+  principals <username> reset                       remove all the user principals
+  pending                                           list the user keys waiting for an admin approval
+  pending approve <username> [-expiry <duration>] [-principals <principal,...>]
+                                                    set the user expiry and principals, then activate its key
+  pending reject <username>                         delete the pending user key
+`)
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.Arg(0) == "pending" {
+		return runAdminPending(ctx, env, flags.Args()[1:])
+	}
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return errors.New("action and username are required")
+	}
+
+	cfg, client, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	action, user, actionArgs := flags.Arg(0), cfg.NewSessionAdmin(client).User(cassh.Username(flags.Arg(1))), flags.Args()[2:]
+
+	switch action {
+	case "status":
+		negotiateServerVersion(ctx, client)
+		status, err := user.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to get user status: %w", err)
+		}
+		printUserStatus(env, status)
+		return nil
+	case "activate":
+		err = user.Key().Activate(ctx)
+	case "revoke":
+		err = user.Key().Revoke(ctx)
+	case "delete":
+		err = user.Key().Delete(ctx)
+	case "expiry":
+		err = runAdminExpiry(ctx, user, actionArgs)
+	case "principals":
+		err = runAdminPrincipals(ctx, user, actionArgs)
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown admin action %q", action)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to %s user %s: %w", action, flags.Arg(1), err)
+	}
+
+	fmt.Fprintln(env.stdout, "done")
+	return nil
+}
+
+func runAdminExpiry(ctx context.Context, user *cassh.SessionAdminUser, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expiry requires a single duration argument")
+	}
+
+	expiry, err := time.ParseDuration(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to parse expiry: %v", err)
+	}
+
+	return user.Key().SetExpiry(ctx, expiry)
+}
+
+func runAdminPrincipals(ctx context.Context, user *cassh.SessionAdminUser, args []string) error {
+	if len(args) == 0 {
+		return errors.New("principals requires an operation")
+	}
+
+	operation, args := args[0], args[1:]
+	if operation == "reset" {
+		return user.Principals().Reset(ctx)
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("principals %s requires at least one principal", operation)
+	}
+
+	principals := make([]cassh.Principal, len(args))
+	for i, arg := range args {
+		principals[i] = cassh.Principal(arg)
+	}
+
+	switch operation {
+	case "add":
+		return user.Principals().Add(ctx, principals[0], principals[1:]...)
+	case "remove":
+		return user.Principals().Remove(ctx, principals[0], principals[1:]...)
+	case "set":
+		return user.Principals().Set(ctx, principals[0], principals[1:]...)
+	default:
+		return fmt.Errorf("unknown principals operation %q", operation)
+	}
+}
+
+func runAdminPending(ctx context.Context, env *environment, args []string) error {
+	cfg, client, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	session := cfg.NewSessionAdmin(client)
+
+	if len(args) == 0 {
+		negotiateServerVersion(ctx, client)
+		users, err := session.PendingUsers(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list pending users: %w", err)
+		}
+
+		if len(users) == 0 {
+			fmt.Fprintln(env.stdout, "no pending keys")
+		}
+		for i := range users {
+			printUserStatus(env, &users[i])
+		}
+		return nil
+	}
+
+	if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+		return fmt.Errorf("pending %s requires a username", args[0])
+	}
+
+	action, user := args[0], session.User(cassh.Username(args[1]))
+
+	var result *cassh.ApprovalResult
+	switch action {
+	case "approve":
+		var opts []cassh.SessionAdminUserApproveOption
+		if opts, err = parseAdminApproveOptions(env, args[2:]); err != nil {
+			return err
+		}
+		result, err = user.Approve(ctx, opts...)
+	case "reject":
+		if len(args) > 2 {
+			return errors.New("pending reject requires a single username")
+		}
+		result, err = user.Reject(ctx)
+	default:
+		return fmt.Errorf("unknown pending operation %q", action)
+	}
+
+	printApprovalResult(env, result)
+
+	if err != nil {
+		return fmt.Errorf("unable to %s user %s: %w", action, args[1], err)
+	}
+
+	fmt.Fprintln(env.stdout, "done")
+	return nil
+}
+
+func parseAdminApproveOptions(env *environment, args []string) ([]cassh.SessionAdminUserApproveOption, error) {
+	flags := newCommandFlags(env, "admin pending approve")
+	expiry := flags.Duration("expiry", 0, "validity duration of the user certificates")
+	principals := flags.String("principals", "", "comma-separated list of principals replacing the user ones")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+
+	var opts []cassh.SessionAdminUserApproveOption
+	if *expiry != 0 {
+		opts = append(opts, cassh.SessionAdminUserApproveOptionExpiry(*expiry))
+	}
+
+	var list []cassh.Principal
+	for _, principal := range strings.Split(*principals, ",") {
+		if principal = strings.TrimSpace(principal); principal != "" {
+			list = append(list, cassh.Principal(principal))
+		}
+	}
+	if len(list) > 0 {
+		opts = append(opts, cassh.SessionAdminUserApproveOptionPrincipals(list[0], list[1:]...))
+	}
+
+	return opts, nil
+}
+
+// printApprovalResult prints the outcome of each action made while approving or rejecting a key.
+func printApprovalResult(env *environment, result *cassh.ApprovalResult) {
+	for _, step := range result.Steps {
+		if step.Err != nil {
+			fmt.Fprintf(env.stdout, "  %s: failed: %v\n", step.Action, step.Err)
+		} else {
+			fmt.Fprintf(env.stdout, "  %s: ok\n", step.Action)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/krostar/cassh"
+	"github.com/krostar/cassh/casshtest"
+)
+
+func Test_runAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	env.srv.SeedUser(casshtest.User{Name: "jane", PublicKey: env.publicKey, Principals: cassh.Principals{"jane"}})
+
+	getUser := func() casshtest.User {
+		user, exists := env.srv.User("jane")
+		assert.Assert(t, exists)
+		return user
+	}
+
+	stdout, _, err := env.run(t, "admin", "status", "jane")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "[PENDING] jane"))
+
+	_, _, err = env.run(t, "admin", "activate", "jane")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(getUser().State, cassh.KeyStateActive))
+
+	_, _, err = env.run(t, "admin", "expiry", "jane", "12h")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(getUser().Expiry, 12*time.Hour))
+
+	_, _, err = env.run(t, "admin", "principals", "jane", "add", "root", "admin")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(getUser().Principals, cassh.Principals{"jane", "root", "admin"}))
+
+	_, _, err = env.run(t, "admin", "principals", "jane", "remove", "root")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(getUser().Principals, cassh.Principals{"jane", "admin"}))
+
+	_, _, err = env.run(t, "admin", "principals", "jane", "set", "web")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(getUser().Principals, cassh.Principals{"web"}))
+
+	_, _, err = env.run(t, "admin", "principals", "jane", "reset")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(getUser().Principals, 0))
+
+	_, _, err = env.run(t, "admin", "revoke", "jane")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(getUser().State, cassh.KeyStateRevoked))
+
+	_, _, err = env.run(t, "admin", "delete", "jane")
+	assert.NilError(t, err)
+	_, exists := env.srv.User("jane")
+	assert.Check(t, !exists)
+
+	t.Run("ko", func(t *testing.T) {
+		for name, test := range map[string]struct {
+			args        []string
+			expectedErr string
+		}{
+			"missing username":           {args: []string{"status"}, expectedErr: "action and username are required"},
+			"unknown action":             {args: []string{"foo", "jane"}, expectedErr: `unknown admin action "foo"`},
+			"unknown user":               {args: []string{"activate", "jane"}, expectedErr: "User does not exists"},
+			"invalid expiry":             {args: []string{"expiry", "jane", "soon"}, expectedErr: "unable to parse expiry"},
+			"missing principals op":      {args: []string{"principals", "jane"}, expectedErr: "principals requires an operation"},
+			"missing principals":         {args: []string{"principals", "jane", "add"}, expectedErr: "principals add requires at least one principal"},
+			"unknown principals op":      {args: []string{"principals", "jane", "foo", "bar"}, expectedErr: `unknown principals operation "foo"`},
+			"expiry without duration":    {args: []string{"expiry", "jane"}, expectedErr: "expiry requires a single duration argument"},
+			"expiry with many durations": {args: []string{"expiry", "jane", "1h", "2h"}, expectedErr: "expiry requires a single duration argument"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, _, err := env.run(t, append([]string{"admin"}, test.args...)...)
+				assert.Check(t, cmp.ErrorContains(err, test.expectedErr))
+			})
+		}
+	})
+}
+
+func Test_runAdminPending(t *testing.T) {
+	env := newTestEnv(t)
+
+	stdout, _, err := env.run(t, "admin", "pending")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stdout, "no pending keys\n"))
+
+	env.srv.SeedUser(casshtest.User{Name: "jane", RealName: "jane@corp", State: cassh.KeyStatePending, PublicKey: env.publicKey, Principals: cassh.Principals{"jane"}})
+	env.srv.SeedUser(casshtest.User{Name: "jack", State: cassh.KeyStatePending})
+	env.srv.SeedUser(casshtest.User{Name: "john", State: cassh.KeyStateActive})
+
+	stdout, _, err = env.run(t, "admin", "pending")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "[PENDING] jane (jane@corp)"))
+	assert.Check(t, cmp.Contains(stdout, "  key: "+ssh.FingerprintSHA256(env.publicKey)+" (ED25519, 256 bits, high strength)"))
+	assert.Check(t, cmp.Contains(stdout, "  principals: jane\n"))
+	assert.Check(t, cmp.Contains(stdout, "[PENDING] jack"))
+	assert.Check(t, !strings.Contains(stdout, "john"))
+
+	stdout, _, err = env.run(t, "admin", "pending", "approve", "jane", "-expiry", "12h", "-principals", "jane, web")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stdout, "  set principals: ok\n  set expiry: ok\n  activate: ok\ndone\n"))
+	user, _ := env.srv.User("jane")
+	assert.Check(t, cmp.Equal(user.State, cassh.KeyStateActive))
+	assert.Check(t, cmp.Equal(user.Expiry, 12*time.Hour))
+	assert.Check(t, cmp.DeepEqual(user.Principals, cassh.Principals{"jane", "web"}))
+
+	stdout, _, err = env.run(t, "admin", "pending", "reject", "jack")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stdout, "  delete: ok\ndone\n"))
+	_, exists := env.srv.User("jack")
+	assert.Check(t, !exists)
+
+	t.Run("ko", func(t *testing.T) {
+		for name, test := range map[string]struct {
+			args        []string
+			expectedErr string
+		}{
+			"missing username":      {args: []string{"approve"}, expectedErr: "pending approve requires a username"},
+			"flags before username": {args: []string{"approve", "-expiry", "1h", "jane"}, expectedErr: "pending approve requires a username"},
+			"unknown operation":     {args: []string{"foo", "jane"}, expectedErr: `unknown pending operation "foo"`},
+			"not pending":           {args: []string{"approve", "john"}, expectedErr: "key not pending: key of user john is ACTIVE"},
+			"reject not pending":    {args: []string{"reject", "john"}, expectedErr: "key not pending"},
+			"unknown user":          {args: []string{"reject", "jack"}, expectedErr: "User does not exists"},
+			"reject with arguments": {args: []string{"reject", "john", "jack"}, expectedErr: "pending reject requires a single username"},
+			"unexpected arguments":  {args: []string{"approve", "john", "jack"}, expectedErr: `unexpected arguments ["jack"]`},
+			"invalid expiry flag":   {args: []string{"approve", "john", "-expiry", "soon"}, expectedErr: "invalid value"},
+			"invalid expiry":        {args: []string{"approve", "john", "-expiry", "30m"}, expectedErr: "invalid expiry 30m0s, smallest is 1h"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, _, err := env.run(t, append([]string{"admin", "pending"}, test.args...)...)
+				assert.Check(t, cmp.ErrorContains(err, test.expectedErr))
+			})
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/krostar/cassh/krlsync"
+)
+
+func runAuthority(ctx context.Context, env *environment, args []string) error {
+	flags := newCommandFlags(env, "ca")
+	knownHosts := flags.String("known-hosts", "", "print known_hosts lines trusting the authority for the provided comma-separated host patterns")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	_, client, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	if *knownHosts != "" {
+		lines, err := client.AuthorityKnownHosts(ctx, strings.Split(*knownHosts, ",")...)
+		if err != nil {
+			return fmt.Errorf("unable to get authority known hosts: %w", err)
+		}
+		_, err = env.stdout.Write(lines)
+		return err
+	}
+
+	authority, err := client.AuthorityPublicKey(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get authority public key: %w", err)
+	}
+
+	_, err = env.stdout.Write(ssh.MarshalAuthorizedKey(authority))
+	return err
+}
+
+func runKeyRevocationList(ctx context.Context, env *environment, args []string) error {
+	if len(args) > 0 && args[0] == "sync" {
+		return runKeyRevocationListSync(ctx, env, args[1:])
+	}
+
+	flags := newCommandFlags(env, "krl")
+	output := flags.String("output", "", "write the key revocation list to the provided path instead of describing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	_, client, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	list, raw, err := client.RawKeyRevocationList(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get key revocation list: %w", err)
+	}
+
+	if *output == "" {
+		fmt.Fprintf(env.stdout, "version: %d\n", list.Version)
+		fmt.Fprintf(env.stdout, "generated: %s\n", time.Unix(int64(list.GeneratedDate), 0).Format(time.RFC3339))
+		fmt.Fprintf(env.stdout, "sections: %d\n", len(list.Sections))
+		return nil
+	}
+
+	// the list is written as sent by the server, marshaling it would drop its signatures
+	if err := os.WriteFile(*output, raw, 0o644); err != nil { //nolint:gosec // revocation lists are meant to be read by sshd
+		return fmt.Errorf("unable to write key revocation list: %v", err)
+	}
+
+	return nil
+}
+
+func runKeyRevocationListSync(ctx context.Context, env *environment, args []string) error {
+	flags := newCommandFlags(env, "krl sync")
+	output := flags.String("output", "", "path of the key revocation list used by sshd")
+	requireSignature := flags.Bool("require-signature", false, "refuse key revocation lists that are not signed by the authority")
+	allowShrink := flags.Bool("allow-shrink", false, "accept key revocation lists revoking fewer keys, or older, than the synchronized one")
+	daemon := flags.Bool("daemon", false, "keep running and synchronize the key revocation list periodically")
+	interval := flags.Duration("interval", time.Minute, "delay between two synchronizations in daemon mode")
+	metricsFile := flags.String("metrics-file", "", "write metrics in the Prometheus text format to the provided path after each synchronization")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return errors.New("an output path is required")
+	}
+
+	_, client, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	opts := []krlsync.SyncerOption{krlsync.SyncerOptionInterval(*interval)}
+	if *requireSignature {
+		opts = append(opts, krlsync.SyncerOptionRequireSignature())
+	}
+	if *allowShrink {
+		opts = append(opts, krlsync.SyncerOptionAllowShrink())
+	}
+	if *metricsFile != "" {
+		opts = append(opts, krlsync.SyncerOptionMetricsFile(*metricsFile))
+	}
+
+	if *daemon {
+		opts = append(opts, krlsync.SyncerOptionOnSync(func(result krlsync.Result, err error) {
+			switch {
+			case err != nil:
+				fmt.Fprintf(env.stderr, "key revocation list synchronization failed: %v\n", err)
+			case result.Changed:
+				printKeyRevocationListSynced(env, *output, result)
+			}
+		}))
+
+		if err := krlsync.New(client, *output, opts...).Run(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		return nil
+	}
+
+	result, err := krlsync.New(client, *output, opts...).Sync(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to synchronize key revocation list: %w", err)
+	}
+
+	if result.Changed {
+		printKeyRevocationListSynced(env, *output, result)
+	} else {
+		fmt.Fprintf(env.stdout, "key revocation list version %d is up to date\n", result.Version)
+	}
+
+	return nil
+}
+
+func printKeyRevocationListSynced(env *environment, path string, result krlsync.Result) {
+	fmt.Fprintf(env.stdout, "key revocation list version %d written to %s (%d revoked entries)\n", result.Version, path, result.RevokedEntries)
+}
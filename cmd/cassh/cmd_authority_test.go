@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/krostar/cassh"
+	"github.com/krostar/cassh/casshtest"
+)
+
+func Test_runAuthority(t *testing.T) {
+	env := newTestEnv(t)
+
+	stdout, _, err := env.run(t, "ca")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stdout, string(ssh.MarshalAuthorizedKey(env.srv.Authority()))))
+
+	stdout, _, err = env.run(t, "ca", "-known-hosts", "*.corp,10.0.0.*")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stdout, "@cert-authority *.corp,10.0.0.* "+string(ssh.MarshalAuthorizedKey(env.srv.Authority()))))
+
+	env.writeConfig(t, "pinned_authorities = SHA256:nope\n")
+
+	_, _, err = env.run(t, "ca")
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrAuthorityMismatch))
+}
+
+func Test_runKeyRevocationList(t *testing.T) {
+	env := newTestEnv(t, casshtest.ServerOptionSignKRL())
+	env.srv.RevokeKey(env.publicKey)
+
+	stdout, _, err := env.run(t, "krl")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "version: 2\n"))
+	assert.Check(t, cmp.Contains(stdout, "sections: 1\n"))
+
+	output := filepath.Join(env.dir, "revoked_keys")
+	_, _, err = env.run(t, "krl", "-output", output)
+	assert.NilError(t, err)
+
+	raw, err := os.ReadFile(output)
+	assert.NilError(t, err)
+	list, err := krl.ParseKRL(raw)
+	assert.NilError(t, err)
+	assert.Check(t, list.IsRevoked(env.publicKey))
+	assert.Check(t, cmp.Len(list.SigningKeys, 1), "list must be written as sent by the server")
+	assert.Check(t, cmp.Equal(list.Version, uint64(2)))
+}
+
+func Test_runKeyRevocationListSync(t *testing.T) {
+	env := newTestEnv(t)
+	env.srv.RevokeKey(env.publicKey)
+
+	output := filepath.Join(env.dir, "revoked_keys")
+	metrics := filepath.Join(env.dir, "cassh.prom")
+
+	_, _, err := env.run(t, "krl", "sync")
+	assert.Check(t, cmp.ErrorContains(err, "an output path is required"))
+
+	stdout, _, err := env.run(t, "krl", "sync", "-output", output, "-metrics-file", metrics)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stdout, "key revocation list version 2 written to "+output+" (1 revoked entries)\n"))
+
+	raw, err := os.ReadFile(output)
+	assert.NilError(t, err)
+	list, err := krl.ParseKRL(raw)
+	assert.NilError(t, err)
+	assert.Check(t, list.IsRevoked(env.publicKey))
+
+	_, err = os.Stat(metrics)
+	assert.NilError(t, err)
+
+	stdout, _, err = env.run(t, "krl", "sync", "-output", output)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stdout, "key revocation list version 2 is up to date\n"))
+
+	_, _, err = env.run(t, "krl", "sync", "-output", output, "-require-signature")
+	assert.Check(t, cmp.ErrorContains(err, "key revocation list is not signed"))
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/krostar/cassh"
+	"github.com/krostar/cassh/config"
+	"github.com/krostar/cassh/sshagent"
+	"github.com/krostar/sshx"
+)
+
+func (env *environment) userSession() (*config.Config, *cassh.SessionUser, error) {
+	cfg, client, err := env.client()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := cfg.NewSessionUser(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, session, nil
+}
+
+func (env *environment) userKeySession() (*config.Config, *cassh.SessionUserKey, error) {
+	cfg, session, err := env.userSession()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, err := sshx.NewPublicKeyFromOpenSSHAuthorizedKeyFile(cfg.PublicKeyPath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read user public key: %v", err)
+	}
+
+	return cfg, session.Key(publicKey), nil
+}
+
+func runStatus(ctx context.Context, env *environment, args []string) error {
+	flags := newCommandFlags(env, "status")
+	checkRevocation := flags.Bool("check-revocation", false, "also check whether the user key and certificate are revoked")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, client, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	session, err := cfg.NewSessionUser(client)
+	if err != nil {
+		return err
+	}
+
+	negotiateServerVersion(ctx, client)
+
+	status, err := session.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get user status: %w", err)
+	}
+
+	printUserStatus(env, status)
+	printLocalKey(env, status, cfg.PublicKeyPath())
+
+	if *checkRevocation {
+		return printRevocation(ctx, env, cfg, client, session)
+	}
+
+	return nil
+}
+
+func printRevocation(ctx context.Context, env *environment, cfg *config.Config, client *cassh.Client, session *cassh.SessionUser) error {
+	publicKey, err := sshx.NewPublicKeyFromOpenSSHAuthorizedKeyFile(cfg.PublicKeyPath())
+	if err != nil {
+		return fmt.Errorf("unable to read user public key: %v", err)
+	}
+	keySession := session.Key(publicKey)
+
+	// certificates are revoked when their key is, checking it is enough when there is one
+	certificate, err := keySession.Renewer(cfg.CertificatePath()).Certificate()
+	if err != nil {
+		return err
+	}
+
+	var revocation *cassh.Revocation
+	if certificate != nil {
+		revocation, err = client.Revocation(ctx, certificate)
+	} else {
+		revocation, err = keySession.Revocation(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to check revocation: %w", err)
+	}
+
+	if revocation == nil {
+		fmt.Fprintln(env.stdout, "  revoked: no")
+	} else {
+		fmt.Fprintf(env.stdout, "  revoked: yes, %s\n", revocation)
+	}
+
+	return nil
+}
+
+func printUserStatus(env *environment, status *cassh.UserStatus) {
+	principals := make([]string, len(status.KeyPrincipals))
+	for i, principal := range status.KeyPrincipals {
+		principals[i] = principal.String()
+	}
+
+	fmt.Fprintln(env.stdout, status.String())
+	if status.KeyExpiration.IsZero() {
+		fmt.Fprintln(env.stdout, "  expiration: none")
+	} else {
+		fmt.Fprintf(env.stdout, "  expiration: %s\n", status.KeyExpiration.Local().Format(time.RFC3339))
+	}
+	if status.KeyExpiry > 0 {
+		fmt.Fprintf(env.stdout, "  expiry: %s\n", status.KeyExpiry)
+	}
+	fmt.Fprintf(env.stdout, "  principals: %s\n", strings.Join(principals, ","))
+	if status.KeyFingerprint != "" {
+		fmt.Fprintf(env.stdout, "  key: %s (%s, %d bits, %s strength)\n", status.KeyFingerprint, status.KeyAlgorithm, status.KeyBits, strings.ToLower(status.KeyStrength.String()))
+	}
+}
+
+// printLocalKey prints whenever the key registered on the CASSH server is the one on disk.
+func printLocalKey(env *environment, status *cassh.UserStatus, publicKeyPath string) {
+	publicKey, err := sshx.NewPublicKeyFromOpenSSHAuthorizedKeyFile(publicKeyPath)
+	switch {
+	case err != nil:
+		fmt.Fprintf(env.stdout, "  local key: unable to read %s: %v\n", publicKeyPath, err)
+	case status.KeyMatches(publicKey):
+		fmt.Fprintf(env.stdout, "  local key: %s matches\n", publicKeyPath)
+	default:
+		fmt.Fprintf(env.stdout, "  local key: %s (%s) differs\n", publicKeyPath, ssh.FingerprintSHA256(publicKey))
+	}
+}
+
+func runAdd(ctx context.Context, env *environment, args []string) error {
+	if err := newCommandFlags(env, "add").Parse(args); err != nil {
+		return err
+	}
+
+	_, session, err := env.userKeySession()
+	if err != nil {
+		return err
+	}
+
+	if err := session.Set(ctx); err != nil {
+		return fmt.Errorf("unable to add user key: %w", err)
+	}
+
+	fmt.Fprintln(env.stdout, "key added, an admin needs to activate it before it can be signed")
+	return nil
+}
+
+func runSign(ctx context.Context, env *environment, args []string) error {
+	flags := newCommandFlags(env, "sign")
+	force := flags.Bool("force", false, "force the signature (admin only)")
+	addToAgent := flags.Bool("agent", false, "also add the private key and its certificate to the ssh agent")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, session, err := env.userKeySession()
+	if err != nil {
+		return err
+	}
+
+	var opts []cassh.SessionUserKeySignOption
+	if *force {
+		opts = append(opts, cassh.SessionUserKeySignOptionForce())
+	}
+
+	certificate, err := session.Renewer(cfg.CertificatePath(), cassh.RenewerOptionSignOptions(opts...)).Renew(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to sign user key: %w", err)
+	}
+
+	printCertificateWritten(env, cfg.CertificatePath(), certificate)
+
+	if *addToAgent {
+		return addCertificateToAgent(env, cfg, certificate)
+	}
+
+	return nil
+}
+
+func addCertificateToAgent(env *environment, cfg *config.Config, certificate *ssh.Certificate) error {
+	signer, err := env.userPrivateKey(cfg)
+	if err != nil {
+		return err
+	}
+
+	agent, closeAgent, err := sshagent.Dial()
+	if err != nil {
+		return err
+	}
+	defer closeAgent() //nolint:errcheck // nothing useful to do with a close error
+
+	if err := agent.AddCertificate(signer, certificate); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(env.stdout, "certificate added to the ssh agent")
+	return nil
+}
+
+// userPrivateKey returns the user private key, asking for its passphrase on the terminal if it is encrypted.
+func (env *environment) userPrivateKey(cfg *config.Config) (crypto.Signer, error) {
+	if env.userKey != nil {
+		return env.userKey, nil
+	}
+
+	rawPrivateKey, err := os.ReadFile(cfg.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read user private key: %v", err)
+	}
+
+	privateKey, err := ssh.ParseRawPrivateKey(rawPrivateKey)
+	var passphraseMissing *ssh.PassphraseMissingError
+	if errors.As(err, &passphraseMissing) {
+		fmt.Fprintf(env.stderr, "Passphrase for %s: ", cfg.KeyPath)
+		passphrase, readErr := env.readPassword()
+		fmt.Fprintln(env.stderr)
+		if readErr != nil {
+			return nil, fmt.Errorf("unable to read user private key passphrase: %v", readErr)
+		}
+		privateKey, err = ssh.ParseRawPrivateKeyWithPassphrase(rawPrivateKey, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse user private key: %v", err)
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	env.userKey = signer
+	return signer, nil
+}
+
+func printCertificateWritten(env *environment, path string, certificate *ssh.Certificate) {
+	fmt.Fprintf(env.stdout, "certificate written to %s, valid until %s\n",
+		path, time.Unix(int64(certificate.ValidBefore), 0).Format(time.RFC3339))
+}
+
+func runRenew(ctx context.Context, env *environment, args []string) error {
+	flags := newCommandFlags(env, "renew")
+	daemon := flags.Bool("daemon", false, "keep running and renew the certificate each time it is about to expire")
+	addToAgent := flags.Bool("agent", false, "also add the private key and each renewed certificate to the ssh agent")
+	renewBefore := flags.Duration("renew-before", 0, "renew the certificate this long before it expires (default to 20% of its validity)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, session, err := env.userKeySession()
+	if err != nil {
+		return err
+	}
+
+	renewer := session.Renewer(cfg.CertificatePath(),
+		cassh.RenewerOptionRenewBefore(*renewBefore),
+		cassh.RenewerOptionOnEvent(func(event cassh.RenewerEvent) {
+			if !*daemon {
+				return
+			}
+			switch event.Type {
+			case cassh.RenewerEventScheduled:
+				fmt.Fprintf(env.stderr, "next renewal scheduled at %s\n", event.At.Format(time.RFC3339))
+			case cassh.RenewerEventRenewed:
+				printCertificateWritten(env, cfg.CertificatePath(), event.Certificate)
+				if *addToAgent {
+					if err := addCertificateToAgent(env, cfg, event.Certificate); err != nil {
+						fmt.Fprintf(env.stderr, "unable to add certificate to the ssh agent: %v\n", err)
+					}
+				}
+			case cassh.RenewerEventFailed:
+				fmt.Fprintf(env.stderr, "renewal failed, next attempt at %s: %v\n", event.At.Format(time.RFC3339), event.Err)
+			}
+		}),
+	)
+
+	if *daemon {
+		if err := renewer.Run(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		return nil
+	}
+
+	if nextRenewal := renewer.NextRenewal(); nextRenewal.After(time.Now()) {
+		fmt.Fprintf(env.stdout, "certificate is still fresh, next renewal at %s\n", nextRenewal.Format(time.RFC3339))
+		return nil
+	}
+
+	certificate, err := renewer.Renew(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to renew certificate: %w", err)
+	}
+
+	printCertificateWritten(env, cfg.CertificatePath(), certificate)
+
+	if *addToAgent {
+		return addCertificateToAgent(env, cfg, certificate)
+	}
+
+	return nil
+}
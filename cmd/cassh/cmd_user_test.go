@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/krostar/cassh"
+	"github.com/krostar/cassh/casshtest"
+	"github.com/krostar/cassh/config"
+)
+
+func Test_runUser(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, _, err := env.run(t, "status")
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrUserNotFound))
+
+	stdout, _, err := env.run(t, "add")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "key added"))
+
+	stdout, _, err = env.run(t, "status")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "[PENDING] john (john)"))
+	assert.Check(t, cmp.Contains(stdout, "  key: "+ssh.FingerprintSHA256(env.publicKey)+" (ED25519, 256 bits, high strength)\n"))
+	assert.Check(t, cmp.Contains(stdout, "  local key: "+env.keyPath+".pub matches\n"))
+
+	user, _ := env.srv.User("john")
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	user.PublicKey, err = ssh.NewPublicKey(otherKey)
+	assert.NilError(t, err)
+	env.srv.SeedUser(user)
+
+	stdout, _, err = env.run(t, "status")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "  local key: "+env.keyPath+".pub ("+ssh.FingerprintSHA256(env.publicKey)+") differs\n"))
+	user.PublicKey = env.publicKey
+	env.srv.SeedUser(user)
+
+	_, _, err = env.run(t, "sign")
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrKeyPending))
+
+	user, _ = env.srv.User("john")
+	user.State = cassh.KeyStateActive
+	env.srv.SeedUser(user)
+
+	stdout, _, err = env.run(t, "sign")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "certificate written to "+env.keyPath+"-cert.pub"))
+
+	raw, err := os.ReadFile(env.keyPath + "-cert.pub")
+	assert.NilError(t, err)
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(raw)
+	assert.NilError(t, err)
+	certificate, ok := publicKey.(*ssh.Certificate)
+	assert.Assert(t, ok)
+	assert.Check(t, cmp.DeepEqual(certificate.Key.Marshal(), env.publicKey.Marshal()))
+
+	stdout, _, err = env.run(t, "status", "-check-revocation")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "  revoked: no\n"))
+
+	env.srv.RevokeKey(env.publicKey)
+
+	stdout, _, err = env.run(t, "status", "-check-revocation")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "  revoked: yes, key "+ssh.FingerprintSHA256(env.publicKey)+" revoked by explicit key entry"))
+}
+
+func Test_runUser_ldap(t *testing.T) {
+	env := newTestEnv(t, casshtest.ServerOptionLDAPCredentials(map[string]string{"john@corp": "secret"}))
+	env.srv.SeedUser(casshtest.User{Name: "john", RealName: "john@corp"})
+
+	_, _, err := env.run(t, "status")
+	assert.Check(t, cmp.ErrorIs(err, cassh.ErrInsufficientPrivileges))
+
+	env.writeConfig(t, "[ldap]\nrealname = john@corp\npassword = secret\n")
+
+	stdout, _, err := env.run(t, "status")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "[PENDING] john (john@corp)"))
+}
+
+func Test_runRenew(t *testing.T) {
+	env := newTestEnv(t)
+	env.srv.SeedUser(casshtest.User{Name: "john", State: cassh.KeyStateActive, PublicKey: env.publicKey})
+
+	stdout, _, err := env.run(t, "renew")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "certificate written to "+env.keyPath+"-cert.pub"))
+
+	stdout, _, err = env.run(t, "renew")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "certificate is still fresh"))
+
+	stdout, _, err = env.run(t, "renew", "-renew-before", "48h")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "certificate is still fresh"), "renew before longer than the certificate validity must be ignored")
+
+	stdout, _, err = env.run(t, "renew", "-renew-before", "24h")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "certificate written to"))
+	assert.Check(t, cmp.Equal(env.srv.RequestsCount("/client"), 2))
+
+	t.Run("daemon", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var stdout, stderr bytes.Buffer
+		assert.NilError(t, run(ctx, []string{"-config", env.configPath, "renew", "-daemon"}, &stdout, &stderr))
+		assert.Check(t, cmp.Contains(stderr.String(), "next renewal scheduled at"))
+	})
+}
+
+func Test_runSign_agent(t *testing.T) {
+	env := newTestEnv(t)
+	env.srv.SeedUser(casshtest.User{Name: "john", State: cassh.KeyStateActive, PublicKey: env.publicKey})
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	_, _, err := env.run(t, "sign", "-agent")
+	assert.Check(t, cmp.ErrorContains(err, "SSH_AUTH_SOCK is not set"))
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	listener, err := net.Listen("unix", socket)
+	assert.NilError(t, err)
+	defer listener.Close()
+
+	keyring := agent.NewKeyring()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = agent.ServeAgent(keyring, conn)
+			conn.Close()
+		}
+	}()
+
+	stdout, _, err := env.run(t, "sign", "-agent")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(stdout, "certificate added to the ssh agent"))
+
+	keys, err := keyring.List()
+	assert.NilError(t, err)
+	assert.Assert(t, cmp.Len(keys, 1))
+	assert.Check(t, cmp.Equal(keys[0].Format, ssh.CertAlgoED25519v01))
+}
+
+func Test_environment_userPrivateKey(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	rawPrivateKey, err := x509.MarshalECPrivateKey(privateKey)
+	assert.NilError(t, err)
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", rawPrivateKey, []byte("secret"), x509.PEMCipherAES256) //nolint:staticcheck // openssh encrypted keys cannot be written by this x/crypto version
+	assert.NilError(t, err)
+
+	cfg := &config.Config{KeyPath: filepath.Join(t.TempDir(), "id_ecdsa")}
+	assert.NilError(t, os.WriteFile(cfg.KeyPath, pem.EncodeToMemory(block), 0o600))
+
+	t.Run("ok", func(t *testing.T) {
+		var stderr bytes.Buffer
+		var prompts int
+		env := &environment{stderr: &stderr, readPassword: func() ([]byte, error) {
+			prompts++
+			return []byte("secret"), nil
+		}}
+
+		for i := 0; i < 2; i++ {
+			signer, err := env.userPrivateKey(cfg)
+			assert.NilError(t, err)
+			assert.Check(t, privateKey.Equal(signer))
+		}
+		assert.Check(t, cmp.Equal(prompts, 1), "passphrase must be asked once")
+		assert.Check(t, cmp.Equal(stderr.String(), "Passphrase for "+cfg.KeyPath+": \n"))
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		env := &environment{stderr: io.Discard, readPassword: func() ([]byte, error) { return []byte("wrong"), nil }}
+		_, err := env.userPrivateKey(cfg)
+		assert.Check(t, cmp.ErrorContains(err, "unable to parse user private key"))
+	})
+
+	t.Run("no terminal", func(t *testing.T) {
+		env := &environment{stderr: io.Discard, readPassword: func() ([]byte, error) { return nil, errors.New("standard input is not a terminal") }}
+		_, err := env.userPrivateKey(cfg)
+		assert.Check(t, cmp.Error(err, "unable to read user private key passphrase: standard input is not a terminal"))
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/krostar/cassh"
+	"github.com/krostar/cassh/config"
+)
+
+func (env *environment) loadConfig() (*config.Config, error) {
+	return config.Load(env.configPath)
+}
+
+func (env *environment) client() (*config.Config, *cassh.Client, error) {
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := cfg.NewClient()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create cassh client: %v", err)
+	}
+
+	return cfg, client, nil
+}
+
+// negotiateServerVersion negotiates the version of the server, for its responses to be decoded according to it.
+// Failures are ignored, responses being then decoded accepting the formats of all server releases.
+func negotiateServerVersion(ctx context.Context, client *cassh.Client) {
+	_, _, _ = client.ServerVersion(ctx)
+}
@@ -0,0 +1,121 @@
+// Command cassh is a client of the CASSH server, built on top of the cassh package.
+package main
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"golang.org/x/term"
+
+	"github.com/krostar/cassh/config"
+)
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "cassh: %v\n", err)
+		}
+		cancel()
+		os.Exit(1) //nolint:gocritic // cancel is explicitly called before exiting
+	}
+}
+
+type command struct {
+	usage       string
+	description string
+	run         func(ctx context.Context, env *environment, args []string) error
+}
+
+func commands() map[string]command {
+	return map[string]command{
+		"status": {usage: "status [-check-revocation]", description: "show the user status", run: runStatus},
+		"add":    {usage: "add", description: "add or update the user key", run: runAdd},
+		"sign":   {usage: "sign [-force] [-agent]", description: "sign the user key and write the certificate next to it", run: runSign},
+		"renew":  {usage: "renew [-daemon] [-agent] [-renew-before duration]", description: "sign the user key again if its certificate is about to expire", run: runRenew},
+		"ca":     {usage: "ca [-known-hosts patterns]", description: "show the public key of the certificate authority", run: runAuthority},
+		"krl":    {usage: "krl [sync] [-output path]", description: "show, write, or synchronize the key revocation list", run: runKeyRevocationList},
+		"admin":  {usage: "admin <action> <username> [args...]", description: "manage users as admin", run: runAdmin},
+	}
+}
+
+// environment stores what is shared by all commands.
+type environment struct {
+	stdout       io.Writer
+	stderr       io.Writer
+	configPath   string
+	readPassword func() ([]byte, error)
+
+	// userKey is the user private key, kept once parsed to ask for its passphrase only once.
+	userKey crypto.Signer
+}
+
+// readTerminalPassword reads a password on the terminal, without echoing it.
+var readTerminalPassword = func() ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("standard input is not a terminal")
+	}
+	return term.ReadPassword(fd)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	env := &environment{stdout: stdout, stderr: stderr, readPassword: readTerminalPassword}
+
+	flags := flag.NewFlagSet("cassh", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&env.configPath, "config", config.DefaultPath(), "path of the configuration file")
+	flags.Usage = func() { printUsage(stderr, flags) }
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	cmd, exists := commands()[flags.Arg(0)]
+	if !exists {
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", flags.Arg(0))
+	}
+
+	return cmd.run(ctx, env, flags.Args()[1:])
+}
+
+func printUsage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: cassh [flags] <command> [command flags] [args...]\n\nCommands:\n")
+
+	cmds := commands()
+
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-40s %s\n", cmds[name].usage, cmds[name].description)
+	}
+
+	fmt.Fprintf(w, "\nFlags:\n")
+	flags.PrintDefaults()
+}
+
+func newCommandFlags(env *environment, name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("cassh "+name, flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	return flags
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/krostar/cassh/casshtest"
+)
+
+type testEnv struct {
+	srv        *casshtest.Server
+	dir        string
+	configPath string
+	keyPath    string
+	publicKey  ssh.PublicKey
+}
+
+func newTestEnv(t *testing.T, opts ...casshtest.ServerOption) *testEnv {
+	srv, err := casshtest.NewServer(opts...)
+	assert.NilError(t, err)
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	env := &testEnv{
+		srv:        srv,
+		dir:        dir,
+		configPath: filepath.Join(dir, "cassh"),
+		keyPath:    filepath.Join(dir, "id_ed25519"),
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	env.publicKey, err = ssh.NewPublicKey(publicKey)
+	assert.NilError(t, err)
+	rawPrivateKey, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.NilError(t, err)
+
+	assert.NilError(t, os.WriteFile(env.keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawPrivateKey}), 0o600))
+	assert.NilError(t, os.WriteFile(env.keyPath+".pub", ssh.MarshalAuthorizedKey(env.publicKey), 0o600))
+
+	env.writeConfig(t, "")
+
+	return env
+}
+
+// writeConfig writes a configuration file to talk to the test server, with extra content appended to it.
+func (env *testEnv) writeConfig(t *testing.T, extra string) {
+	content := fmt.Sprintf("[user]\nname = john\nurl = %s\nkey_path = %s\ntimeout = 5\nverify = false\n%s", env.srv.URL(), env.keyPath, extra)
+	assert.NilError(t, os.WriteFile(env.configPath, []byte(content), 0o600))
+}
+
+func (env *testEnv) run(t *testing.T, args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), append([]string{"-config", env.configPath}, args...), &stdout, &stderr)
+	t.Logf("stdout: %s\nstderr: %s", stdout.String(), stderr.String())
+	return stdout.String(), stderr.String(), err
+}
+
+func Test_run(t *testing.T) {
+	env := newTestEnv(t)
+
+	t.Run("no command", func(t *testing.T) {
+		_, stderr, err := env.run(t)
+		assert.Check(t, cmp.ErrorIs(err, flag.ErrHelp))
+		assert.Check(t, cmp.Contains(stderr, "Commands:"))
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		_, _, err := env.run(t, "foo")
+		assert.Check(t, cmp.ErrorContains(err, `unknown command "foo"`))
+	})
+
+	t.Run("unreadable configuration", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		err := run(context.Background(), []string{"-config", filepath.Join(env.dir, "nope"), "status"}, &stdout, &stderr)
+		assert.Check(t, cmp.ErrorContains(err, "unable to open configuration file"))
+	})
+}
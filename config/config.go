@@ -0,0 +1,271 @@
+// Package config reads the INI configuration file of the upstream python CASSH client,
+// and turns it into a cassh client and sessions.
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krostar/cassh"
+)
+
+// Config stores the settings of the upstream python CASSH client configuration file.
+type Config struct {
+	// URL is the address of the CASSH server.
+	URL string
+	// Name is the CASSH username.
+	Name cassh.Username
+	// KeyPath is the path of the user private key, public key is expected to be KeyPath + ".pub".
+	KeyPath string
+	// KeySignedPath is the path of the signed certificate, without the ".pub" suffix.
+	KeySignedPath string
+	// Timeout is the timeout of each request made to the CASSH server.
+	Timeout time.Duration
+	// Verify defines whenever the CASSH server TLS certificate is verified.
+	Verify bool
+	// SSLCert and SSLKey are the paths of the TLS client certificate and its key, loaded again when they change.
+	SSLCert string
+	SSLKey  string
+	// PinnedAuthorities are the authority keys or SHA256 fingerprints the CASSH server is expected to use.
+	// It is not part of the upstream python client configuration.
+	PinnedAuthorities []string
+	// AllowUnsignedKRL accepts key revocation lists without signature when authorities are pinned.
+	// It is not part of the upstream python client configuration.
+	AllowUnsignedKRL bool
+	// LDAPRealName and LDAPPassword are the LDAP credentials used to authenticate requests.
+	LDAPRealName string
+	LDAPPassword string
+	// LDAPPasswordFile, LDAPPasswordCommand, and LDAPPasswordEnv are alternatives to LDAPPassword,
+	// the password being read from the file, the output of the shell command, or the environment variable on each request.
+	// They are not part of the upstream python client configuration.
+	LDAPPasswordFile    string
+	LDAPPasswordCommand string
+	LDAPPasswordEnv     string
+}
+
+// DefaultPath returns the path where the upstream python client expects its configuration file.
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".cassh"
+	}
+	return filepath.Join(home, ".cassh")
+}
+
+// Load reads and parses the configuration file at the provided path.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open configuration file: %v", err)
+	}
+	defer f.Close() //nolint:errcheck // file is only read
+
+	cfg, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse configuration file %s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Parse parses the provided configuration.
+func Parse(r io.Reader) (*Config, error) {
+	file, err := parseINI(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		KeyPath: "~/.ssh/id_rsa",
+		Timeout: 2 * time.Second,
+		Verify:  true,
+	}
+
+	if cfg.URL, _ = file.get("user", "url"); cfg.URL == "" {
+		return nil, errors.New("user.url is required")
+	}
+
+	name, _ := file.get("user", "name")
+	cfg.Name = cassh.Username(name)
+
+	if keyPath, exists := file.get("user", "key_path"); exists {
+		cfg.KeyPath = keyPath
+	}
+
+	cfg.KeySignedPath, _ = file.get("user", "key_signed_path")
+	cfg.SSLCert, _ = file.get("user", "ssl_cert")
+	cfg.SSLKey, _ = file.get("user", "ssl_key")
+	cfg.LDAPRealName, _ = file.get("ldap", "realname")
+	cfg.LDAPPassword, _ = file.get("ldap", "password")
+	cfg.LDAPPasswordFile, _ = file.get("ldap", "password_file")
+	cfg.LDAPPasswordCommand, _ = file.get("ldap", "password_command")
+	cfg.LDAPPasswordEnv, _ = file.get("ldap", "password_env")
+
+	if pinnedAuthorities, exists := file.get("user", "pinned_authorities"); exists {
+		for _, authority := range strings.Split(pinnedAuthorities, ",") {
+			if authority = strings.TrimSpace(authority); authority != "" {
+				cfg.PinnedAuthorities = append(cfg.PinnedAuthorities, authority)
+			}
+		}
+	}
+
+	if timeout, exists := file.get("user", "timeout"); exists {
+		seconds, err := strconv.ParseFloat(timeout, 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("user.timeout is not a positive number of seconds: %q", timeout)
+		}
+		cfg.Timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	if verify, exists := file.get("user", "verify"); exists {
+		if cfg.Verify, err = parseINIBool(verify); err != nil {
+			return nil, fmt.Errorf("user.verify: %v", err)
+		}
+	}
+
+	if allowUnsignedKRL, exists := file.get("user", "allow_unsigned_krl"); exists {
+		if cfg.AllowUnsignedKRL, err = parseINIBool(allowUnsignedKRL); err != nil {
+			return nil, fmt.Errorf("user.allow_unsigned_krl: %v", err)
+		}
+	}
+
+	if (cfg.SSLCert == "") != (cfg.SSLKey == "") {
+		return nil, errors.New("user.ssl_cert and user.ssl_key must be set together")
+	}
+
+	passwordSources := 0
+	for _, source := range []string{cfg.LDAPPassword, cfg.LDAPPasswordFile, cfg.LDAPPasswordCommand, cfg.LDAPPasswordEnv} {
+		if source != "" {
+			passwordSources++
+		}
+	}
+	if passwordSources > 1 {
+		return nil, errors.New("only one of ldap.password, ldap.password_file, ldap.password_command, and ldap.password_env can be set")
+	}
+
+	for _, path := range []*string{&cfg.KeyPath, &cfg.KeySignedPath, &cfg.SSLCert, &cfg.SSLKey, &cfg.LDAPPasswordFile} {
+		if *path, err = expandHome(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.KeySignedPath == "" {
+		cfg.KeySignedPath = cfg.KeyPath + "-cert"
+	}
+
+	return cfg, nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to get user home directory: %v", err)
+	}
+
+	return home + strings.TrimPrefix(path, "~"), nil
+}
+
+// PublicKeyPath returns the path of the user public key.
+func (cfg *Config) PublicKeyPath() string { return cfg.KeyPath + ".pub" }
+
+// CertificatePath returns the path of the signed certificate, as expected by ssh.
+func (cfg *Config) CertificatePath() string { return cfg.KeySignedPath + ".pub" }
+
+// ClientOptions returns the cassh client options matching the configuration.
+func (cfg *Config) ClientOptions() []cassh.ClientOption {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: !cfg.Verify, //nolint:gosec // explicitly asked by configuration
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	opts := []cassh.ClientOption{
+		cassh.ClientOptionHTTPClient(&http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+		}),
+	}
+
+	if cfg.SSLCert != "" {
+		opts = append(opts, cassh.ClientOptionTLS(cassh.TLSOptionClientCertificateFiles(cfg.SSLCert, cfg.SSLKey)))
+	}
+
+	if len(cfg.PinnedAuthorities) > 0 {
+		opts = append(opts, cassh.ClientOptionPinnedAuthority(cfg.PinnedAuthorities...))
+	}
+
+	if cfg.AllowUnsignedKRL {
+		opts = append(opts, cassh.ClientOptionAllowUnsignedKRL())
+	}
+
+	return opts
+}
+
+// NewClient creates a cassh client from the configuration; provided options are applied after the configuration ones.
+func (cfg *Config) NewClient(opts ...cassh.ClientOption) (*cassh.Client, error) {
+	return cassh.NewClient(cfg.URL, append(cfg.ClientOptions(), opts...)...)
+}
+
+// SessionUserOptions returns the user session options matching the configuration.
+func (cfg *Config) SessionUserOptions() []cassh.SessionUserOption {
+	if cfg.LDAPRealName == "" {
+		return nil
+	}
+	if provider := cfg.ldapCredentialProvider(); provider != nil {
+		return []cassh.SessionUserOption{cassh.SessionUserOptionAuthenticationMechanismLDAPCredential(provider)}
+	}
+	return []cassh.SessionUserOption{cassh.SessionUserOptionAuthenticationMechanismLDAP(cfg.LDAPRealName, cfg.LDAPPassword)}
+}
+
+// SessionAdminOptions returns the admin session options matching the configuration.
+func (cfg *Config) SessionAdminOptions() []cassh.SessionAdminOption {
+	if cfg.LDAPRealName == "" {
+		return nil
+	}
+	if provider := cfg.ldapCredentialProvider(); provider != nil {
+		return []cassh.SessionAdminOption{cassh.SessionAdminOptionAuthenticationMechanismLDAPCredential(provider)}
+	}
+	return []cassh.SessionAdminOption{cassh.SessionAdminOptionAuthenticationMechanismLDAP(cfg.LDAPRealName, cfg.LDAPPassword)}
+}
+
+// ldapCredentialProvider returns the provider of the LDAP password, or nil if the password is static.
+func (cfg *Config) ldapCredentialProvider() cassh.CredentialProvider {
+	switch {
+	case cfg.LDAPPasswordFile != "":
+		return cassh.CredentialProviderFile(cfg.LDAPRealName, cfg.LDAPPasswordFile)
+	case cfg.LDAPPasswordCommand != "":
+		return cassh.CredentialProviderExec(cfg.LDAPRealName, "sh", "-c", cfg.LDAPPasswordCommand)
+	case cfg.LDAPPasswordEnv != "":
+		return cassh.CredentialProviderEnv(cfg.LDAPRealName, cfg.LDAPPasswordEnv)
+	default:
+		return nil
+	}
+}
+
+// NewSessionUser creates the configured user session; provided options are applied after the configuration ones.
+func (cfg *Config) NewSessionUser(client *cassh.Client, opts ...cassh.SessionUserOption) (*cassh.SessionUser, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("user.name is required to create a user session")
+	}
+
+	return client.SessionUser(cfg.Name, append(cfg.SessionUserOptions(), opts...)...), nil
+}
+
+// NewSessionAdmin creates the configured admin session; provided options are applied after the configuration ones.
+func (cfg *Config) NewSessionAdmin(client *cassh.Client, opts ...cassh.SessionAdminOption) *cassh.SessionAdmin {
+	return client.SessionAdmin(append(cfg.SessionAdminOptions(), opts...)...)
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/krostar/cassh"
+	"github.com/krostar/cassh/casshtest"
+)
+
+func Test_Parse(t *testing.T) {
+	home, err := os.UserHomeDir()
+	assert.NilError(t, err)
+
+	t.Run("ok", func(t *testing.T) {
+		cfg, err := Parse(strings.NewReader(`
+[user]
+name = john
+key_path = ~/.ssh/id_ed25519
+url = https://cassh.corp
+timeout = 0.5
+verify = False
+pinned_authorities = SHA256:abc, SHA256:def,
+allow_unsigned_krl = true
+
+[ldap]
+realname = john@corp
+`))
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(cfg, &Config{
+			URL:               "https://cassh.corp",
+			Name:              "john",
+			KeyPath:           home + "/.ssh/id_ed25519",
+			KeySignedPath:     home + "/.ssh/id_ed25519-cert",
+			Timeout:           500 * time.Millisecond,
+			Verify:            false,
+			PinnedAuthorities: []string{"SHA256:abc", "SHA256:def"},
+			AllowUnsignedKRL:  true,
+			LDAPRealName:      "john@corp",
+		}))
+		assert.Check(t, cmp.Equal(cfg.PublicKeyPath(), home+"/.ssh/id_ed25519.pub"))
+		assert.Check(t, cmp.Equal(cfg.CertificatePath(), home+"/.ssh/id_ed25519-cert.pub"))
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := Parse(strings.NewReader("[user]\nurl = https://cassh.corp\nkey_signed_path = /tmp/signed"))
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(cfg.KeyPath, home+"/.ssh/id_rsa"))
+		assert.Check(t, cmp.Equal(cfg.KeySignedPath, "/tmp/signed"))
+		assert.Check(t, cmp.Equal(cfg.Timeout, 2*time.Second))
+		assert.Check(t, cfg.Verify)
+	})
+
+	t.Run("ldap password alternatives", func(t *testing.T) {
+		cfg, err := Parse(strings.NewReader("[user]\nurl = https://cassh.corp\n[ldap]\nrealname = john@corp\npassword_file = ~/.cassh-password"))
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(cfg.LDAPPasswordFile, home+"/.cassh-password"))
+
+		cfg, err = Parse(strings.NewReader("[user]\nurl = https://cassh.corp\n[ldap]\nrealname = john@corp\npassword_command = pass show ldap"))
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(cfg.LDAPPasswordCommand, "pass show ldap"))
+
+		cfg, err = Parse(strings.NewReader("[user]\nurl = https://cassh.corp\n[ldap]\nrealname = john@corp\npassword_env = LDAP_PASSWORD"))
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(cfg.LDAPPasswordEnv, "LDAP_PASSWORD"))
+	})
+
+	t.Run("ko", func(t *testing.T) {
+		for content, expectedErr := range map[string]string{
+			"[user":               "malformed section header",
+			"[user]\nname = john": "user.url is required",
+			"[user]\nurl = https://a\ntimeout = soon":                         "user.timeout is not a positive number of seconds",
+			"[user]\nurl = https://a\ntimeout = -1":                           "user.timeout is not a positive number of seconds",
+			"[user]\nurl = https://a\nverify = maybe":                         "user.verify: not a boolean",
+			"[user]\nurl = https://a\nallow_unsigned_krl = maybe":             "user.allow_unsigned_krl: not a boolean",
+			"[user]\nurl = https://a\nssl_cert = /tmp/cert.pem":               "user.ssl_cert and user.ssl_key must be set together",
+			"[user]\nurl = https://a\nssl_key = /tmp/client.key":              "user.ssl_cert and user.ssl_key must be set together",
+			"[user]\nurl = https://a\n[ldap]\npassword = a\npassword_env = B": "only one of ldap.password, ldap.password_file",
+		} {
+			_, err := Parse(strings.NewReader(content))
+			assert.Check(t, cmp.ErrorContains(err, expectedErr), content)
+		}
+	})
+}
+
+func Test_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassh")
+
+	_, err := Load(path)
+	assert.Check(t, cmp.ErrorContains(err, "unable to open configuration file"))
+
+	assert.NilError(t, os.WriteFile(path, []byte("[user]\nname = john"), 0o600))
+	_, err = Load(path)
+	assert.Check(t, cmp.ErrorContains(err, "user.url is required"))
+
+	assert.NilError(t, os.WriteFile(path, []byte("[user]\nurl = https://cassh.corp"), 0o600))
+	cfg, err := Load(path)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(cfg.URL, "https://cassh.corp"))
+}
+
+func Test_DefaultPath(t *testing.T) {
+	assert.Check(t, strings.HasSuffix(DefaultPath(), "/.cassh"))
+}
+
+func Test_Config_sessions(t *testing.T) {
+	ctx := context.Background()
+
+	srv, err := casshtest.NewServer(
+		casshtest.ServerOptionLDAPCredentials(map[string]string{"john@corp": "secret"}),
+		casshtest.ServerOptionAdmins("john@corp"),
+	)
+	assert.NilError(t, err)
+	defer srv.Close()
+	srv.SeedUser(casshtest.User{Name: "john", RealName: "john@corp"})
+
+	cfg := &Config{
+		URL:          srv.URL(),
+		Name:         "john",
+		Timeout:      time.Second,
+		Verify:       false,
+		LDAPRealName: "john@corp",
+		LDAPPassword: "secret",
+	}
+
+	client, err := cfg.NewClient()
+	assert.NilError(t, err)
+
+	session, err := cfg.NewSessionUser(client)
+	assert.NilError(t, err)
+	status, err := session.Status(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(status.RealName, "john@corp"))
+
+	assert.NilError(t, cfg.NewSessionAdmin(client).CheckAuthentication(ctx))
+
+	cfg.Name = ""
+	_, err = cfg.NewSessionUser(client)
+	assert.Check(t, cmp.ErrorContains(err, "user.name is required"))
+
+	t.Run("ldap password alternatives", func(t *testing.T) {
+		passwordPath := filepath.Join(t.TempDir(), "password")
+		assert.NilError(t, os.WriteFile(passwordPath, []byte("secret\n"), 0o600))
+		t.Setenv("CASSH_TEST_LDAP_PASSWORD", "secret")
+
+		for name, cfg := range map[string]*Config{
+			"file":    {LDAPPasswordFile: passwordPath},
+			"command": {LDAPPasswordCommand: "echo secret"},
+			"env":     {LDAPPasswordEnv: "CASSH_TEST_LDAP_PASSWORD"},
+		} {
+			cfg.URL, cfg.Name, cfg.Timeout, cfg.LDAPRealName = srv.URL(), "john", time.Second, "john@corp"
+
+			session, err := cfg.NewSessionUser(client)
+			assert.NilError(t, err)
+			_, err = session.Status(ctx)
+			assert.Check(t, err, name)
+			assert.Check(t, cfg.NewSessionAdmin(client).CheckAuthentication(ctx), name)
+		}
+
+		cfg := &Config{Name: "john", LDAPRealName: "john@corp", LDAPPasswordEnv: "CASSH_TEST_UNSET_LDAP_PASSWORD"}
+		session, err := cfg.NewSessionUser(client)
+		assert.NilError(t, err)
+		_, err = session.Status(ctx)
+		assert.Check(t, cmp.ErrorIs(err, cassh.ErrCredentialUnavailable))
+	})
+
+	t.Run("no ldap", func(t *testing.T) {
+		cfg := &Config{}
+		assert.Check(t, cmp.Len(cfg.SessionUserOptions(), 0))
+		assert.Check(t, cmp.Len(cfg.SessionAdminOptions(), 0))
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		cfg := &Config{URL: srv.URL(), Timeout: time.Second, Verify: true}
+		client, err := cfg.NewClient()
+		assert.NilError(t, err)
+		assert.Check(t, cmp.ErrorContains(client.Ping(ctx), "certificate"))
+	})
+
+	t.Run("pinned authorities", func(t *testing.T) {
+		cfg := &Config{URL: srv.URL(), PinnedAuthorities: []string{"SHA256:abc"}}
+		assert.Check(t, cmp.Len(cfg.ClientOptions(), 2))
+
+		client, err := cfg.NewClient(cassh.ClientOptionHTTPClient(srv.HTTPClient()))
+		assert.NilError(t, err)
+		_, err = client.AuthorityPublicKey(ctx)
+		assert.Check(t, cmp.ErrorIs(err, cassh.ErrAuthorityMismatch))
+
+		cfg.AllowUnsignedKRL = true
+		assert.Check(t, cmp.Len(cfg.ClientOptions(), 3))
+	})
+
+	t.Run("invalid client certificate", func(t *testing.T) {
+		cfg := &Config{URL: srv.URL(), SSLCert: "/nope.pem", SSLKey: "/nope.key"}
+		_, err := cfg.NewClient()
+		assert.Check(t, cmp.ErrorContains(err, "unable to load client certificate"))
+	})
+}
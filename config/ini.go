@@ -0,0 +1,83 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// iniFile stores values per section and key, both lower-cased like python's configparser does by default.
+type iniFile map[string]map[string]string
+
+func (f iniFile) get(section, key string) (string, bool) {
+	value, exists := f[section][key]
+	return value, exists
+}
+
+// parseINI parses the subset of python's configparser format used by CASSH configuration files:
+// sections, "key = value" or "key: value" entries, full line comments and indented continuation lines.
+func parseINI(r io.Reader) (iniFile, error) {
+	file := make(iniFile)
+
+	var (
+		section, lastKey string
+		lineNumber       int
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNumber++
+		rawLine := scanner.Text()
+		line := strings.TrimSpace(rawLine)
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case rawLine[0] == ' ' || rawLine[0] == '\t':
+			if lastKey == "" {
+				return nil, fmt.Errorf("line %d: unexpected continuation line", lineNumber)
+			}
+			file[section][lastKey] += "\n" + line
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section header", lineNumber)
+			}
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			lastKey = ""
+			if _, exists := file[section]; !exists {
+				file[section] = make(map[string]string)
+			}
+		default:
+			if section == "" {
+				return nil, fmt.Errorf("line %d: entry outside of any section", lineNumber)
+			}
+
+			separator := strings.IndexAny(line, "=:")
+			if separator <= 0 {
+				return nil, fmt.Errorf("line %d: malformed entry", lineNumber)
+			}
+
+			lastKey = strings.ToLower(strings.TrimSpace(line[:separator]))
+			file[section][lastKey] = strings.TrimSpace(line[separator+1:])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read file: %v", err)
+	}
+
+	return file, nil
+}
+
+// parseINIBool parses booleans the same way python's configparser getboolean does.
+func parseINIBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "yes", "true", "on":
+		return true, nil
+	case "0", "no", "false", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("not a boolean: %q", value)
+	}
+}
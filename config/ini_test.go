@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_parseINI(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		file, err := parseINI(strings.NewReader(`
+# comment
+[User]
+Name = john
+url: https://cassh.corp:8443/
+; other comment
+empty =
+multi = first
+  second
+
+[ldap]
+realname = john@corp
+`))
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(file, iniFile{
+			"user": {
+				"name":  "john",
+				"url":   "https://cassh.corp:8443/",
+				"empty": "",
+				"multi": "first\nsecond",
+			},
+			"ldap": {"realname": "john@corp"},
+		}))
+
+		value, exists := file.get("user", "name")
+		assert.Check(t, exists)
+		assert.Check(t, cmp.Equal(value, "john"))
+
+		_, exists = file.get("nope", "name")
+		assert.Check(t, !exists)
+	})
+
+	t.Run("ko", func(t *testing.T) {
+		for content, expectedErr := range map[string]string{
+			"[user\nname = john":     "line 1: malformed section header",
+			"name = john":            "line 1: entry outside of any section",
+			"[user]\nname":           "line 2: malformed entry",
+			"[user]\n= john":         "line 2: malformed entry",
+			"[user]\n  continuation": "line 2: unexpected continuation line",
+		} {
+			_, err := parseINI(strings.NewReader(content))
+			assert.Check(t, cmp.ErrorContains(err, expectedErr), content)
+		}
+	})
+}
+
+func Test_parseINIBool(t *testing.T) {
+	for _, value := range []string{"1", "yes", "True", "ON"} {
+		b, err := parseINIBool(value)
+		assert.Check(t, err)
+		assert.Check(t, b, value)
+	}
+
+	for _, value := range []string{"0", "No", "false", "off"} {
+		b, err := parseINIBool(value)
+		assert.Check(t, err)
+		assert.Check(t, !b, value)
+	}
+
+	_, err := parseINIBool("maybe")
+	assert.Check(t, cmp.ErrorContains(err, `not a boolean: "maybe"`))
+}
@@ -0,0 +1,237 @@
+package cassh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// Credential stores a name and its password.
+type Credential struct {
+	Name     string
+	Password string
+}
+
+func (c Credential) String() string {
+	return fmt.Sprintf("%s:%s", c.Name, redactSecret(c.Password))
+}
+
+// GoString never prints the password, even with %#v.
+func (c Credential) GoString() string {
+	return fmt.Sprintf("cassh.Credential{Name:%q, Password:%q}", c.Name, redactSecret(c.Password))
+}
+
+// LogValue never logs the password.
+func (c Credential) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("name", c.Name),
+		slog.String("password", redactSecret(c.Password)),
+	)
+}
+
+// CredentialProvider provides credentials each time they are needed, which allows them to change over time.
+type CredentialProvider interface {
+	Credential(ctx context.Context) (*Credential, error)
+}
+
+// CredentialProviderFunc is a function implementing the CredentialProvider interface.
+type CredentialProviderFunc func(ctx context.Context) (*Credential, error)
+
+// Credential implements CredentialProvider.
+func (f CredentialProviderFunc) Credential(ctx context.Context) (*Credential, error) { return f(ctx) }
+
+func (CredentialProviderFunc) String() string { return "func" }
+
+// CredentialProviderStatic always provides the same credential.
+func CredentialProviderStatic(name, password string) CredentialProvider {
+	return credentialProviderStatic{name: name, password: password}
+}
+
+type credentialProviderStatic struct {
+	name     string
+	password string
+}
+
+func (p credentialProviderStatic) Credential(context.Context) (*Credential, error) {
+	return &Credential{Name: p.name, Password: p.password}, nil
+}
+
+func (p credentialProviderStatic) String() string { return "static(" + p.name + ")" }
+
+// CredentialProviderEnv provides the password stored in the provided environment variable,
+// read each time a credential is needed.
+func CredentialProviderEnv(name, passwordVariable string) CredentialProvider {
+	return credentialProviderEnv{name: name, passwordVariable: passwordVariable, lookupEnv: os.LookupEnv}
+}
+
+type credentialProviderEnv struct {
+	name             string
+	passwordVariable string
+	lookupEnv        func(string) (string, bool)
+}
+
+func (p credentialProviderEnv) Credential(context.Context) (*Credential, error) {
+	password, isSet := p.lookupEnv(p.passwordVariable)
+	if !isSet {
+		return nil, fmt.Errorf("%w: environment variable %s is not set", ErrCredentialUnavailable, p.passwordVariable)
+	}
+	return &Credential{Name: p.name, Password: password}, nil
+}
+
+func (p credentialProviderEnv) String() string {
+	return "env(" + p.name + ", $" + p.passwordVariable + ")"
+}
+
+// CredentialProviderFile provides the password stored in the provided file, read each time a credential is needed.
+// The trailing new line, if any, is not part of the password.
+func CredentialProviderFile(name, passwordPath string) CredentialProvider {
+	return credentialProviderFile{name: name, passwordPath: passwordPath}
+}
+
+type credentialProviderFile struct {
+	name         string
+	passwordPath string
+}
+
+func (p credentialProviderFile) Credential(context.Context) (*Credential, error) {
+	raw, err := os.ReadFile(p.passwordPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read password file: %v", ErrCredentialUnavailable, err)
+	}
+	return &Credential{Name: p.name, Password: trimNewLine(string(raw))}, nil
+}
+
+func (p credentialProviderFile) String() string {
+	return "file(" + p.name + ", " + p.passwordPath + ")"
+}
+
+// CredentialProviderTerminal prompts the password on the terminal the first time a credential is needed,
+// and remembers it afterward.
+func CredentialProviderTerminal(name string) CredentialProvider {
+	return &credentialProviderTerminal{
+		name:   name,
+		prompt: os.Stderr,
+		readPassword: func() ([]byte, error) {
+			fd := int(os.Stdin.Fd())
+			if !term.IsTerminal(fd) {
+				return nil, errors.New("standard input is not a terminal")
+			}
+			return term.ReadPassword(fd)
+		},
+	}
+}
+
+type credentialProviderTerminal struct {
+	name         string
+	prompt       io.Writer
+	readPassword func() ([]byte, error)
+
+	m        sync.Mutex
+	password *string
+}
+
+func (p *credentialProviderTerminal) Credential(context.Context) (*Credential, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if p.password == nil {
+		fmt.Fprintf(p.prompt, "Password for %s: ", p.name) //nolint:errcheck // prompt is best effort
+		raw, err := p.readPassword()
+		fmt.Fprintln(p.prompt) //nolint:errcheck // prompt is best effort
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to read password: %v", ErrCredentialUnavailable, err)
+		}
+
+		password := trimNewLine(string(raw))
+		p.password = &password
+	}
+
+	return &Credential{Name: p.name, Password: *p.password}, nil
+}
+
+func (p *credentialProviderTerminal) String() string { return "terminal(" + p.name + ")" }
+
+// CredentialProviderExec provides the credential returned by the provided command, run each time a credential is needed.
+// Like git credential helpers, the command receives "username=<name>" followed by an empty line on its standard input,
+// and may answer "username=" and "password=" lines. When its output has no "key=value" lines at all, its first line
+// is the password.
+func CredentialProviderExec(name, command string, args ...string) CredentialProvider {
+	return credentialProviderExec{name: name, command: command, args: args}
+}
+
+type credentialProviderExec struct {
+	name    string
+	command string
+	args    []string
+}
+
+func (p credentialProviderExec) Credential(ctx context.Context) (*Credential, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, p.command, p.args...) //nolint:gosec // command is provided on purpose
+	cmd.Stdin = strings.NewReader("username=" + p.name + "\n\n")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%v: %s", err, message)
+		}
+		return nil, fmt.Errorf("%w: unable to run %s: %v", ErrCredentialUnavailable, p.command, err)
+	}
+
+	credential := Credential{Name: p.name}
+
+	var (
+		firstLine      *string
+		keyValueIsSent bool
+		passwordIsSent bool
+	)
+
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if firstLine == nil {
+			firstLine = &line
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		keyValueIsSent = keyValueIsSent || found
+
+		switch key {
+		case "username":
+			credential.Name = value
+		case "password":
+			credential.Password = value
+			passwordIsSent = true
+		}
+	}
+
+	switch {
+	case passwordIsSent:
+	case keyValueIsSent:
+		return nil, fmt.Errorf("%w: %s answered without password", ErrCredentialUnavailable, p.command)
+	case firstLine != nil:
+		credential = Credential{Name: p.name, Password: *firstLine}
+	default:
+		return nil, fmt.Errorf("%w: %s did not provide any password", ErrCredentialUnavailable, p.command)
+	}
+
+	return &credential, nil
+}
+
+func (p credentialProviderExec) String() string { return "exec(" + p.name + ", " + p.command + ")" }
+
+func trimNewLine(s string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+}
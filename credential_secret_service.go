@@ -0,0 +1,126 @@
+package cassh
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretServiceName          = "org.freedesktop.secrets"
+	secretServicePath          = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretServiceInterface     = "org.freedesktop.Secret.Service"
+	secretServiceItemInterface = "org.freedesktop.Secret.Item"
+	secretServiceSessionClose  = "org.freedesktop.Secret.Session.Close"
+	secretServiceNoPrompt      = dbus.ObjectPath("/")
+)
+
+// CredentialProviderSecretService provides the password of the OS keyring item matching the provided attributes,
+// looked up each time a credential is needed using the Secret Service D-Bus API (GNOME Keyring, KWallet, KeePassXC, ...).
+// Items stored with `secret-tool store --label=cassh service cassh username john` are found
+// with the {"service": "cassh", "username": "john"} attributes.
+func CredentialProviderSecretService(name string, attributes map[string]string) CredentialProvider {
+	return credentialProviderSecretService{
+		name:       name,
+		attributes: attributes,
+		connect: func() (secretServiceBus, error) {
+			return dbus.SessionBus()
+		},
+	}
+}
+
+// secretServiceBus is the part of the D-Bus connection used to talk to the Secret Service.
+type secretServiceBus interface {
+	Object(dest string, path dbus.ObjectPath) dbus.BusObject
+}
+
+// secretServiceSecret is the Secret struct of the Secret Service API.
+type secretServiceSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+type credentialProviderSecretService struct {
+	name       string
+	attributes map[string]string
+	connect    func() (secretServiceBus, error)
+}
+
+func (p credentialProviderSecretService) Credential(ctx context.Context) (*Credential, error) {
+	bus, err := p.connect()
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to connect to the session bus: %v", ErrCredentialUnavailable, err)
+	}
+
+	service := bus.Object(secretServiceName, secretServicePath)
+
+	item, err := p.searchItem(ctx, service)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCredentialUnavailable, err)
+	}
+
+	var (
+		output  dbus.Variant
+		session dbus.ObjectPath
+	)
+
+	if err := service.CallWithContext(ctx, secretServiceInterface+".OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &session); err != nil {
+		return nil, fmt.Errorf("%w: unable to open secret service session: %v", ErrCredentialUnavailable, err)
+	}
+	defer bus.Object(secretServiceName, session).CallWithContext(ctx, secretServiceSessionClose, 0)
+
+	var secret secretServiceSecret
+
+	if err := bus.Object(secretServiceName, item).CallWithContext(ctx, secretServiceItemInterface+".GetSecret", 0, session).Store(&secret); err != nil {
+		return nil, fmt.Errorf("%w: unable to get secret: %v", ErrCredentialUnavailable, err)
+	}
+
+	return &Credential{Name: p.name, Password: string(secret.Value)}, nil
+}
+
+// searchItem returns the first item matching the attributes, unlocking it if needed and possible without prompting.
+func (p credentialProviderSecretService) searchItem(ctx context.Context, service dbus.BusObject) (dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+
+	if err := service.CallWithContext(ctx, secretServiceInterface+".SearchItems", 0, p.attributes).Store(&unlocked, &locked); err != nil {
+		return "", fmt.Errorf("unable to search items: %v", err)
+	}
+
+	if len(unlocked) > 0 {
+		return unlocked[0], nil
+	}
+
+	if len(locked) == 0 {
+		return "", fmt.Errorf("no keyring item matches %s", p.describeAttributes())
+	}
+
+	var prompt dbus.ObjectPath
+
+	if err := service.CallWithContext(ctx, secretServiceInterface+".Unlock", 0, locked[:1]).Store(&unlocked, &prompt); err != nil {
+		return "", fmt.Errorf("unable to unlock item: %v", err)
+	}
+
+	if len(unlocked) == 0 || prompt != secretServiceNoPrompt {
+		return "", fmt.Errorf("keyring item matching %s is locked", p.describeAttributes())
+	}
+
+	return unlocked[0], nil
+}
+
+func (p credentialProviderSecretService) describeAttributes() string {
+	attributes := make([]string, 0, len(p.attributes))
+	for key, value := range p.attributes {
+		attributes = append(attributes, key+"="+value)
+	}
+	sort.Strings(attributes)
+	return "{" + strings.Join(attributes, ", ") + "}"
+}
+
+func (p credentialProviderSecretService) String() string {
+	return "secret-service(" + p.name + ", " + p.describeAttributes() + ")"
+}
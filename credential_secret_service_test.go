@@ -0,0 +1,183 @@
+package cassh
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type secretServiceTestItem struct {
+	attributes map[string]string
+	secret     string
+	locked     bool
+	unlockable bool
+}
+
+// secretServiceTestBus fakes the Secret Service on a D-Bus session bus.
+type secretServiceTestBus struct {
+	items    map[dbus.ObjectPath]*secretServiceTestItem
+	sessions map[dbus.ObjectPath]bool
+}
+
+func (b *secretServiceTestBus) Object(dest string, path dbus.ObjectPath) dbus.BusObject {
+	return &secretServiceTestObject{bus: b, dest: dest, path: path}
+}
+
+type secretServiceTestObject struct {
+	dbus.BusObject
+	bus  *secretServiceTestBus
+	dest string
+	path dbus.ObjectPath
+}
+
+func (o *secretServiceTestObject) CallWithContext(_ context.Context, method string, _ dbus.Flags, args ...any) *dbus.Call {
+	if o.dest != secretServiceName {
+		return &dbus.Call{Err: errors.New("unknown destination " + o.dest)}
+	}
+
+	switch method {
+	case secretServiceInterface + ".SearchItems":
+		var unlocked, locked []dbus.ObjectPath
+		for path, item := range o.bus.items {
+			if !matchAttributes(item.attributes, args[0].(map[string]string)) {
+				continue
+			}
+			if item.locked {
+				locked = append(locked, path)
+			} else {
+				unlocked = append(unlocked, path)
+			}
+		}
+		return &dbus.Call{Body: []any{unlocked, locked}}
+
+	case secretServiceInterface + ".Unlock":
+		var unlocked []dbus.ObjectPath
+		for _, path := range args[0].([]dbus.ObjectPath) {
+			if item := o.bus.items[path]; item.unlockable {
+				item.locked = false
+				unlocked = append(unlocked, path)
+			}
+		}
+		if len(unlocked) == 0 {
+			return &dbus.Call{Body: []any{unlocked, dbus.ObjectPath("/org/freedesktop/secrets/prompt/p1")}}
+		}
+		return &dbus.Call{Body: []any{unlocked, secretServiceNoPrompt}}
+
+	case secretServiceInterface + ".OpenSession":
+		if args[0] != "plain" {
+			return &dbus.Call{Err: errors.New("unsupported algorithm")}
+		}
+		session := dbus.ObjectPath("/org/freedesktop/secrets/session/s1")
+		o.bus.sessions[session] = true
+		return &dbus.Call{Body: []any{dbus.MakeVariant(""), session}}
+
+	case secretServiceSessionClose:
+		delete(o.bus.sessions, o.path)
+		return &dbus.Call{}
+
+	case secretServiceItemInterface + ".GetSecret":
+		session := args[0].(dbus.ObjectPath)
+		item, exists := o.bus.items[o.path]
+		if !exists || item.locked || !o.bus.sessions[session] {
+			return &dbus.Call{Err: errors.New("no such secret")}
+		}
+		return &dbus.Call{Body: []any{secretServiceSecret{
+			Session:     session,
+			Value:       []byte(item.secret),
+			ContentType: "text/plain",
+		}}}
+
+	default:
+		return &dbus.Call{Err: errors.New("unknown method " + method)}
+	}
+}
+
+func matchAttributes(attributes, search map[string]string) bool {
+	for key, value := range search {
+		if attributes[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func Test_CredentialProviderSecretService(t *testing.T) {
+	ctx := context.Background()
+
+	newProvider := func(bus *secretServiceTestBus, attributes map[string]string) credentialProviderSecretService {
+		provider := CredentialProviderSecretService("john", attributes).(credentialProviderSecretService)
+		provider.connect = func() (secretServiceBus, error) { return bus, nil }
+		return provider
+	}
+
+	bus := &secretServiceTestBus{
+		items: map[dbus.ObjectPath]*secretServiceTestItem{
+			"/org/freedesktop/secrets/collection/login/1": {
+				attributes: map[string]string{"service": "cassh", "username": "john"},
+				secret:     "secret",
+			},
+			"/org/freedesktop/secrets/collection/login/2": {
+				attributes: map[string]string{"service": "cassh", "username": "jane"},
+				secret:     "jane-secret",
+				locked:     true,
+				unlockable: true,
+			},
+			"/org/freedesktop/secrets/collection/login/3": {
+				attributes: map[string]string{"service": "cassh", "username": "jack"},
+				secret:     "jack-secret",
+				locked:     true,
+			},
+		},
+		sessions: make(map[dbus.ObjectPath]bool),
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		provider := newProvider(bus, map[string]string{"service": "cassh", "username": "john"})
+
+		credential, err := provider.Credential(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(credential, &Credential{Name: "john", Password: "secret"}))
+		assert.Check(t, cmp.Len(bus.sessions, 0), "session must be closed")
+
+		bus.items["/org/freedesktop/secrets/collection/login/1"].secret = "rotated"
+		credential, err = provider.Credential(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(credential.Password, "rotated"))
+	})
+
+	t.Run("ok unlocked without prompt", func(t *testing.T) {
+		credential, err := newProvider(bus, map[string]string{"username": "jane"}).Credential(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(credential.Password, "jane-secret"))
+	})
+
+	t.Run("ko locked", func(t *testing.T) {
+		_, err := newProvider(bus, map[string]string{"username": "jack"}).Credential(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "keyring item matching {username=jack} is locked"))
+	})
+
+	t.Run("ko not found", func(t *testing.T) {
+		_, err := newProvider(bus, map[string]string{"service": "cassh", "username": "joe"}).Credential(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "no keyring item matches {service=cassh, username=joe}"))
+	})
+
+	t.Run("ko connection", func(t *testing.T) {
+		provider := newProvider(bus, nil)
+		provider.connect = func() (secretServiceBus, error) { return nil, errors.New("no session bus") }
+
+		_, err := provider.Credential(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "unable to connect to the session bus: no session bus"))
+	})
+
+	t.Run("string", func(t *testing.T) {
+		provider := CredentialProviderSecretService("john", map[string]string{"username": "john", "service": "cassh"})
+		assert.Check(t, cmp.Equal(describeSecretHolder(provider), "secret-service(john, {service=cassh, username=john})"))
+	})
+}
@@ -0,0 +1,166 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_Credential_String(t *testing.T) {
+	credential := Credential{Name: "john", Password: "secret"}
+
+	assert.Check(t, cmp.Equal(fmt.Sprint(credential), "john:[REDACTED]"))
+	assert.Check(t, cmp.Equal(fmt.Sprintf("%+v", &credential), "john:[REDACTED]"))
+	assert.Check(t, cmp.Equal(fmt.Sprintf("%#v", credential), `cassh.Credential{Name:"john", Password:"[REDACTED]"}`))
+
+	var logs bytes.Buffer
+	slog.New(slog.NewTextHandler(&logs, nil)).Info("credential", "credential", credential)
+	assert.Check(t, cmp.Contains(logs.String(), "credential.name=john credential.password=[REDACTED]"))
+	assert.Check(t, !strings.Contains(logs.String(), "secret"))
+}
+
+func Test_CredentialProviderFunc(t *testing.T) {
+	provider := CredentialProviderFunc(func(context.Context) (*Credential, error) {
+		return &Credential{Name: "john", Password: "secret"}, nil
+	})
+
+	credential, err := provider.Credential(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(credential, &Credential{Name: "john", Password: "secret"}))
+	assert.Check(t, cmp.Equal(describeSecretHolder(provider), "func"))
+}
+
+func Test_CredentialProviderStatic(t *testing.T) {
+	provider := CredentialProviderStatic("john", "secret")
+
+	credential, err := provider.Credential(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(credential, &Credential{Name: "john", Password: "secret"}))
+	assert.Check(t, cmp.Equal(describeSecretHolder(provider), "static(john)"))
+}
+
+func Test_CredentialProviderEnv(t *testing.T) {
+	ctx := context.Background()
+	provider := CredentialProviderEnv("john", "CASSH_TEST_PASSWORD")
+	assert.Check(t, cmp.Equal(describeSecretHolder(provider), "env(john, $CASSH_TEST_PASSWORD)"))
+
+	_, err := provider.Credential(ctx)
+	assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+	assert.Check(t, cmp.ErrorContains(err, "environment variable CASSH_TEST_PASSWORD is not set"))
+
+	t.Setenv("CASSH_TEST_PASSWORD", "secret")
+	credential, err := provider.Credential(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(credential, &Credential{Name: "john", Password: "secret"}))
+
+	t.Setenv("CASSH_TEST_PASSWORD", "rotated")
+	credential, err = provider.Credential(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(credential.Password, "rotated"))
+}
+
+func Test_CredentialProviderFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "password")
+	provider := CredentialProviderFile("john", path)
+	assert.Check(t, cmp.Equal(describeSecretHolder(provider), "file(john, "+path+")"))
+
+	_, err := provider.Credential(ctx)
+	assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+	assert.Check(t, cmp.ErrorContains(err, "unable to read password file"))
+
+	assert.NilError(t, os.WriteFile(path, []byte("secret\n"), 0o600))
+	credential, err := provider.Credential(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(credential, &Credential{Name: "john", Password: "secret"}))
+
+	assert.NilError(t, os.WriteFile(path, []byte("rotated\r\n"), 0o600))
+	credential, err = provider.Credential(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(credential.Password, "rotated"))
+}
+
+func Test_CredentialProviderTerminal(t *testing.T) {
+	ctx := context.Background()
+
+	provider := CredentialProviderTerminal("john").(*credentialProviderTerminal)
+	assert.Check(t, cmp.Equal(describeSecretHolder(provider), "terminal(john)"))
+
+	var prompt bytes.Buffer
+	provider.prompt = &prompt
+
+	provider.readPassword = func() ([]byte, error) { return nil, errors.New("boom") }
+	_, err := provider.Credential(ctx)
+	assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+	assert.Check(t, cmp.ErrorContains(err, "boom"))
+
+	reads := 0
+	provider.readPassword = func() ([]byte, error) {
+		reads++
+		return []byte("secret"), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		credential, err := provider.Credential(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(credential, &Credential{Name: "john", Password: "secret"}))
+	}
+
+	assert.Check(t, cmp.Equal(reads, 1), "password must be prompted once")
+	assert.Check(t, cmp.Equal(prompt.String(), "Password for john: \nPassword for john: \n"))
+}
+
+func Test_CredentialProviderExec(t *testing.T) {
+	ctx := context.Background()
+
+	for name, test := range map[string]struct {
+		script   string
+		expected *Credential
+		err      string
+	}{
+		"password only": {
+			script:   `echo secret`,
+			expected: &Credential{Name: "john", Password: "secret"},
+		},
+		"credential helper": {
+			script:   `read -r line; [ "$line" = "username=john" ] && printf 'protocol=https\nusername=john@corp\npassword=secret\n'`,
+			expected: &Credential{Name: "john@corp", Password: "secret"},
+		},
+		"credential helper without password": {
+			script: `printf 'protocol=https\nusername=john@corp\n'`,
+			err:    "sh answered without password",
+		},
+		"failure": {
+			script: `echo boom >&2; exit 1`,
+			err:    "unable to run sh: exit status 1: boom",
+		},
+		"no output": {
+			script: `true`,
+			err:    "sh did not provide any password",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			provider := CredentialProviderExec("john", "sh", "-c", test.script)
+
+			credential, err := provider.Credential(ctx)
+			if test.err != "" {
+				assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+				assert.Check(t, cmp.ErrorContains(err, test.err))
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(credential, test.expected))
+		})
+	}
+
+	assert.Check(t, cmp.Equal(describeSecretHolder(CredentialProviderExec("john", "pass", "show", "ldap")), "exec(john, pass)"))
+}
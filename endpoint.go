@@ -0,0 +1,353 @@
+package cassh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/krostar/httpclient"
+)
+
+// EndpointStrategy defines how the endpoint serving a request is selected among healthy endpoints.
+type EndpointStrategy int
+
+const (
+	// EndpointStrategyPriority always uses the first healthy endpoint, in the order they were provided.
+	EndpointStrategyPriority EndpointStrategy = iota
+	// EndpointStrategyRoundRobin spreads requests over healthy endpoints.
+	EndpointStrategyRoundRobin
+	// EndpointStrategyLowestLatency uses the healthy endpoint answering the fastest to health checks.
+	EndpointStrategyLowestLatency
+)
+
+// String implements fmt.Stringer.
+func (s EndpointStrategy) String() string {
+	switch s {
+	case EndpointStrategyPriority:
+		return "priority"
+	case EndpointStrategyRoundRobin:
+		return "round-robin"
+	case EndpointStrategyLowestLatency:
+		return "lowest-latency"
+	default:
+		return fmt.Sprintf("EndpointStrategy(%d)", int(s))
+	}
+}
+
+// EndpointStatus describes the known state of an endpoint.
+type EndpointStatus struct {
+	// Address is the address of the endpoint, as provided to NewClient or ClientOptionEndpoints.
+	Address string
+	// Healthy is false when the last request or health check made to the endpoint failed.
+	Healthy bool
+	// Latency is the duration of the last successful health check, zero if there was none.
+	Latency time.Duration
+	// LastCheck is the time of the last health check, zero if there was none.
+	LastCheck time.Time
+	// LastError is the reason why the endpoint is unhealthy.
+	LastError error
+}
+
+type endpoint struct {
+	address string
+	url     url.URL
+
+	m         sync.Mutex
+	healthy   bool
+	latency   time.Duration
+	lastCheck time.Time
+	lastError error
+}
+
+func (e *endpoint) status() EndpointStatus {
+	e.m.Lock()
+	defer e.m.Unlock()
+	return EndpointStatus{
+		Address:   e.address,
+		Healthy:   e.healthy,
+		Latency:   e.latency,
+		LastCheck: e.lastCheck,
+		LastError: e.lastError,
+	}
+}
+
+func (e *endpoint) setHealthy(healthy bool, err error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.healthy = healthy
+	e.lastError = err
+}
+
+// endpointsDoer wraps a doer to send each request to one of the endpoints, failing over to the others when it fails.
+type endpointsDoer struct {
+	doer      httpclient.Doer
+	base      url.URL
+	endpoints []*endpoint
+	o         *clientOptions
+
+	next uint64
+
+	checkM    sync.Mutex
+	checking  bool
+	lastCheck time.Time
+	checks    sync.WaitGroup
+}
+
+func newEndpointsDoer(doer httpclient.Doer, base url.URL, addresses []url.URL, o *clientOptions) *endpointsDoer {
+	d := &endpointsDoer{doer: doer, base: base, o: o}
+	for _, address := range addresses {
+		d.endpoints = append(d.endpoints, &endpoint{address: address.String(), url: address, healthy: true})
+	}
+	return d
+}
+
+func (d *endpointsDoer) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	candidates := d.candidates(ctx)
+
+	for i, e := range candidates {
+		endpointReq, err := d.endpointRequest(req, e, i > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := d.doer.Do(endpointReq)
+
+		var failure error
+		switch {
+		case err != nil:
+			failure = err
+		case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+			failure = fmt.Errorf("server answered with status %d", resp.StatusCode)
+		}
+		e.setHealthy(failure == nil, failure)
+
+		if failure == nil || i == len(candidates)-1 || ctx.Err() != nil || !d.canFailover(req, err) {
+			recordServingEndpoint(ctx, e.address)
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+	}
+
+	return nil, errors.New("no endpoint configured")
+}
+
+// canFailover returns whenever the failed request can be sent to another endpoint.
+// Requests of non-idempotent operations are only sent again when they did not reach the server.
+func (d *endpointsDoer) canFailover(req *http.Request, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if op, ok := operationFromContext(req.Context()); ok && op.idempotent() {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// endpointRequest returns the request to send to the provided endpoint.
+func (d *endpointsDoer) endpointRequest(req *http.Request, e *endpoint, resend bool) (*http.Request, error) {
+	endpointReq := req.Clone(req.Context())
+
+	if resend && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		endpointReq.Body = body
+	}
+
+	endpointReq.URL.Scheme = e.url.Scheme
+	endpointReq.URL.Host = e.url.Host
+	endpointReq.URL.User = e.url.User
+	endpointReq.URL.Path = e.url.Path + strings.TrimPrefix(req.URL.Path, d.base.Path)
+	endpointReq.URL.RawPath = ""
+	endpointReq.Host = ""
+
+	return endpointReq, nil
+}
+
+// candidates returns the endpoints to try, in order: healthy ones ordered by the strategy, then unhealthy ones.
+// Their last known health is used, health checks being run in the background.
+func (d *endpointsDoer) candidates(ctx context.Context) []*endpoint {
+	if len(d.endpoints) == 1 {
+		return d.endpoints
+	}
+
+	if d.o.endpointsHealthCheckInterval > 0 {
+		d.checkInBackground(ctx)
+	}
+
+	var healthy, unhealthy []*endpoint
+	latencies := make(map[*endpoint]time.Duration, len(d.endpoints))
+
+	for _, e := range d.endpoints {
+		status := e.status()
+		if status.Healthy {
+			healthy = append(healthy, e)
+			latencies[e] = status.Latency
+		} else {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+
+	switch d.o.endpointsStrategy {
+	case EndpointStrategyRoundRobin:
+		if len(healthy) > 0 {
+			start := int((atomic.AddUint64(&d.next, 1) - 1) % uint64(len(healthy)))
+			healthy = append(healthy[start:len(healthy):len(healthy)], healthy[:start]...)
+		}
+	case EndpointStrategyLowestLatency:
+		sort.SliceStable(healthy, func(i, j int) bool {
+			li, lj := latencies[healthy[i]], latencies[healthy[j]]
+			// endpoints never checked successfully are used last
+			return lj == 0 && li != 0 || (li != 0 && li < lj)
+		})
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// checkInBackground starts a health check of the endpoints when the last one is too old and none is running.
+// The check outlives the request which triggered it: it is not canceled with ctx, only bound by the health check timeout.
+func (d *endpointsDoer) checkInBackground(ctx context.Context) {
+	d.checkM.Lock()
+	defer d.checkM.Unlock()
+
+	if d.checking || d.o.now().Sub(d.lastCheck) < d.o.endpointsHealthCheckInterval {
+		return
+	}
+	d.checking = true
+
+	d.checks.Add(1)
+	go func() {
+		defer d.checks.Done()
+		d.check(context.WithoutCancel(ctx))
+
+		d.checkM.Lock()
+		d.checking = false
+		d.checkM.Unlock()
+	}()
+}
+
+// check pings all endpoints concurrently to update their health and latency.
+func (d *endpointsDoer) check(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, e := range d.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			d.checkEndpoint(ctx, e)
+		}(e)
+	}
+
+	wg.Wait()
+
+	d.checkM.Lock()
+	d.lastCheck = d.o.now()
+	d.checkM.Unlock()
+}
+
+func (d *endpointsDoer) checkEndpoint(ctx context.Context, e *endpoint) {
+	ctx, cancel := context.WithTimeout(ctx, d.o.endpointsHealthCheckTimeout)
+	defer cancel()
+
+	pingURL := e.url
+	pingURL.Path += "/ping"
+
+	ping := func() error {
+		req, err := http.NewRequestWithContext(withOperation(ctx, operationPing), http.MethodGet, pingURL.String(), http.NoBody)
+		if err != nil {
+			return fmt.Errorf("unable to create request: %v", err)
+		}
+
+		resp, err := d.doer.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() //nolint:errcheck // body is fully read
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("server answered with status %d", resp.StatusCode)
+		}
+
+		return nil
+	}
+
+	start := d.o.now()
+	checkErr := ping()
+	latency := d.o.now().Sub(start)
+
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.lastCheck = start
+	e.healthy = checkErr == nil
+	e.lastError = checkErr
+	if checkErr == nil {
+		if latency <= 0 {
+			latency = 1
+		}
+		e.latency = latency
+	}
+}
+
+func (d *endpointsDoer) statuses() []EndpointStatus {
+	statuses := make([]EndpointStatus, len(d.endpoints))
+	for i, e := range d.endpoints {
+		statuses[i] = e.status()
+	}
+	return statuses
+}
+
+// Endpoints returns the known state of the endpoints of the CASSH server.
+func (c *Client) Endpoints() []EndpointStatus {
+	return c.endpoints.statuses()
+}
+
+// CheckEndpoints pings all the endpoints of the CASSH server to update their health and latency, and returns their state.
+func (c *Client) CheckEndpoints(ctx context.Context) []EndpointStatus {
+	c.endpoints.check(ctx)
+
+	return c.endpoints.statuses()
+}
+
+type servingEndpointContextKey struct{}
+
+type servingEndpointRecorder struct {
+	m        sync.Mutex
+	endpoint *string
+}
+
+// ContextWithServingEndpoint returns a context recording, in endpoint, the address of the endpoint
+// which served the last request made with it. It is useful to know which endpoint served a call
+// when several endpoints are configured using ClientOptionEndpoints.
+func ContextWithServingEndpoint(ctx context.Context, endpoint *string) context.Context {
+	return context.WithValue(ctx, servingEndpointContextKey{}, &servingEndpointRecorder{endpoint: endpoint})
+}
+
+func recordServingEndpoint(ctx context.Context, address string) {
+	if recorder, ok := ctx.Value(servingEndpointContextKey{}).(*servingEndpointRecorder); ok {
+		recorder.m.Lock()
+		*recorder.endpoint = address
+		recorder.m.Unlock()
+	}
+}
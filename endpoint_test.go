@@ -0,0 +1,247 @@
+package cassh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type endpointTestDoer struct {
+	m        sync.Mutex
+	statuses map[string]int
+	errs     map[string]error
+	requests []string
+}
+
+func (d *endpointTestDoer) Do(req *http.Request) (*http.Response, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.requests = append(d.requests, req.URL.Host+req.URL.Path)
+
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	if err := d.errs[req.URL.Host]; err != nil {
+		return nil, err
+	}
+
+	status := http.StatusOK
+	if s, ok := d.statuses[req.URL.Host]; ok {
+		status = s
+	}
+
+	return &http.Response{
+		StatusCode:    status,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(`{"name":"cassh","version":"1.0.0"}`)),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func (d *endpointTestDoer) reset() {
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.requests = nil
+}
+
+func newEndpointTestClient(t *testing.T, doer *endpointTestDoer, opts ...ClientOption) *Client {
+	client, err := NewClient("https://a.local", append([]ClientOption{
+		ClientOptionHTTPClient(doer),
+		ClientOptionEndpoints("https://b.local", "https://c.local"),
+		ClientOptionEndpointsHealthCheck(0, time.Second),
+	}, opts...)...)
+	assert.NilError(t, err)
+	return client
+}
+
+func Test_Client_endpoints_priority(t *testing.T) {
+	doer := &endpointTestDoer{statuses: map[string]int{"a.local": http.StatusBadGateway}}
+	client := newEndpointTestClient(t, doer)
+
+	var served string
+	ctx := ContextWithServingEndpoint(context.Background(), &served)
+
+	_, _, err := client.Health(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(served, "https://b.local"))
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"a.local/health", "b.local/health"}))
+
+	statuses := client.Endpoints()
+	assert.Assert(t, cmp.Len(statuses, 3))
+	assert.Check(t, !statuses[0].Healthy)
+	assert.Check(t, cmp.ErrorContains(statuses[0].LastError, "status 502"))
+	assert.Check(t, statuses[1].Healthy)
+
+	doer.reset()
+	_, _, err = client.Health(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"b.local/health"}), "unhealthy endpoint must be avoided")
+
+	doer.statuses = nil
+	statuses = client.CheckEndpoints(context.Background())
+	assert.Check(t, statuses[0].Healthy)
+	assert.Check(t, statuses[0].Latency > 0)
+	assert.Check(t, !statuses[0].LastCheck.IsZero())
+
+	doer.reset()
+	assert.NilError(t, client.Ping(ctx))
+	assert.Check(t, cmp.Equal(served, "https://a.local"))
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"a.local/ping"}))
+}
+
+func Test_Client_endpoints_allFailing(t *testing.T) {
+	doer := &endpointTestDoer{statuses: map[string]int{
+		"a.local": http.StatusBadGateway,
+		"b.local": http.StatusBadGateway,
+		"c.local": http.StatusServiceUnavailable,
+	}}
+	client := newEndpointTestClient(t, doer)
+
+	var served string
+	err := client.Ping(ContextWithServingEndpoint(context.Background(), &served))
+	assert.Check(t, cmp.ErrorIs(err, ErrServerFailure))
+	assert.Check(t, cmp.Len(doer.requests, 3))
+	assert.Check(t, cmp.Equal(served, "https://c.local"))
+}
+
+func Test_Client_endpoints_nonIdempotent(t *testing.T) {
+	ctx := context.Background()
+	key := newVerificationTestSigner(t).PublicKey()
+
+	doer := &endpointTestDoer{statuses: map[string]int{"a.local": http.StatusBadGateway}}
+	client := newEndpointTestClient(t, doer)
+
+	_, err := client.SessionUser("john").Key(key).Sign(ctx, SessionUserKeySignOptionSkipVerification())
+	assert.Check(t, cmp.ErrorIs(err, ErrServerFailure))
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"a.local/client"}), "sign reaching the server must not fail over")
+
+	doer = &endpointTestDoer{
+		statuses: map[string]int{"b.local": http.StatusBadRequest},
+		errs:     map[string]error{"a.local": &net.OpError{Op: "dial", Err: errors.New("connection refused")}},
+	}
+	client = newEndpointTestClient(t, doer)
+
+	_, err = client.SessionUser("john").Key(key).Sign(ctx, SessionUserKeySignOptionSkipVerification())
+	assert.Check(t, cmp.ErrorIs(err, ErrBadRequest))
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"a.local/client", "b.local/client"}), "sign not reaching the server must fail over")
+}
+
+func Test_Client_endpoints_roundRobin(t *testing.T) {
+	doer := &endpointTestDoer{}
+	client := newEndpointTestClient(t, doer, ClientOptionEndpointStrategy(EndpointStrategyRoundRobin))
+
+	for i := 0; i < 4; i++ {
+		assert.NilError(t, client.Ping(context.Background()))
+	}
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"a.local/ping", "b.local/ping", "c.local/ping", "a.local/ping"}))
+}
+
+func Test_Client_endpoints_lowestLatency(t *testing.T) {
+	doer := &endpointTestDoer{}
+	client := newEndpointTestClient(t, doer, ClientOptionEndpointStrategy(EndpointStrategyLowestLatency))
+
+	client.endpoints.endpoints[0].latency = 30 * time.Millisecond
+	client.endpoints.endpoints[2].latency = 10 * time.Millisecond
+
+	assert.NilError(t, client.Ping(context.Background()))
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"c.local/ping"}))
+
+	candidates := client.endpoints.candidates(context.Background())
+	assert.Check(t, cmp.Equal(candidates[0].address, "https://c.local"))
+	assert.Check(t, cmp.Equal(candidates[1].address, "https://a.local"))
+	assert.Check(t, cmp.Equal(candidates[2].address, "https://b.local"), "unchecked endpoints must be used last")
+}
+
+func Test_Client_endpoints_healthCheck(t *testing.T) {
+	doer := &endpointTestDoer{statuses: map[string]int{"a.local": http.StatusServiceUnavailable}}
+	client := newEndpointTestClient(t, doer, ClientOptionEndpointsHealthCheck(time.Minute, time.Second))
+
+	now := time.Now()
+	client.endpoints.o.now = func() time.Time { return now }
+
+	_, _, err := client.Health(context.Background())
+	assert.NilError(t, err)
+	client.endpoints.checks.Wait()
+	assert.Check(t, cmp.Len(doer.requests, 5), "all endpoints must be checked in the background")
+	statuses := client.Endpoints()
+	assert.Check(t, !statuses[0].Healthy)
+	assert.Check(t, statuses[1].Healthy && statuses[2].Healthy)
+	assert.Check(t, cmp.Equal(statuses[1].LastCheck, now))
+
+	doer.reset()
+	_, _, err = client.Health(context.Background())
+	assert.NilError(t, err)
+	client.endpoints.checks.Wait()
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"b.local/health"}))
+
+	now = now.Add(time.Minute)
+	doer.statuses = nil
+	doer.reset()
+	_, _, err = client.Health(context.Background())
+	assert.NilError(t, err)
+	client.endpoints.checks.Wait()
+	assert.Check(t, cmp.Len(doer.requests, 4))
+	assert.Check(t, cmp.Contains(doer.requests, "b.local/health"), "last known health must be used while checking")
+
+	doer.reset()
+	_, _, err = client.Health(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"a.local/health"}))
+}
+
+func Test_Client_endpoints_healthCheck_canceled(t *testing.T) {
+	doer := &endpointTestDoer{}
+	client := newEndpointTestClient(t, doer, ClientOptionEndpointsHealthCheck(time.Minute, time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client.endpoints.candidates(ctx)
+	client.endpoints.checks.Wait()
+
+	for _, status := range client.Endpoints() {
+		assert.Check(t, status.Healthy, status.Address)
+		assert.Check(t, status.LastError == nil, status.Address)
+		assert.Check(t, !status.LastCheck.IsZero(), status.Address)
+	}
+}
+
+func Test_Client_endpoints_path(t *testing.T) {
+	doer := &endpointTestDoer{statuses: map[string]int{"a.local": http.StatusBadGateway}}
+	client, err := NewClient("https://a.local/cassh",
+		ClientOptionHTTPClient(doer),
+		ClientOptionEndpoints("https://b.local/api"),
+		ClientOptionEndpointsHealthCheck(0, 0),
+	)
+	assert.NilError(t, err)
+
+	assert.NilError(t, client.Ping(context.Background()))
+	assert.Check(t, cmp.DeepEqual(doer.requests, []string{"a.local/cassh/ping", "b.local/api/ping"}))
+}
+
+func Test_NewClient_endpoints(t *testing.T) {
+	_, err := NewClient("https://a.local", ClientOptionEndpoints("http://b.local"))
+	assert.Check(t, cmp.ErrorContains(err, "insecure protocol used: http://b.local"))
+
+	_, err = NewClient("https://a.local", ClientOptionEndpoints("://"))
+	assert.Check(t, cmp.ErrorContains(err, "unable to parse server address"))
+}
+
+func Test_EndpointStrategy_String(t *testing.T) {
+	assert.Check(t, cmp.Equal(EndpointStrategyPriority.String(), "priority"))
+	assert.Check(t, cmp.Equal(EndpointStrategyRoundRobin.String(), "round-robin"))
+	assert.Check(t, cmp.Equal(EndpointStrategyLowestLatency.String(), "lowest-latency"))
+	assert.Check(t, cmp.Equal(EndpointStrategy(42).String(), "EndpointStrategy(42)"))
+}
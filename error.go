@@ -0,0 +1,182 @@
+package cassh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/krostar/httpclient"
+)
+
+type sentinelError string
+
+func (err sentinelError) Error() string { return string(err) }
+
+const (
+	// ErrInsufficientPrivileges is returned when the privileges provided to the CASSH server are not sufficient to execute the request successfully.
+	ErrInsufficientPrivileges = sentinelError("insufficient privileges")
+	// ErrBadRequest is returned when the CASSH server refused the request as invalid.
+	ErrBadRequest = sentinelError("bad request")
+	// ErrUserNotFound is returned when the CASSH server does not know the user.
+	ErrUserNotFound = sentinelError("user not found")
+	// ErrKeyPending is returned when the user key has not been activated by an admin yet.
+	ErrKeyPending = sentinelError("key pending")
+	// ErrKeyNotPending is returned when approving or rejecting a user key which is not waiting for an admin approval.
+	ErrKeyNotPending = sentinelError("key not pending")
+	// ErrKeyRevoked is returned when the user key has been revoked.
+	ErrKeyRevoked = sentinelError("key revoked")
+	// ErrServerFailure is returned when the CASSH server failed to process the request on its side.
+	ErrServerFailure = sentinelError("server failure")
+	// ErrCertificateVerification is returned when a certificate returned by the CASSH server cannot be trusted.
+	ErrCertificateVerification = sentinelError("certificate verification failed")
+	// ErrAuthorityMismatch is returned when the CASSH server authority is not one of the pinned authorities.
+	ErrAuthorityMismatch = sentinelError("authority mismatch")
+	// ErrKRLUnavailable is returned when no key revocation list has been cached yet.
+	ErrKRLUnavailable = sentinelError("key revocation list unavailable")
+	// ErrKRLStale is returned when the cached key revocation list has not been refreshed for too long.
+	ErrKRLStale = sentinelError("key revocation list is stale")
+	// ErrCredentialUnavailable is returned when the credential provider failed to provide the credential to authenticate with.
+	ErrCredentialUnavailable = sentinelError("credential unavailable")
+	// ErrUnsupportedByServer is returned when the CASSH server version does not support the request.
+	ErrUnsupportedByServer = sentinelError("unsupported by server")
+)
+
+// APIError is returned when the CASSH server answers a request with a non-successful status.
+// It can be compared to the sentinel errors of this package using errors.Is.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+
+	kinds []error
+}
+
+func newAPIError(method, path string, statusCode int, message string) *APIError {
+	return &APIError{
+		Method:     method,
+		Path:       path,
+		StatusCode: statusCode,
+		Message:    message,
+		kinds:      apiErrorKinds(statusCode, message),
+	}
+}
+
+// apiErrorMaxSize is the maximum size read from the body of failed responses.
+const apiErrorMaxSize = 64 << 10
+
+// apiErrorDoer turns every response with an error status into an APIError,
+// as the API has no fallback handler for the statuses requests do not handle.
+type apiErrorDoer struct {
+	doer httpclient.Doer
+}
+
+func (d apiErrorDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.doer.Do(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	resp.Body = io.NopCloser(io.LimitReader(resp.Body, apiErrorMaxSize))
+
+	return nil, apiErrorResponseHandler(resp)
+}
+
+func apiErrorResponseHandler(resp *http.Response) error {
+	var method, path string
+	if resp.Request != nil {
+		method = resp.Request.Method
+		path = resp.Request.URL.Path
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+
+	return newAPIError(method, path, resp.StatusCode, apiErrorDecodeMessage(body))
+}
+
+// apiErrorDecodeMessage extracts the explanation from the body of a failed response.
+// CASSH mostly answers with plain text, but some proxies and server versions wrap it in JSON.
+func apiErrorDecodeMessage(body []byte) string {
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) == 0 {
+		return ""
+	}
+
+	var message string
+	if err := json.Unmarshal(body, &message); err == nil {
+		return strings.TrimSpace(message)
+	}
+
+	var object struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &object); err == nil {
+		switch {
+		case object.Message != "":
+			return strings.TrimSpace(object.Message)
+		case object.Error != "":
+			return strings.TrimSpace(object.Error)
+		}
+	}
+
+	return string(body)
+}
+
+func apiErrorKinds(statusCode int, message string) []error {
+	var kinds []error
+
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		kinds = append(kinds, ErrInsufficientPrivileges)
+	case statusCode == http.StatusBadRequest:
+		kinds = append(kinds, ErrBadRequest)
+	case statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented:
+		kinds = append(kinds, ErrUnsupportedByServer)
+	case statusCode >= http.StatusInternalServerError:
+		kinds = append(kinds, ErrServerFailure)
+	}
+
+	lowerMessage := strings.ToLower(message)
+
+	for _, match := range []struct {
+		kind     error
+		patterns []string
+	}{
+		{kind: ErrUserNotFound, patterns: []string{"does not exist", "user absent", "unknown user", "user not found"}},
+		{kind: ErrKeyPending, patterns: []string{KeyStatePending.String()}},
+		{kind: ErrKeyRevoked, patterns: []string{KeyStateRevoked.String()}},
+	} {
+		for _, pattern := range match.patterns {
+			if strings.Contains(lowerMessage, strings.ToLower(pattern)) {
+				kinds = append(kinds, match.kind)
+				break
+			}
+		}
+	}
+
+	return kinds
+}
+
+// Error implements error for APIError.
+func (err *APIError) Error() string {
+	message := err.Message
+	if message == "" {
+		message = http.StatusText(err.StatusCode)
+	}
+
+	return fmt.Sprintf("request %s %s failed with status %d: %s", err.Method, err.Path, err.StatusCode, message)
+}
+
+// Is returns whenever the provided target is one of the sentinel errors matching the server response.
+func (err *APIError) Is(target error) bool {
+	for _, kind := range err.kinds {
+		if kind == target { //nolint:errorlint // kinds are sentinels, they are never wrapped
+			return true
+		}
+	}
+	return false
+}
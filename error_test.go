@@ -0,0 +1,141 @@
+package cassh
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_sentinelError_Error(t *testing.T) {
+	assert.Equal(t, sentinelError("foo").Error(), "foo")
+}
+
+func Test_apiErrorResponseHandler(t *testing.T) {
+	err := apiErrorResponseHandler(&http.Response{
+		StatusCode: http.StatusForbidden,
+		Body:       io.NopCloser(strings.NewReader("Status: PENDING\n")),
+		Request:    &http.Request{Method: http.MethodPost, URL: &url.URL{Path: "/client"}},
+	})
+
+	var apiErr *APIError
+	assert.Assert(t, errors.As(err, &apiErr))
+	assert.Check(t, cmp.Equal(apiErr.Method, http.MethodPost))
+	assert.Check(t, cmp.Equal(apiErr.Path, "/client"))
+	assert.Check(t, cmp.Equal(apiErr.StatusCode, http.StatusForbidden))
+	assert.Check(t, cmp.Equal(apiErr.Message, "Status: PENDING"))
+	assert.Check(t, cmp.ErrorIs(err, ErrKeyPending))
+	assert.Check(t, cmp.Error(err, "request POST /client failed with status 403: Status: PENDING"))
+}
+
+func Test_apiErrorDoer(t *testing.T) {
+	var status int
+	doer := apiErrorDoer{doer: errorTestDoer(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(strings.NewReader("teapot")),
+			Request:    req,
+		}
+	})}
+
+	for _, status = range []int{http.StatusOK, http.StatusNotModified} {
+		resp, err := doer.Do(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/ping"}})
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(resp.StatusCode, status))
+	}
+
+	for _, status = range []int{http.StatusBadRequest, http.StatusTeapot, 599} {
+		resp, err := doer.Do(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/ping"}})
+		assert.Check(t, resp == nil)
+
+		var apiErr *APIError
+		assert.Assert(t, errors.As(err, &apiErr))
+		assert.Check(t, cmp.Equal(apiErr.StatusCode, status))
+		assert.Check(t, cmp.Equal(apiErr.Message, "teapot"))
+	}
+}
+
+type errorTestDoer func(req *http.Request) *http.Response
+
+func (d errorTestDoer) Do(req *http.Request) (*http.Response, error) { return d(req), nil }
+
+func Test_apiErrorDecodeMessage(t *testing.T) {
+	for body, expected := range map[string]string{
+		"":                                 "",
+		"  \n":                             "",
+		"Error: No realname option given.": "Error: No realname option given.",
+		`"quoted message"`:                 "quoted message",
+		`{"message": "from message"}`:      "from message",
+		`{"error": "from error"}`:          "from error",
+		`{"status": "ACTIVE"}`:             `{"status": "ACTIVE"}`,
+	} {
+		assert.Check(t, cmp.Equal(apiErrorDecodeMessage([]byte(body)), expected), body)
+	}
+}
+
+func Test_APIError_Is(t *testing.T) {
+	for name, test := range map[string]struct {
+		statusCode int
+		message    string
+		is         []error
+		isNot      []error
+	}{
+		"unauthorized": {
+			statusCode: http.StatusUnauthorized,
+			is:         []error{ErrInsufficientPrivileges},
+			isNot:      []error{ErrBadRequest, ErrServerFailure},
+		},
+		"bad request with user absent": {
+			statusCode: http.StatusBadRequest,
+			message:    "Error : User absent, please create an account.",
+			is:         []error{ErrBadRequest, ErrUserNotFound},
+			isNot:      []error{ErrKeyPending, ErrKeyRevoked},
+		},
+		"user does not exists": {
+			statusCode: http.StatusBadRequest,
+			message:    "User does not exists.",
+			is:         []error{ErrUserNotFound},
+		},
+		"key revoked": {
+			statusCode: http.StatusForbidden,
+			message:    "Status: REVOKED",
+			is:         []error{ErrKeyRevoked},
+			isNot:      []error{ErrKeyPending, ErrBadRequest},
+		},
+		"method not allowed": {
+			statusCode: http.StatusMethodNotAllowed,
+			is:         []error{ErrUnsupportedByServer},
+			isNot:      []error{ErrBadRequest, ErrServerFailure},
+		},
+		"not implemented": {
+			statusCode: http.StatusNotImplemented,
+			is:         []error{ErrUnsupportedByServer},
+			isNot:      []error{ErrServerFailure},
+		},
+		"server failure": {
+			statusCode: http.StatusBadGateway,
+			is:         []error{ErrServerFailure},
+			isNot:      []error{ErrInsufficientPrivileges},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := newAPIError(http.MethodGet, "/", test.statusCode, test.message)
+			for _, target := range test.is {
+				assert.Check(t, cmp.ErrorIs(err, target))
+			}
+			for _, target := range test.isNot {
+				assert.Check(t, !errors.Is(err, target), target)
+			}
+		})
+	}
+}
+
+func Test_APIError_Error(t *testing.T) {
+	assert.Check(t, cmp.Error(newAPIError(http.MethodPut, "/client", http.StatusBadGateway, ""), "request PUT /client failed with status 502: Bad Gateway"))
+	assert.Check(t, cmp.Error(newAPIError(http.MethodPut, "/client", http.StatusBadRequest, "boom"), "request PUT /client failed with status 400: boom"))
+}
@@ -1,6 +1,6 @@
 module github.com/krostar/cassh
 
-go 1.19
+go 1.21
 
 require (
 	github.com/krostar/httpclient v0.2.0
@@ -9,14 +9,24 @@ require (
 )
 
 require (
-	github.com/google/go-cmp v0.5.9
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/google/go-cmp v0.6.0
 	github.com/stripe/krl v0.0.0-20220202203423-9dc12b164150
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/metric v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/sdk/metric v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+	golang.org/x/term v0.21.0
 	gotest.tools/v3 v3.4.0
 )
 
 require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/exp v0.0.0-20230116083435-1de6713980de // indirect
-	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 )
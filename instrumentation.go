@@ -0,0 +1,167 @@
+package cassh
+
+import (
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/krostar/httpclient"
+)
+
+const instrumentationName = "github.com/krostar/cassh"
+
+// instrumentedDoer wraps a doer to log, trace, and measure requests made to the CASSH server.
+type instrumentedDoer struct {
+	doer   httpclient.Doer
+	o      *instrumentationOptions
+	tracer trace.Tracer
+
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newInstrumentedDoer(doer httpclient.Doer, o *instrumentationOptions) (*instrumentedDoer, error) {
+	meter := o.meterProvider.Meter(instrumentationName)
+
+	requests, err := meter.Int64Counter("cassh.client.requests",
+		metric.WithDescription("Number of calls made to the CASSH server."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := meter.Float64Histogram("cassh.client.request.duration",
+		metric.WithDescription("Duration of calls made to the CASSH server, retries included."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedDoer{
+		doer:     doer,
+		o:        o,
+		tracer:   o.tracerProvider.Tracer(instrumentationName),
+		requests: requests,
+		duration: duration,
+	}, nil
+}
+
+func (d *instrumentedDoer) Do(req *http.Request) (*http.Response, error) {
+	name := "cassh.request"
+	if op, ok := operationFromContext(req.Context()); ok {
+		name = string(op)
+	}
+
+	// metrics attributes are kept to a bounded set of values, usernames are only set on spans and logs
+	attributes := []attribute.KeyValue{
+		attribute.String("cassh.operation", name),
+		attribute.String("http.request.method", req.Method),
+	}
+
+	spanAttributes := []attribute.KeyValue{attribute.String("url.path", req.URL.Path)}
+	if username := operationUsernameFromContext(req.Context()); username != "" {
+		spanAttributes = append(spanAttributes, attribute.String("cassh.username", username.String()))
+	}
+
+	ctx, span := d.tracer.Start(req.Context(), name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+		trace.WithAttributes(spanAttributes...),
+	)
+	defer span.End()
+
+	start := d.o.now()
+	resp, err := d.doer.Do(req.WithContext(ctx))
+	duration := d.o.now().Sub(start)
+
+	level := slog.LevelDebug
+
+	switch {
+	case err != nil:
+		level = slog.LevelError
+		attributes = append(attributes, attribute.String("error.type", "request"))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case resp.StatusCode >= 400:
+		level = slog.LevelWarn
+		if resp.StatusCode >= 500 {
+			level = slog.LevelError
+		}
+		attributes = append(attributes, attribute.String("error.type", strconv.Itoa(resp.StatusCode)))
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	if resp != nil {
+		attributes = append(attributes, attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.Request != nil {
+			attributes = append(attributes, attribute.String("server.address", resp.Request.URL.Host))
+		}
+	}
+
+	span.SetAttributes(attributes...)
+	d.requests.Add(ctx, 1, metric.WithAttributes(attributes...))
+	d.duration.Record(ctx, duration.Seconds(), metric.WithAttributes(attributes...))
+
+	if d.o.logger.Enabled(ctx, level) {
+		logAttributes := []slog.Attr{
+			slog.String("operation", name),
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.Duration("duration", duration),
+		}
+		if username := operationUsernameFromContext(req.Context()); username != "" {
+			logAttributes = append(logAttributes, slog.String("username", username.String()))
+		}
+		if parameters := requestParameters(req); parameters != nil {
+			logAttributes = append(logAttributes, slog.Any("parameters", RedactRequestParameters(parameters)))
+		}
+		if resp != nil {
+			logAttributes = append(logAttributes, slog.Int("status_code", resp.StatusCode))
+		}
+		if err != nil {
+			logAttributes = append(logAttributes, slog.String("error", err.Error()))
+		}
+		d.o.logger.LogAttrs(ctx, level, "cassh request", logAttributes...)
+	}
+
+	return resp, err
+}
+
+// requestParameters returns the form parameters sent by the request, if any.
+func requestParameters(req *http.Request) url.Values {
+	if req.GetBody == nil {
+		return nil
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != "application/x-www-form-urlencoded" {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close() //nolint:errcheck // body is fully read
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil
+	}
+
+	parameters, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return nil
+	}
+
+	return parameters
+}
@@ -0,0 +1,53 @@
+package cassh
+
+import (
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationOption defines the signature of all options usable on ClientOptionInstrumentation.
+type InstrumentationOption func(o *instrumentationOptions)
+
+type instrumentationOptions struct {
+	logger         *slog.Logger
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+
+	now func() time.Time
+}
+
+func instrumentationOptionsDefaults() *instrumentationOptions {
+	return &instrumentationOptions{
+		logger:         slog.Default(),
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		now:            time.Now,
+	}
+}
+
+// InstrumentationOptionLogger sets the logger used to log requests. It defaults to slog.Default().
+func InstrumentationOptionLogger(logger *slog.Logger) InstrumentationOption {
+	return func(o *instrumentationOptions) {
+		o.logger = logger
+	}
+}
+
+// InstrumentationOptionTracerProvider sets the provider of the tracer used to create spans.
+// It defaults to the global OpenTelemetry tracer provider.
+func InstrumentationOptionTracerProvider(tracerProvider trace.TracerProvider) InstrumentationOption {
+	return func(o *instrumentationOptions) {
+		o.tracerProvider = tracerProvider
+	}
+}
+
+// InstrumentationOptionMeterProvider sets the provider of the meter used to record metrics.
+// It defaults to the global OpenTelemetry meter provider.
+func InstrumentationOptionMeterProvider(meterProvider metric.MeterProvider) InstrumentationOption {
+	return func(o *instrumentationOptions) {
+		o.meterProvider = meterProvider
+	}
+}
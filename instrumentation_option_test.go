@@ -0,0 +1,39 @@
+package cassh
+
+import (
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric/noop"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"gotest.tools/v3/assert"
+)
+
+func Test_instrumentationOptionsDefaults(t *testing.T) {
+	opts := instrumentationOptionsDefaults()
+	assert.Check(t, opts.logger == slog.Default())
+	assert.Check(t, opts.tracerProvider != nil)
+	assert.Check(t, opts.meterProvider != nil)
+	assert.Check(t, opts.now != nil)
+}
+
+func Test_InstrumentationOptionLogger(t *testing.T) {
+	logger := slog.New(slog.Default().Handler())
+	opts := instrumentationOptionsDefaults()
+	InstrumentationOptionLogger(logger)(opts)
+	assert.Check(t, opts.logger == logger)
+}
+
+func Test_InstrumentationOptionTracerProvider(t *testing.T) {
+	tracerProvider := tracenoop.NewTracerProvider()
+	opts := instrumentationOptionsDefaults()
+	InstrumentationOptionTracerProvider(tracerProvider)(opts)
+	assert.Check(t, opts.tracerProvider == tracerProvider)
+}
+
+func Test_InstrumentationOptionMeterProvider(t *testing.T) {
+	meterProvider := noop.NewMeterProvider()
+	opts := instrumentationOptionsDefaults()
+	InstrumentationOptionMeterProvider(meterProvider)(opts)
+	assert.Check(t, opts.meterProvider == meterProvider)
+}
@@ -0,0 +1,117 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_instrumentedDoer(t *testing.T) {
+	ctx := context.Background()
+
+	var logs bytes.Buffer
+	spans := tracetest.NewSpanRecorder()
+	metrics := sdkmetric.NewManualReader()
+
+	doer := &retryTestDoer{responses: []retryTestResponse{{status: http.StatusOK}, {status: http.StatusBadRequest}}}
+	client, err := NewClient("https://cassh.local",
+		ClientOptionHTTPClient(doer),
+		ClientOptionInstrumentation(
+			InstrumentationOptionLogger(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+			InstrumentationOptionTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+			InstrumentationOptionMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(metrics))),
+		),
+	)
+	assert.NilError(t, err)
+
+	assert.NilError(t, client.Ping(ctx))
+
+	key := newVerificationTestSigner(t).PublicKey()
+	_, err = client.
+		SessionUser("john", SessionUserOptionAuthenticationMechanismLDAP("john@corp", "secret")).
+		Key(key).
+		Sign(ctx, SessionUserKeySignOptionSkipVerification())
+	assert.Check(t, cmp.ErrorIs(err, ErrBadRequest))
+
+	t.Run("logs", func(t *testing.T) {
+		assert.Check(t, !bytes.Contains(logs.Bytes(), []byte("secret")), "password must be redacted")
+		assert.Check(t, !bytes.Contains(logs.Bytes(), []byte(ssh.MarshalAuthorizedKey(key)[:30])), "public key must be redacted")
+
+		decoder := json.NewDecoder(&logs)
+
+		var record map[string]any
+		assert.NilError(t, decoder.Decode(&record))
+		assert.Check(t, cmp.Equal(record["level"], "DEBUG"))
+		assert.Check(t, cmp.Equal(record["operation"], "cassh.ping"))
+		assert.Check(t, cmp.Equal(record["status_code"], float64(http.StatusOK)))
+
+		record = nil
+		assert.NilError(t, decoder.Decode(&record))
+		assert.Check(t, cmp.Equal(record["level"], "WARN"))
+		assert.Check(t, cmp.Equal(record["operation"], "cassh.user.key.sign"))
+		assert.Check(t, cmp.Equal(record["username"], "john"))
+		assert.Check(t, cmp.DeepEqual(record["parameters"], map[string]any{
+			"username": []any{"john"},
+			"realname": []any{"john@corp"},
+			"password": []any{"[REDACTED]"},
+			"pubkey":   []any{"[REDACTED]"},
+		}))
+	})
+
+	t.Run("spans", func(t *testing.T) {
+		ended := spans.Ended()
+		assert.Assert(t, cmp.Len(ended, 2))
+
+		assert.Check(t, cmp.Equal(ended[0].Name(), "cassh.ping"))
+		assert.Check(t, cmp.Equal(ended[0].Status().Code, codes.Unset))
+
+		assert.Check(t, cmp.Equal(ended[1].Name(), "cassh.user.key.sign"))
+		assert.Check(t, cmp.Equal(ended[1].Status().Code, codes.Error))
+
+		attributes := attribute.NewSet(ended[1].Attributes()...)
+		username, _ := attributes.Value("cassh.username")
+		assert.Check(t, cmp.Equal(username.AsString(), "john"))
+		statusCode, _ := attributes.Value("http.response.status_code")
+		assert.Check(t, cmp.Equal(statusCode.AsInt64(), int64(http.StatusBadRequest)))
+		serverAddress, _ := attributes.Value("server.address")
+		assert.Check(t, cmp.Equal(serverAddress.AsString(), "cassh.local"))
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		var data metricdata.ResourceMetrics
+		assert.NilError(t, metrics.Collect(ctx, &data))
+		assert.Assert(t, cmp.Len(data.ScopeMetrics, 1))
+
+		byName := make(map[string]metricdata.Metrics)
+		for _, m := range data.ScopeMetrics[0].Metrics {
+			byName[m.Name] = m
+		}
+
+		requests, ok := byName["cassh.client.requests"].Data.(metricdata.Sum[int64])
+		assert.Assert(t, ok)
+		assert.Check(t, cmp.Len(requests.DataPoints, 2))
+		for _, point := range requests.DataPoints {
+			_, hasUsername := point.Attributes.Value("cassh.username")
+			assert.Check(t, !hasUsername, "usernames must not be metrics attributes")
+			serverAddress, _ := point.Attributes.Value("server.address")
+			assert.Check(t, cmp.Equal(serverAddress.AsString(), "cassh.local"))
+		}
+
+		duration, ok := byName["cassh.client.request.duration"].Data.(metricdata.Histogram[float64])
+		assert.Assert(t, ok)
+		assert.Check(t, cmp.Len(duration.DataPoints, 2))
+	})
+}
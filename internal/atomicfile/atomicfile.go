@@ -0,0 +1,45 @@
+// Package atomicfile writes files in a way readers never observe a partially written content.
+package atomicfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to a temporary file in the same directory as path, then renames it to path.
+// The file ends up with the provided permissions regardless of the process umask.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %v", err)
+	}
+
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath) //nolint:errcheck // file does not exist anymore on success
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to write temporary file: %v", err)
+	}
+
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to set temporary file permissions: %v", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to sync temporary file: %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close temporary file: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("unable to replace file: %v", err)
+	}
+
+	return nil
+}
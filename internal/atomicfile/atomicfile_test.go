@@ -0,0 +1,33 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_WriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+
+	assert.NilError(t, WriteFile(path, []byte("first"), 0o600))
+	assert.NilError(t, WriteFile(path, []byte("second"), 0o644))
+
+	content, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(content), "second"))
+
+	info, err := os.Stat(path)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(info.Mode().Perm(), os.FileMode(0o644)))
+
+	entries, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(entries, 1), "temporary files must be cleaned up")
+
+	err = WriteFile(filepath.Join(dir, "nope", "file"), []byte("x"), 0o600)
+	assert.Check(t, cmp.ErrorContains(err, "unable to create temporary file"))
+}
@@ -0,0 +1,288 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/krostar/cassh/internal/atomicfile"
+	"github.com/krostar/httpclient"
+)
+
+// KRLCache creates a cache of the key revocation list, persisted at the provided path.
+// The file is the raw list, which can be used as is by sshd as RevokedKeys;
+// the metadata needed for conditional requests is stored next to it, with a ".meta" suffix.
+func (c *Client) KRLCache(path string, opts ...KRLCacheOption) *KRLCache {
+	o := krlCacheOptionsDefaults()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &KRLCache{
+		api:          c.api.Clone(),
+		checkSigners: c.checkKeyRevocationListSigners,
+		path:         path,
+		o:            o,
+	}
+}
+
+// KRLCache keeps the last known key revocation list in memory and on disk,
+// and only downloads it again when it changed.
+// When the CASSH server cannot be reached, the last known list keeps being used.
+// It is safe to use it concurrently.
+type KRLCache struct {
+	api          *httpclient.API
+	checkSigners func(*krl.KRL) error
+	path         string
+	o            *krlCacheOptions
+
+	refreshM sync.Mutex
+
+	m        sync.RWMutex
+	loaded   bool
+	list     *krl.KRL
+	raw      []byte
+	metadata krlCacheMetadata
+}
+
+type krlCacheMetadata struct {
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+	RefreshedAt  time.Time `json:"refreshed_at"`
+}
+
+// KRLCacheRefresh describes the outcome of a refresh made by KRLCache.Run.
+type KRLCacheRefresh struct {
+	// Changed is true when a new list has been stored.
+	Changed bool
+	// Version is the version of the cached list, zero if there is none.
+	Version uint64
+	// Err is set when the refresh failed, in which case the previous list is kept.
+	Err error
+}
+
+func (c *KRLCache) metadataPath() string { return c.path + ".meta" }
+
+// loadLocked reads the list stored on disk, the first time it is called.
+// A missing list is not an error. It must be called with c.m locked for writing.
+func (c *KRLCache) loadLocked() error {
+	if c.loaded {
+		return nil
+	}
+
+	raw, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read cached key revocation list: %v", err)
+	}
+
+	list, err := krl.ParseKRL(raw)
+	if err != nil {
+		return fmt.Errorf("unable to parse cached key revocation list: %v", err)
+	}
+
+	var metadata krlCacheMetadata
+	if rawMetadata, err := os.ReadFile(c.metadataPath()); err == nil {
+		// metadata only allow conditional requests and staleness checks, a corrupted file is not a problem
+		_ = json.Unmarshal(rawMetadata, &metadata)
+	}
+
+	c.list = list
+	c.raw = raw
+	c.metadata = metadata
+	c.loaded = true
+
+	return nil
+}
+
+func (c *KRLCache) saveMetadataLocked() error {
+	raw, err := json.Marshal(c.metadata)
+	if err != nil {
+		return fmt.Errorf("unable to marshal key revocation list metadata: %v", err)
+	}
+
+	if err := atomicfile.WriteFile(c.metadataPath(), raw, 0o644); err != nil { //nolint:gosec // metadata are not sensitive
+		return fmt.Errorf("unable to write key revocation list metadata: %v", err)
+	}
+
+	return nil
+}
+
+// Refresh asks the CASSH server for the key revocation list, if it changed since the last call,
+// and stores it if it differs from the cached one. It returns whenever a new list has been stored.
+// Lists older than the cached one are refused, unless one of them is unversioned, like the lists generated
+// by ssh-keygen without -z. On failure, the previous list is kept.
+func (c *KRLCache) Refresh(ctx context.Context) (bool, error) {
+	c.refreshM.Lock()
+	defer c.refreshM.Unlock()
+
+	c.m.Lock()
+	// an unreadable cache is replaced by the downloaded list, and must not prevent the refresh
+	_ = c.loadLocked()
+	metadata := c.metadata
+	if c.list == nil {
+		metadata = krlCacheMetadata{}
+	}
+	c.m.Unlock()
+
+	req := c.api.Get("/krl")
+	if metadata.ETag != "" {
+		req.SetHeader("If-None-Match", metadata.ETag)
+	}
+	if metadata.LastModified != "" {
+		req.SetHeader("If-Modified-Since", metadata.LastModified)
+	}
+
+	var (
+		raw         []byte
+		notModified bool
+	)
+
+	if err := c.api.
+		Do(withOperation(ctx, operationKRL), req).
+		BodySizeReadLimit(krlMaxSize).
+		OnStatus(http.StatusOK, func(resp *http.Response) error {
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return fmt.Errorf("unable to read body: %v", err)
+			}
+			raw = body
+			metadata.ETag = resp.Header.Get("ETag")
+			metadata.LastModified = resp.Header.Get("Last-Modified")
+			return nil
+		}).
+		OnStatus(http.StatusNotModified, func(*http.Response) error {
+			notModified = true
+			return nil
+		}).
+		Error(); err != nil {
+		return false, err
+	}
+
+	var list *krl.KRL
+	if !notModified {
+		var err error
+		if list, err = krl.ParseKRL(raw); err != nil {
+			return false, fmt.Errorf("unable to parse body as krl: %v", err)
+		}
+		if err := c.checkSigners(list); err != nil {
+			return false, err
+		}
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	metadata.RefreshedAt = c.o.now()
+
+	if list == nil || (c.list != nil && bytes.Equal(raw, c.raw)) {
+		c.metadata = metadata
+		return false, c.saveMetadataLocked()
+	}
+
+	if c.list != nil && list.Version != 0 && c.list.Version != 0 && list.Version < c.list.Version {
+		return false, fmt.Errorf("refusing key revocation list version %d older than cached version %d", list.Version, c.list.Version)
+	}
+
+	if err := atomicfile.WriteFile(c.path, raw, 0o644); err != nil { //nolint:gosec // revocation lists are meant to be read by sshd
+		return false, fmt.Errorf("unable to write key revocation list: %v", err)
+	}
+
+	c.list = list
+	c.raw = raw
+	c.metadata = metadata
+	c.loaded = true
+
+	return true, c.saveMetadataLocked()
+}
+
+// KRL returns the cached key revocation list, loading it from disk if needed.
+// ErrKRLUnavailable is returned if there is none, and ErrKRLStale if it is older than the maximum staleness.
+func (c *KRLCache) KRL() (*krl.KRL, error) {
+	c.m.RLock()
+	loaded, list, refreshedAt := c.loaded, c.list, c.metadata.RefreshedAt
+	c.m.RUnlock()
+
+	if !loaded {
+		c.m.Lock()
+		err := c.loadLocked()
+		list, refreshedAt = c.list, c.metadata.RefreshedAt
+		c.m.Unlock()
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrKRLUnavailable, err)
+		}
+	}
+
+	if list == nil {
+		return nil, ErrKRLUnavailable
+	}
+
+	if c.o.maxStaleness > 0 && c.o.now().Sub(refreshedAt) > c.o.maxStaleness {
+		return nil, fmt.Errorf("%w: last refreshed at %s", ErrKRLStale, refreshedAt.Format(time.RFC3339))
+	}
+
+	return list, nil
+}
+
+// IsRevoked returns whenever the key, or certificate, is revoked by the cached key revocation list.
+// Use Revocation to know why.
+func (c *KRLCache) IsRevoked(key ssh.PublicKey) (bool, error) {
+	revocation, err := c.Revocation(key)
+	if err != nil {
+		return false, err
+	}
+	return revocation != nil, nil
+}
+
+// Revocation returns the entry of the cached key revocation list that revokes the key, or certificate,
+// or nil if it is not revoked.
+func (c *KRLCache) Revocation(key ssh.PublicKey) (*Revocation, error) {
+	list, err := c.KRL()
+	if err != nil {
+		return nil, err
+	}
+	return CheckRevocation(list, key), nil
+}
+
+// Run refreshes the key revocation list periodically until the context is done.
+// It always returns the context error.
+func (c *KRLCache) Run(ctx context.Context) error {
+	for {
+		changed, err := c.Refresh(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		refresh := KRLCacheRefresh{Changed: changed, Err: err}
+		c.m.RLock()
+		if c.list != nil {
+			refresh.Version = c.list.Version
+		}
+		c.m.RUnlock()
+		c.o.onRefresh(refresh)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.o.after(c.o.refreshInterval):
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package cassh
+
+import (
+	"time"
+)
+
+// KRLCacheOption defines the signature of all options usable on Client.KRLCache.
+type KRLCacheOption func(o *krlCacheOptions)
+
+type krlCacheOptions struct {
+	refreshInterval time.Duration
+	maxStaleness    time.Duration
+	onRefresh       func(KRLCacheRefresh)
+
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+func krlCacheOptionsDefaults() *krlCacheOptions {
+	return &krlCacheOptions{
+		refreshInterval: time.Minute,
+		onRefresh:       func(KRLCacheRefresh) {},
+		now:             time.Now,
+		after:           time.After,
+	}
+}
+
+// KRLCacheOptionRefreshInterval sets the delay between two refreshes made by KRLCache.Run. It defaults to a minute.
+func KRLCacheOptionRefreshInterval(refreshInterval time.Duration) KRLCacheOption {
+	return func(o *krlCacheOptions) {
+		o.refreshInterval = refreshInterval
+	}
+}
+
+// KRLCacheOptionMaxStaleness sets how long the cached list is used when it cannot be refreshed,
+// after which lookups fail with ErrKRLStale. By default, the last known list is used forever.
+func KRLCacheOptionMaxStaleness(maxStaleness time.Duration) KRLCacheOption {
+	return func(o *krlCacheOptions) {
+		o.maxStaleness = maxStaleness
+	}
+}
+
+// KRLCacheOptionOnRefresh sets a callback called synchronously after each refresh made by KRLCache.Run.
+func KRLCacheOptionOnRefresh(onRefresh func(KRLCacheRefresh)) KRLCacheOption {
+	return func(o *krlCacheOptions) {
+		o.onRefresh = onRefresh
+	}
+}
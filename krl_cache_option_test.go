@@ -0,0 +1,38 @@
+package cassh
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_krlCacheOptionsDefaults(t *testing.T) {
+	opts := krlCacheOptionsDefaults()
+	assert.Check(t, cmp.Equal(opts.refreshInterval, time.Minute))
+	assert.Check(t, cmp.Equal(opts.maxStaleness, time.Duration(0)))
+	assert.Check(t, opts.onRefresh != nil)
+	assert.Check(t, opts.now != nil)
+	assert.Check(t, opts.after != nil)
+}
+
+func Test_KRLCacheOptionRefreshInterval(t *testing.T) {
+	opts := krlCacheOptionsDefaults()
+	KRLCacheOptionRefreshInterval(time.Hour)(opts)
+	assert.Check(t, cmp.Equal(opts.refreshInterval, time.Hour))
+}
+
+func Test_KRLCacheOptionMaxStaleness(t *testing.T) {
+	opts := krlCacheOptionsDefaults()
+	KRLCacheOptionMaxStaleness(time.Hour)(opts)
+	assert.Check(t, cmp.Equal(opts.maxStaleness, time.Hour))
+}
+
+func Test_KRLCacheOptionOnRefresh(t *testing.T) {
+	var called bool
+	opts := krlCacheOptionsDefaults()
+	KRLCacheOptionOnRefresh(func(KRLCacheRefresh) { called = true })(opts)
+	opts.onRefresh(KRLCacheRefresh{})
+	assert.Check(t, called)
+}
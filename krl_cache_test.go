@@ -0,0 +1,413 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type krlCacheTestDoer struct {
+	t *testing.T
+
+	m        sync.Mutex
+	list     *krl.KRL
+	signer   ssh.Signer
+	fail     bool
+	requests []http.Header
+
+	// raw, when set, is always answered, without conditional request headers
+	raw []byte
+}
+
+func (d *krlCacheTestDoer) revoke(key ssh.PublicKey, version uint64) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	section := krl.KRLExplicitKeySection{key}
+	if d.list != nil {
+		for _, s := range d.list.Sections {
+			section = append(section, *(s.(*krl.KRLExplicitKeySection))...)
+		}
+	}
+	d.list = &krl.KRL{Version: version, Sections: []krl.KRLSection{&section}}
+}
+
+func (d *krlCacheTestDoer) Do(req *http.Request) (*http.Response, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.requests = append(d.requests, req.Header.Clone())
+
+	resp := &http.Response{Header: make(http.Header), Body: http.NoBody, ContentLength: -1, Request: req}
+
+	if d.fail {
+		resp.StatusCode = http.StatusBadGateway
+		return resp, nil
+	}
+
+	if d.raw != nil {
+		resp.StatusCode = http.StatusOK
+		resp.Body = io.NopCloser(bytes.NewReader(d.raw))
+		return resp, nil
+	}
+
+	etag := strconv.Quote(strconv.FormatUint(d.list.Version, 10))
+	resp.Header.Set("ETag", etag)
+	resp.Header.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+
+	if req.Header.Get("If-None-Match") == etag {
+		resp.StatusCode = http.StatusNotModified
+		return resp, nil
+	}
+
+	var signers []ssh.Signer
+	if d.signer != nil {
+		signers = append(signers, d.signer)
+	}
+
+	raw, err := d.list.Marshal(rand.Reader, signers...)
+	assert.NilError(d.t, err)
+
+	resp.StatusCode = http.StatusOK
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	return resp, nil
+}
+
+// marshalTestKRL marshals the list with the provided version, which can be zero unlike with krl.KRL.Marshal.
+func marshalTestKRL(t *testing.T, version uint64, keys ...ssh.PublicKey) []byte {
+	section := krl.KRLExplicitKeySection(keys)
+	list := &krl.KRL{Version: 1, GeneratedDate: 1700000000, Sections: []krl.KRLSection{&section}}
+
+	raw, err := list.Marshal(rand.Reader)
+	assert.NilError(t, err)
+
+	// the version follows the magic and the format version in the header
+	binary.BigEndian.PutUint64(raw[12:20], version)
+	return raw
+}
+
+func newTestKRLCache(t *testing.T, doer *krlCacheTestDoer, opts ...KRLCacheOption) (*Client, *KRLCache, string) {
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "revoked_keys")
+	return client, client.KRLCache(path, opts...), path
+}
+
+func Test_KRLCache_Refresh(t *testing.T) {
+	ctx := context.Background()
+	revoked := newVerificationTestSigner(t).PublicKey()
+	other := newVerificationTestSigner(t).PublicKey()
+
+	doer := &krlCacheTestDoer{t: t}
+	doer.revoke(revoked, 1)
+
+	client, cache, path := newTestKRLCache(t, doer)
+
+	_, err := cache.IsRevoked(revoked)
+	assert.Check(t, cmp.ErrorIs(err, ErrKRLUnavailable))
+
+	changed, err := cache.Refresh(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, changed)
+	assert.Check(t, cmp.Equal(doer.requests[0].Get("If-None-Match"), ""))
+
+	isRevoked, err := cache.IsRevoked(revoked)
+	assert.NilError(t, err)
+	assert.Check(t, isRevoked)
+
+	revocation, err := cache.Revocation(revoked)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(revocation, &Revocation{Reason: RevocationReasonExplicitKey, ListVersion: 1, Key: revoked}))
+
+	raw, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	list, err := krl.ParseKRL(raw)
+	assert.NilError(t, err)
+	assert.Check(t, list.IsRevoked(revoked), "list on disk must be usable by sshd")
+
+	t.Run("not modified", func(t *testing.T) {
+		changed, err := cache.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, !changed)
+
+		lastRequest := doer.requests[len(doer.requests)-1]
+		assert.Check(t, cmp.Equal(lastRequest.Get("If-None-Match"), `"1"`))
+		assert.Check(t, cmp.Equal(lastRequest.Get("If-Modified-Since"), "Mon, 02 Jan 2006 15:04:05 GMT"))
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		doer.revoke(other, 2)
+
+		changed, err := cache.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, changed)
+
+		isRevoked, err := cache.IsRevoked(other)
+		assert.NilError(t, err)
+		assert.Check(t, isRevoked)
+	})
+
+	t.Run("persisted", func(t *testing.T) {
+		reloaded := client.KRLCache(path)
+		isRevoked, err := reloaded.IsRevoked(other)
+		assert.NilError(t, err)
+		assert.Check(t, isRevoked)
+
+		changed, err := reloaded.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, !changed)
+		assert.Check(t, cmp.Equal(doer.requests[len(doer.requests)-1].Get("If-None-Match"), `"2"`), "metadata must be persisted")
+	})
+
+	t.Run("same version", func(t *testing.T) {
+		doer.list = &krl.KRL{Version: 2}
+
+		changed, err := cache.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, !changed)
+	})
+
+	t.Run("older version", func(t *testing.T) {
+		doer.list = &krl.KRL{Version: 1}
+
+		_, err := cache.Refresh(ctx)
+		assert.Check(t, cmp.ErrorContains(err, "refusing key revocation list version 1 older than cached version 2"))
+
+		isRevoked, err := cache.IsRevoked(other)
+		assert.NilError(t, err)
+		assert.Check(t, isRevoked)
+	})
+
+	t.Run("stale if error", func(t *testing.T) {
+		doer.fail = true
+		defer func() { doer.fail = false }()
+
+		_, err := cache.Refresh(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrServerFailure))
+
+		isRevoked, err := cache.IsRevoked(revoked)
+		assert.NilError(t, err)
+		assert.Check(t, isRevoked)
+	})
+}
+
+func Test_KRLCache_Refresh_versions(t *testing.T) {
+	ctx := context.Background()
+	first := newVerificationTestSigner(t).PublicKey()
+	second := newVerificationTestSigner(t).PublicKey()
+
+	t.Run("unversioned lists", func(t *testing.T) {
+		doer := &krlCacheTestDoer{t: t, raw: marshalTestKRL(t, 0, first)}
+		_, cache, _ := newTestKRLCache(t, doer)
+
+		changed, err := cache.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, changed)
+
+		changed, err = cache.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, !changed, "same content is not a change")
+
+		doer.raw = marshalTestKRL(t, 0, first, second)
+		changed, err = cache.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, changed, "new content with the same version is a change")
+
+		isRevoked, err := cache.IsRevoked(second)
+		assert.NilError(t, err)
+		assert.Check(t, isRevoked)
+	})
+
+	t.Run("version starting over", func(t *testing.T) {
+		doer := &krlCacheTestDoer{t: t, raw: marshalTestKRL(t, 42, first)}
+		_, cache, _ := newTestKRLCache(t, doer)
+
+		_, err := cache.Refresh(ctx)
+		assert.NilError(t, err)
+
+		doer.raw = marshalTestKRL(t, 0, second)
+		changed, err := cache.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, changed)
+
+		doer.raw = marshalTestKRL(t, 1, first, second)
+		changed, err = cache.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, changed)
+
+		doer.raw = marshalTestKRL(t, 3, first)
+		changed, err = cache.Refresh(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, changed)
+
+		doer.raw = marshalTestKRL(t, 2, first)
+		_, err = cache.Refresh(ctx)
+		assert.Check(t, cmp.ErrorContains(err, "refusing key revocation list version 2 older than cached version 3"))
+	})
+}
+
+func Test_KRLCache_KRL(t *testing.T) {
+	now := time.Now()
+	doer := &krlCacheTestDoer{t: t}
+	doer.revoke(newVerificationTestSigner(t).PublicKey(), 1)
+
+	_, cache, path := newTestKRLCache(t, doer, KRLCacheOptionMaxStaleness(time.Hour))
+	cache.o.now = func() time.Time { return now }
+
+	_, err := cache.Refresh(context.Background())
+	assert.NilError(t, err)
+
+	_, err = cache.KRL()
+	assert.NilError(t, err)
+
+	now = now.Add(2 * time.Hour)
+	_, err = cache.KRL()
+	assert.Check(t, cmp.ErrorIs(err, ErrKRLStale))
+
+	t.Run("corrupted", func(t *testing.T) {
+		assert.NilError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+		client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+		assert.NilError(t, err)
+
+		cache := client.KRLCache(path)
+		_, err = cache.KRL()
+		assert.Check(t, cmp.ErrorIs(err, ErrKRLUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "unable to parse cached key revocation list"))
+
+		changed, err := cache.Refresh(context.Background())
+		assert.NilError(t, err)
+		assert.Check(t, changed, "corrupted list must be replaced")
+	})
+}
+
+func Test_KRLCache_largeList(t *testing.T) {
+	ctx := context.Background()
+	authority := newVerificationTestSigner(t).PublicKey()
+
+	serials := make(krl.KRLCertificateSerialList, 20000)
+	for i := range serials {
+		serials[i] = uint64(2 * i)
+	}
+	doer := &krlCacheTestDoer{t: t, list: &krl.KRL{Version: 1, Sections: []krl.KRLSection{
+		&krl.KRLCertificateSection{CA: authority, Sections: []krl.KRLCertificateSubsection{&serials}},
+	}}}
+
+	client, cache, _ := newTestKRLCache(t, doer)
+
+	list, err := client.KeyRevocationList(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(list.Sections, 1))
+
+	changed, err := cache.Refresh(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, changed)
+}
+
+func Test_Client_RawKeyRevocationList(t *testing.T) {
+	doer := &krlCacheTestDoer{t: t, signer: newVerificationTestSigner(t)}
+	doer.revoke(newVerificationTestSigner(t).PublicKey(), 0)
+
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	list, raw, err := client.RawKeyRevocationList(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(list.SigningKeys, 1))
+
+	parsed, err := krl.ParseKRL(raw)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(parsed.SigningKeys, 1), "raw list must keep its signature")
+	assert.Check(t, cmp.Equal(parsed.Version, list.Version))
+}
+
+func Test_KRLCache_IsRevoked(t *testing.T) {
+	authority := newVerificationTestSigner(t)
+	certificate := newVerificationTestCertificate(t, authority, newVerificationTestSigner(t).PublicKey(), time.Now())
+
+	doer := &krlCacheTestDoer{t: t}
+	doer.revoke(authority.PublicKey(), 1)
+
+	_, cache, _ := newTestKRLCache(t, doer)
+	_, err := cache.Refresh(context.Background())
+	assert.NilError(t, err)
+
+	isRevoked, err := cache.IsRevoked(certificate)
+	assert.NilError(t, err)
+	assert.Check(t, isRevoked, "certificates signed by a revoked authority are revoked")
+
+	revocation, err := cache.Revocation(certificate)
+	assert.NilError(t, err)
+	assert.Assert(t, revocation != nil)
+	assert.Check(t, cmp.Equal(revocation.Reason, RevocationReasonCertificateAuthority))
+}
+
+func Test_KRLCache_pinnedAuthority(t *testing.T) {
+	authority := newVerificationTestSigner(t)
+	doer := &krlCacheTestDoer{t: t, signer: newVerificationTestSigner(t)}
+	doer.revoke(newVerificationTestSigner(t).PublicKey(), 1)
+
+	client, err := NewClient("https://cassh.local",
+		ClientOptionHTTPClient(doer),
+		ClientOptionPinnedAuthority(ssh.FingerprintSHA256(authority.PublicKey())),
+	)
+	assert.NilError(t, err)
+
+	_, err = client.KRLCache(filepath.Join(t.TempDir(), "revoked_keys")).Refresh(context.Background())
+	assert.Check(t, cmp.ErrorIs(err, ErrAuthorityMismatch))
+}
+
+func Test_KRLCache_Run(t *testing.T) {
+	doer := &krlCacheTestDoer{t: t}
+	doer.revoke(newVerificationTestSigner(t).PublicKey(), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		refreshes []KRLCacheRefresh
+		sleeps    []time.Duration
+	)
+
+	_, cache, _ := newTestKRLCache(t, doer,
+		KRLCacheOptionRefreshInterval(time.Second),
+		KRLCacheOptionOnRefresh(func(refresh KRLCacheRefresh) {
+			refreshes = append(refreshes, refresh)
+			switch len(refreshes) {
+			case 2:
+				doer.fail = true
+			case 3:
+				cancel()
+			}
+		}),
+	)
+	cache.o.after = func(d time.Duration) <-chan time.Time {
+		sleeps = append(sleeps, d)
+		c := make(chan time.Time, 1)
+		c <- time.Now()
+		return c
+	}
+
+	assert.Check(t, cmp.ErrorIs(cache.Run(ctx), context.Canceled))
+
+	assert.Assert(t, cmp.Len(refreshes, 3))
+	assert.Check(t, refreshes[0].Changed && refreshes[0].Err == nil)
+	assert.Check(t, !refreshes[1].Changed && refreshes[1].Err == nil)
+	assert.Check(t, cmp.ErrorIs(refreshes[2].Err, ErrServerFailure))
+	assert.Check(t, cmp.Equal(refreshes[2].Version, uint64(1)))
+	assert.Check(t, cmp.DeepEqual(sleeps, []time.Duration{time.Second, time.Second}))
+}
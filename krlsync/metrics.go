@@ -0,0 +1,88 @@
+package krlsync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/krostar/cassh/internal/atomicfile"
+)
+
+// Metrics describes the synchronizations made by a Syncer.
+type Metrics struct {
+	// Syncs is the number of successful synchronizations.
+	Syncs uint64
+	// Failures is the number of failed synchronizations.
+	Failures uint64
+	// Writes is the number of synchronizations that wrote the list.
+	Writes uint64
+	// LastSuccess is the time of the last successful synchronization.
+	LastSuccess time.Time
+	// LastFailure is the time of the last failed synchronization.
+	LastFailure time.Time
+	// LastError is the error of the last synchronization, nil if it succeeded.
+	LastError error
+	// Version is the version of the last synchronized list.
+	Version uint64
+	// RevokedEntries is the number of entries revoked by the last synchronized list.
+	RevokedEntries uint64
+}
+
+func (m *Metrics) record(now time.Time, result Result, err error) {
+	m.LastError = err
+
+	if err != nil {
+		m.Failures++
+		m.LastFailure = now
+		return
+	}
+
+	m.Syncs++
+	m.LastSuccess = now
+	m.Version = result.Version
+	m.RevokedEntries = result.RevokedEntries
+	if result.Changed {
+		m.Writes++
+	}
+}
+
+// WriteTo writes the metrics using the Prometheus text format.
+func (m Metrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	for _, metric := range []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{name: "cassh_krl_sync_success_total", kind: "counter", help: "Number of successful synchronizations.", value: float64(m.Syncs)},
+		{name: "cassh_krl_sync_failure_total", kind: "counter", help: "Number of failed synchronizations.", value: float64(m.Failures)},
+		{name: "cassh_krl_sync_write_total", kind: "counter", help: "Number of synchronizations that wrote the list.", value: float64(m.Writes)},
+		{name: "cassh_krl_sync_last_success_timestamp_seconds", kind: "gauge", help: "Time of the last successful synchronization.", value: unixSeconds(m.LastSuccess)},
+		{name: "cassh_krl_sync_last_failure_timestamp_seconds", kind: "gauge", help: "Time of the last failed synchronization.", value: unixSeconds(m.LastFailure)},
+		{name: "cassh_krl_version", kind: "gauge", help: "Version of the synchronized list.", value: float64(m.Version)},
+		{name: "cassh_krl_revoked_entries", kind: "gauge", help: "Number of entries revoked by the synchronized list.", value: float64(m.RevokedEntries)},
+	} {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", metric.name, metric.help, metric.name, metric.kind, metric.name, metric.value)
+	}
+
+	return buf.WriteTo(w)
+}
+
+func (m Metrics) writeFile(path string) error {
+	var buf bytes.Buffer
+	_, _ = m.WriteTo(&buf)
+
+	if err := atomicfile.WriteFile(path, buf.Bytes(), 0o644); err != nil { //nolint:gosec // metrics are meant to be read by collectors
+		return fmt.Errorf("unable to write metrics: %v", err)
+	}
+
+	return nil
+}
+
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / float64(time.Second)
+}
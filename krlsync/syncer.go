@@ -0,0 +1,208 @@
+// Package krlsync keeps the file referenced by sshd RevokedKeys in sync with the CASSH key revocation list.
+package krlsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/bits"
+	"os"
+	"sync"
+
+	"github.com/stripe/krl"
+
+	"github.com/krostar/cassh"
+	"github.com/krostar/cassh/internal/atomicfile"
+)
+
+// ErrShrink is returned when the downloaded list revokes fewer keys, or is older, than the synchronized one.
+var ErrShrink = errors.New("key revocation list shrunk")
+
+// Syncer downloads the key revocation list of the CASSH server and writes it for sshd.
+// It is safe to use it concurrently.
+type Syncer struct {
+	client *cassh.Client
+	path   string
+	o      *syncerOptions
+
+	m       sync.Mutex
+	metrics Metrics
+}
+
+// Result describes a successful synchronization.
+type Result struct {
+	// Changed is true when the file has been written.
+	Changed bool
+	// Version is the version of the synchronized list.
+	Version uint64
+	// RevokedEntries is the number of keys, certificates, serials, key ids, and fingerprints revoked by the list.
+	RevokedEntries uint64
+}
+
+// New creates a syncer writing the key revocation list at the provided path.
+func New(client *cassh.Client, path string, opts ...SyncerOption) *Syncer {
+	o := syncerOptionsDefaults()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Syncer{client: client, path: path, o: o}
+}
+
+// Sync downloads the key revocation list, validates it, and writes it if it changed.
+// The previous file is kept untouched on failure.
+func (s *Syncer) Sync(ctx context.Context) (Result, error) {
+	result, err := s.sync(ctx)
+
+	s.m.Lock()
+	s.metrics.record(s.o.now(), result, err)
+	metrics := s.metrics
+	s.m.Unlock()
+
+	if s.o.metricsPath != "" {
+		if metricsErr := metrics.writeFile(s.o.metricsPath); metricsErr != nil && err == nil {
+			err = metricsErr
+		}
+	}
+
+	s.o.onSync(result, err)
+
+	return result, err
+}
+
+func (s *Syncer) sync(ctx context.Context) (Result, error) {
+	// the list is written as sent by the server, marshaling it would drop its signatures
+	list, raw, err := s.client.RawKeyRevocationList(ctx)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to get key revocation list: %w", err)
+	}
+
+	if s.o.requireSignature {
+		if err := s.checkSignature(ctx, list); err != nil {
+			return Result{}, err
+		}
+	}
+
+	result := Result{Version: list.Version, RevokedEntries: countRevokedEntries(list)}
+
+	current, currentRaw, err := s.current()
+	if err != nil {
+		return Result{}, err
+	}
+
+	if current != nil && bytes.Equal(raw, currentRaw) {
+		return result, nil
+	}
+
+	if current != nil && !s.o.allowShrink {
+		if list.Version < current.Version {
+			return Result{}, fmt.Errorf("%w: version %d is older than synchronized version %d", ErrShrink, list.Version, current.Version)
+		}
+		if currentEntries := countRevokedEntries(current); result.RevokedEntries < currentEntries {
+			return Result{}, fmt.Errorf("%w: %d revoked entries instead of %d", ErrShrink, result.RevokedEntries, currentEntries)
+		}
+	}
+
+	if err := atomicfile.WriteFile(s.path, raw, s.o.fileMode); err != nil {
+		return Result{}, fmt.Errorf("unable to write key revocation list: %v", err)
+	}
+
+	result.Changed = true
+	return result, nil
+}
+
+func (s *Syncer) checkSignature(ctx context.Context, list *krl.KRL) error {
+	if len(list.SigningKeys) == 0 {
+		return errors.New("key revocation list is not signed")
+	}
+
+	authority, err := s.client.AuthorityPublicKey(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get authority public key: %w", err)
+	}
+
+	for _, signingKey := range list.SigningKeys {
+		if bytes.Equal(signingKey.Marshal(), authority.Marshal()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: key revocation list is not signed by the authority", cassh.ErrAuthorityMismatch)
+}
+
+// current returns the synchronized list, or nil if there is none or it cannot be parsed.
+func (s *Syncer) current() (*krl.KRL, []byte, error) {
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read synchronized key revocation list: %v", err)
+	}
+
+	list, err := krl.ParseKRL(raw)
+	if err != nil {
+		// sshd refuses every key with an invalid list, replacing it can only improve the situation
+		return nil, nil, nil //nolint:nilerr // see above
+	}
+
+	return list, raw, nil
+}
+
+// Run synchronizes the key revocation list periodically until the context is done.
+// Failures are reported through metrics and SyncerOptionOnSync. It always returns the context error.
+func (s *Syncer) Run(ctx context.Context) error {
+	for {
+		_, _ = s.Sync(ctx)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.o.after(s.o.interval):
+		}
+	}
+}
+
+// Metrics returns a snapshot of the synchronization metrics.
+func (s *Syncer) Metrics() Metrics {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.metrics
+}
+
+func countRevokedEntries(list *krl.KRL) uint64 {
+	var count uint64
+
+	for _, section := range list.Sections {
+		switch section := section.(type) {
+		case *krl.KRLCertificateSection:
+			for _, subsection := range section.Sections {
+				switch subsection := subsection.(type) {
+				case *krl.KRLCertificateSerialList:
+					count += uint64(len(*subsection))
+				case *krl.KRLCertificateSerialRange:
+					count += subsection.Max - subsection.Min + 1
+				case *krl.KRLCertificateSerialBitmap:
+					for _, word := range subsection.Bitmap.Bits() {
+						count += uint64(bits.OnesCount(uint(word)))
+					}
+				case *krl.KRLCertificateKeyID:
+					count += uint64(len(*subsection))
+				}
+			}
+		case *krl.KRLExplicitKeySection:
+			count += uint64(len(*section))
+		case *krl.KRLFingerprintSection:
+			count += uint64(len(*section))
+		case *krl.KRLFingerprintSHA256Section:
+			count += uint64(len(*section))
+		}
+	}
+
+	return count
+}
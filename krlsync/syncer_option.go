@@ -0,0 +1,76 @@
+package krlsync
+
+import (
+	"os"
+	"time"
+)
+
+// SyncerOption defines the signature of all options usable on New.
+type SyncerOption func(o *syncerOptions)
+
+type syncerOptions struct {
+	requireSignature bool
+	allowShrink      bool
+	fileMode         os.FileMode
+	interval         time.Duration
+	metricsPath      string
+	onSync           func(Result, error)
+
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+func syncerOptionsDefaults() *syncerOptions {
+	return &syncerOptions{
+		fileMode: 0o644,
+		interval: time.Minute,
+		onSync:   func(Result, error) {},
+		now:      time.Now,
+		after:    time.After,
+	}
+}
+
+// SyncerOptionRequireSignature refuses key revocation lists that are not signed by the CASSH authority.
+// The authority is the one returned by cassh.Client.AuthorityPublicKey, which honors pinned authorities.
+func SyncerOptionRequireSignature() SyncerOption {
+	return func(o *syncerOptions) {
+		o.requireSignature = true
+	}
+}
+
+// SyncerOptionAllowShrink allows the synchronized list to revoke fewer keys than the one it replaces,
+// or to have an older version. It is refused by default, as it may be the sign of a misconfigured server.
+func SyncerOptionAllowShrink() SyncerOption {
+	return func(o *syncerOptions) {
+		o.allowShrink = true
+	}
+}
+
+// SyncerOptionFileMode sets the permissions of the written list. It defaults to 0644.
+func SyncerOptionFileMode(fileMode os.FileMode) SyncerOption {
+	return func(o *syncerOptions) {
+		o.fileMode = fileMode
+	}
+}
+
+// SyncerOptionInterval sets the delay between two synchronizations made by Syncer.Run. It defaults to a minute.
+func SyncerOptionInterval(interval time.Duration) SyncerOption {
+	return func(o *syncerOptions) {
+		o.interval = interval
+	}
+}
+
+// SyncerOptionMetricsFile writes metrics after each synchronization at the provided path,
+// using the Prometheus text format, to be collected for instance by the node exporter textfile collector.
+func SyncerOptionMetricsFile(path string) SyncerOption {
+	return func(o *syncerOptions) {
+		o.metricsPath = path
+	}
+}
+
+// SyncerOptionOnSync sets a callback called synchronously after each synchronization.
+func SyncerOptionOnSync(onSync func(Result, error)) SyncerOption {
+	return func(o *syncerOptions) {
+		o.onSync = onSync
+	}
+}
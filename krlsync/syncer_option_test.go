@@ -0,0 +1,60 @@
+package krlsync
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_syncerOptionsDefaults(t *testing.T) {
+	opts := syncerOptionsDefaults()
+	assert.Check(t, !opts.requireSignature)
+	assert.Check(t, !opts.allowShrink)
+	assert.Check(t, cmp.Equal(opts.fileMode, os.FileMode(0o644)))
+	assert.Check(t, cmp.Equal(opts.interval, time.Minute))
+	assert.Check(t, cmp.Equal(opts.metricsPath, ""))
+	assert.Check(t, opts.onSync != nil)
+	assert.Check(t, opts.now != nil)
+	assert.Check(t, opts.after != nil)
+}
+
+func Test_SyncerOptionRequireSignature(t *testing.T) {
+	opts := syncerOptionsDefaults()
+	SyncerOptionRequireSignature()(opts)
+	assert.Check(t, opts.requireSignature)
+}
+
+func Test_SyncerOptionAllowShrink(t *testing.T) {
+	opts := syncerOptionsDefaults()
+	SyncerOptionAllowShrink()(opts)
+	assert.Check(t, opts.allowShrink)
+}
+
+func Test_SyncerOptionFileMode(t *testing.T) {
+	opts := syncerOptionsDefaults()
+	SyncerOptionFileMode(0o600)(opts)
+	assert.Check(t, cmp.Equal(opts.fileMode, os.FileMode(0o600)))
+}
+
+func Test_SyncerOptionInterval(t *testing.T) {
+	opts := syncerOptionsDefaults()
+	SyncerOptionInterval(time.Hour)(opts)
+	assert.Check(t, cmp.Equal(opts.interval, time.Hour))
+}
+
+func Test_SyncerOptionMetricsFile(t *testing.T) {
+	opts := syncerOptionsDefaults()
+	SyncerOptionMetricsFile("/var/lib/node_exporter/cassh.prom")(opts)
+	assert.Check(t, cmp.Equal(opts.metricsPath, "/var/lib/node_exporter/cassh.prom"))
+}
+
+func Test_SyncerOptionOnSync(t *testing.T) {
+	var called bool
+	opts := syncerOptionsDefaults()
+	SyncerOptionOnSync(func(Result, error) { called = true })(opts)
+	opts.onSync(Result{}, nil)
+	assert.Check(t, called)
+}
@@ -0,0 +1,299 @@
+package krlsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/krostar/cassh"
+	"github.com/krostar/cassh/casshtest"
+)
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	assert.NilError(t, err)
+	return signer
+}
+
+func newTestSyncer(t *testing.T, serverOpts []casshtest.ServerOption, opts ...SyncerOption) (*casshtest.Server, *Syncer, string) {
+	srv, err := casshtest.NewServer(serverOpts...)
+	assert.NilError(t, err)
+	t.Cleanup(srv.Close)
+
+	client, err := srv.NewClient()
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "revoked_keys")
+	return srv, New(client, path, opts...), path
+}
+
+func readTestKRL(t *testing.T, path string) *krl.KRL {
+	raw, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	list, err := krl.ParseKRL(raw)
+	assert.NilError(t, err)
+	return list
+}
+
+func Test_Syncer_Sync(t *testing.T) {
+	ctx := context.Background()
+	revoked := newTestSigner(t).PublicKey()
+
+	srv, syncer, path := newTestSyncer(t, nil, SyncerOptionFileMode(0o640))
+	srv.RevokeKey(revoked)
+
+	result, err := syncer.Sync(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(result, Result{Changed: true, Version: 2, RevokedEntries: 1}))
+	assert.Check(t, readTestKRL(t, path).IsRevoked(revoked))
+
+	info, err := os.Stat(path)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(info.Mode().Perm(), os.FileMode(0o640)))
+
+	t.Run("unchanged", func(t *testing.T) {
+		result, err := syncer.Sync(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(result, Result{Version: 2, RevokedEntries: 1}))
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		other := newTestSigner(t).PublicKey()
+		srv.RevokeKey(other)
+
+		result, err := syncer.Sync(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(result, Result{Changed: true, Version: 3, RevokedEntries: 2}))
+		assert.Check(t, readTestKRL(t, path).IsRevoked(other))
+	})
+
+	t.Run("server failure", func(t *testing.T) {
+		srv.InjectFailure(casshtest.Failure{Path: "/krl", StatusCode: http.StatusBadGateway})
+
+		_, err := syncer.Sync(ctx)
+		assert.Check(t, cmp.ErrorIs(err, cassh.ErrServerFailure))
+		assert.Check(t, readTestKRL(t, path).IsRevoked(revoked), "previous list must be kept")
+	})
+
+	metrics := syncer.Metrics()
+	assert.Check(t, cmp.Equal(metrics.Syncs, uint64(3)))
+	assert.Check(t, cmp.Equal(metrics.Writes, uint64(2)))
+	assert.Check(t, cmp.Equal(metrics.Failures, uint64(1)))
+	assert.Check(t, cmp.ErrorIs(metrics.LastError, cassh.ErrServerFailure))
+	assert.Check(t, cmp.Equal(metrics.Version, uint64(3)))
+	assert.Check(t, cmp.Equal(metrics.RevokedEntries, uint64(2)))
+}
+
+func Test_Syncer_Sync_shrink(t *testing.T) {
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct {
+		current       *krl.KRL
+		opts          []SyncerOption
+		expectedError string
+	}{
+		"fewer entries": {
+			current: &krl.KRL{Version: 1, Sections: []krl.KRLSection{
+				&krl.KRLExplicitKeySection{newTestSigner(t).PublicKey(), newTestSigner(t).PublicKey()},
+			}},
+			expectedError: "key revocation list shrunk: 1 revoked entries instead of 2",
+		},
+		"older version": {
+			current:       &krl.KRL{Version: 42},
+			expectedError: "key revocation list shrunk: version 2 is older than synchronized version 42",
+		},
+		"allowed": {
+			current: &krl.KRL{Version: 42, Sections: []krl.KRLSection{
+				&krl.KRLExplicitKeySection{newTestSigner(t).PublicKey(), newTestSigner(t).PublicKey()},
+			}},
+			opts: []SyncerOption{SyncerOptionAllowShrink()},
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			srv, syncer, path := newTestSyncer(t, nil, tc.opts...)
+			srv.RevokeKey(newTestSigner(t).PublicKey())
+
+			raw, err := tc.current.Marshal(rand.Reader)
+			assert.NilError(t, err)
+			assert.NilError(t, os.WriteFile(path, raw, 0o600))
+
+			result, err := syncer.Sync(ctx)
+			if tc.expectedError != "" {
+				assert.Check(t, cmp.ErrorIs(err, ErrShrink))
+				assert.Check(t, cmp.ErrorContains(err, tc.expectedError))
+				current, err := os.ReadFile(path)
+				assert.NilError(t, err)
+				assert.Check(t, cmp.DeepEqual(current, raw), "current list must be kept")
+			} else {
+				assert.NilError(t, err)
+				assert.Check(t, result.Changed)
+			}
+		})
+	}
+
+	t.Run("corrupted list is replaced", func(t *testing.T) {
+		srv, syncer, path := newTestSyncer(t, nil)
+		srv.RevokeKey(newTestSigner(t).PublicKey())
+		assert.NilError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+
+		result, err := syncer.Sync(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, result.Changed)
+	})
+}
+
+// rawKRLTestDoer always answers the same key revocation list.
+type rawKRLTestDoer struct{ raw []byte }
+
+func (d rawKRLTestDoer) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Body:          io.NopCloser(bytes.NewReader(d.raw)),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func Test_Syncer_Sync_raw(t *testing.T) {
+	list := &krl.KRL{Comment: "unversioned", Sections: []krl.KRLSection{&krl.KRLExplicitKeySection{newTestSigner(t).PublicKey()}}}
+	raw, err := list.Marshal(rand.Reader, newTestSigner(t))
+	assert.NilError(t, err)
+
+	client, err := cassh.NewClient("https://cassh.local", cassh.ClientOptionHTTPClient(rawKRLTestDoer{raw: raw}))
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "revoked_keys")
+	result, err := New(client, path).Sync(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, result.Changed)
+
+	written, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(written, raw), "list must be written as sent by the server")
+}
+
+func Test_Syncer_Sync_requireSignature(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("signed", func(t *testing.T) {
+		srv, syncer, path := newTestSyncer(t, []casshtest.ServerOption{casshtest.ServerOptionSignKRL()}, SyncerOptionRequireSignature())
+		srv.RevokeKey(newTestSigner(t).PublicKey())
+
+		result, err := syncer.Sync(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, result.Changed)
+		assert.Check(t, cmp.Len(readTestKRL(t, path).SigningKeys, 1), "signature must be kept")
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		srv, syncer, path := newTestSyncer(t, nil, SyncerOptionRequireSignature())
+		srv.RevokeKey(newTestSigner(t).PublicKey())
+
+		_, err := syncer.Sync(ctx)
+		assert.Check(t, cmp.ErrorContains(err, "key revocation list is not signed"))
+		_, err = os.Stat(path)
+		assert.Check(t, os.IsNotExist(err))
+	})
+
+	t.Run("signed by another key", func(t *testing.T) {
+		srv, syncer, _ := newTestSyncer(t, []casshtest.ServerOption{
+			casshtest.ServerOptionSignKRL(),
+			casshtest.ServerOptionAuthority(newTestSigner(t)),
+		}, SyncerOptionRequireSignature())
+		srv.RevokeKey(newTestSigner(t).PublicKey())
+
+		client, err := srv.NewClient(cassh.ClientOptionPinnedAuthority(ssh.FingerprintSHA256(newTestSigner(t).PublicKey())))
+		assert.NilError(t, err)
+		syncer.client = client
+
+		_, err = syncer.Sync(ctx)
+		assert.Check(t, cmp.ErrorIs(err, cassh.ErrAuthorityMismatch))
+	})
+}
+
+func Test_Syncer_Sync_metricsFile(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	metricsPath := filepath.Join(t.TempDir(), "cassh.prom")
+
+	srv, syncer, _ := newTestSyncer(t, nil, SyncerOptionMetricsFile(metricsPath))
+	syncer.o.now = func() time.Time { return now }
+	srv.RevokeKey(newTestSigner(t).PublicKey())
+
+	_, err := syncer.Sync(context.Background())
+	assert.NilError(t, err)
+
+	raw, err := os.ReadFile(metricsPath)
+	assert.NilError(t, err)
+	metrics := string(raw)
+	assert.Check(t, strings.Contains(metrics, "# TYPE cassh_krl_sync_success_total counter\ncassh_krl_sync_success_total 1\n"), metrics)
+	assert.Check(t, strings.Contains(metrics, "\ncassh_krl_sync_failure_total 0\n"), metrics)
+	assert.Check(t, strings.Contains(metrics, "\ncassh_krl_sync_last_success_timestamp_seconds 1.7e+09\n"), metrics)
+	assert.Check(t, strings.Contains(metrics, "\ncassh_krl_version 2\n"), metrics)
+	assert.Check(t, strings.Contains(metrics, "\ncassh_krl_revoked_entries 1\n"), metrics)
+}
+
+func Test_Syncer_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		errs   []error
+		sleeps []time.Duration
+	)
+
+	srv, syncer, _ := newTestSyncer(t, nil,
+		SyncerOptionInterval(time.Second),
+		SyncerOptionOnSync(func(_ Result, err error) {
+			errs = append(errs, err)
+			if len(errs) == 2 {
+				cancel()
+			}
+		}),
+	)
+	syncer.o.after = func(d time.Duration) <-chan time.Time {
+		sleeps = append(sleeps, d)
+		c := make(chan time.Time, 1)
+		c <- time.Now()
+		return c
+	}
+	srv.InjectFailure(casshtest.Failure{Path: "/krl", StatusCode: http.StatusBadGateway})
+
+	assert.Check(t, cmp.ErrorIs(syncer.Run(ctx), context.Canceled))
+	assert.Assert(t, cmp.Len(errs, 2))
+	assert.Check(t, cmp.ErrorIs(errs[0], cassh.ErrServerFailure))
+	assert.Check(t, errs[1] == nil)
+	assert.Check(t, cmp.DeepEqual(sleeps, []time.Duration{time.Second}))
+}
+
+func Test_countRevokedEntries(t *testing.T) {
+	bitmap := big.NewInt(0b10110)
+
+	assert.Check(t, cmp.Equal(countRevokedEntries(&krl.KRL{Sections: []krl.KRLSection{
+		&krl.KRLCertificateSection{Sections: []krl.KRLCertificateSubsection{
+			&krl.KRLCertificateSerialList{1, 2},
+			&krl.KRLCertificateSerialRange{Min: 10, Max: 19},
+			&krl.KRLCertificateSerialBitmap{Offset: 100, Bitmap: bitmap},
+			&krl.KRLCertificateKeyID{"john"},
+		}},
+		&krl.KRLExplicitKeySection{newTestSigner(t).PublicKey()},
+		&krl.KRLFingerprintSection{{}},
+		&krl.KRLFingerprintSHA256Section{{}, {}},
+	}}), uint64(2+10+3+1+1+1+2)))
+}
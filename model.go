@@ -2,7 +2,10 @@ package cassh
 
 import (
 	"fmt"
+	"strings"
 	"time"
+
+	"golang.org/x/crypto/ssh"
 )
 
 // Username of the CASSH user.
@@ -13,11 +16,22 @@ func (u Username) String() string { return string(u) }
 
 // UserStatus stores the status attributes of a CASSH user.
 type UserStatus struct {
-	Name          Username
-	RealName      string
-	KeyState      KeyState
+	Name     Username
+	RealName string
+	KeyState KeyState
+	// KeyExpiration is the expiration of the last certificate signed, zero if the server did not send any.
 	KeyExpiration time.Time
+	// KeyExpiry is the validity duration of the certificates signed for the user.
+	KeyExpiry     time.Duration
 	KeyPrincipals Principals
+
+	// KeyFingerprint is the fingerprint of the key registered on the CASSH server, like "SHA256:...".
+	KeyFingerprint string
+	// KeyAlgorithm is the algorithm of the registered key, like "RSA" or "ED25519".
+	KeyAlgorithm string
+	// KeyBits is the bit length of the registered key.
+	KeyBits     int
+	KeyStrength KeyStrength
 }
 
 // String implements stringer for UserStatus.
@@ -25,6 +39,19 @@ func (us UserStatus) String() string {
 	return fmt.Sprintf("[%s] %s (%s)", us.KeyState.String(), us.Name.String(), us.RealName)
 }
 
+// KeyMatches returns whenever the provided key is the key registered on the CASSH server,
+// comparing its fingerprint in the format used by the server (SHA256, or MD5 for older servers).
+func (us UserStatus) KeyMatches(key ssh.PublicKey) bool {
+	switch fingerprint := us.KeyFingerprint; {
+	case fingerprint == "" || key == nil:
+		return false
+	case strings.HasPrefix(fingerprint, "SHA256:"):
+		return fingerprint == ssh.FingerprintSHA256(key)
+	default:
+		return strings.EqualFold(strings.TrimPrefix(fingerprint, "MD5:"), ssh.FingerprintLegacyMD5(key))
+	}
+}
+
 // KeyState defines the different states a user key can be in.
 type KeyState string
 
@@ -35,11 +62,46 @@ const (
 	KeyStateRevoked KeyState = "REVOKED"
 	// KeyStatePending means the key has not been signed yet by a CASSH server admin and cannot be used yet.
 	KeyStatePending KeyState = "PENDING"
+	// KeyStateUnknown means the CASSH server returned a state this client does not know.
+	KeyStateUnknown KeyState = "UNKNOWN"
 )
 
 // String implements stringer for KeyState.
 func (ks KeyState) String() string { return string(ks) }
 
+// Valid returns whenever the state is one of the states known by the CASSH server.
+func (ks KeyState) Valid() bool {
+	switch ks {
+	case KeyStateActive, KeyStateRevoked, KeyStatePending:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseKeyState returns the state sent by the server, or KeyStateUnknown if it is not a valid state.
+func parseKeyState(state string) KeyState {
+	if keyState := KeyState(strings.ToUpper(strings.TrimSpace(state))); keyState.Valid() {
+		return keyState
+	}
+	return KeyStateUnknown
+}
+
+// KeyStrength defines the strength rating the CASSH server gives to user keys.
+type KeyStrength string
+
+const (
+	// KeyStrengthLow means the key is considered weak, like RSA keys shorter than 2048 bits.
+	KeyStrengthLow KeyStrength = "LOW"
+	// KeyStrengthMedium means the key is considered acceptable.
+	KeyStrengthMedium KeyStrength = "MEDIUM"
+	// KeyStrengthHigh means the key is considered strong.
+	KeyStrengthHigh KeyStrength = "HIGH"
+)
+
+// String implements stringer for KeyStrength.
+func (ks KeyStrength) String() string { return string(ks) }
+
 // Principals aliases []Principal to add useful methods.
 type Principals []Principal
 
@@ -70,10 +132,3 @@ type Principal string
 
 // String implements stringer for Principal.
 func (principal Principal) String() string { return string(principal) }
-
-type sentinelError string
-
-func (err sentinelError) Error() string { return string(err) }
-
-// ErrInsufficientPrivileges is returned when the privileges provided to the CASSH server are not sufficient to execute the request successfully.
-const ErrInsufficientPrivileges = sentinelError("insufficient privileges")
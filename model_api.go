@@ -1,7 +1,11 @@
 package cassh
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -27,23 +31,239 @@ type apiUserStatusResponseSSHKeyHash struct {
 	Rate     string `json:"rate"`
 }
 
-func dtoUserStatusResponse(response apiUserStatusResponse, timeZone *time.Location) (*UserStatus, error) {
-	expiration, err := time.ParseInLocation("2006-01-02 15:04:05", response.Expiration, timeZone)
+// UnmarshalJSON decodes the status returned by all server versions, which may send null fields,
+// numbers instead of strings and the other way around, or principals as a comma-separated list.
+func (r *apiUserStatusResponse) UnmarshalJSON(raw []byte) error {
+	var response struct {
+		Expiration apiLenientString  `json:"expiration"`
+		Expiry     apiLenientString  `json:"expiry"`
+		Principals apiLenientStrings `json:"principals"`
+		RealName   apiLenientString  `json:"realname"`
+		SSHKeyHash *struct {
+			AuthType apiLenientString `json:"auth_type"`
+			Bits     apiLenientInt    `json:"bits"`
+			Hash     apiLenientString `json:"hash"`
+			Rate     apiLenientString `json:"rate"`
+		} `json:"ssh_key_hash"`
+		Status   apiLenientString `json:"status"`
+		Username apiLenientString `json:"username"`
+	}
+
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return err
+	}
+
+	*r = apiUserStatusResponse{
+		Expiration: string(response.Expiration),
+		Expiry:     string(response.Expiry),
+		Principals: response.Principals,
+		RealName:   string(response.RealName),
+		Status:     string(response.Status),
+		Username:   string(response.Username),
+	}
+
+	if hash := response.SSHKeyHash; hash != nil {
+		r.SSHKeyHash = apiUserStatusResponseSSHKeyHash{
+			AuthType: string(hash.AuthType),
+			Bits:     int(hash.Bits),
+			Hash:     string(hash.Hash),
+			Rate:     string(hash.Rate),
+		}
+	}
+
+	return nil
+}
+
+func dtoUserStatusResponse(response apiUserStatusResponse, timeZone *time.Location, decoding serverDecoding) (*UserStatus, error) {
+	expiration, err := decoding.parseTime(response.Expiration, timeZone)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse expiration time: %v", err)
 	}
 
+	expiry, err := parseServerExpiry(response.Expiry)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse expiry: %v", err)
+	}
+
 	keyStatus := &UserStatus{
-		Name:          Username(response.Username),
-		RealName:      response.RealName,
-		KeyState:      KeyState(response.Status),
-		KeyExpiration: expiration,
-		KeyPrincipals: make(Principals, len(response.Principals)),
+		Name:           Username(response.Username),
+		RealName:       response.RealName,
+		KeyState:       parseKeyState(response.Status),
+		KeyExpiration:  expiration,
+		KeyExpiry:      expiry,
+		KeyPrincipals:  make(Principals, 0, len(response.Principals)),
+		KeyFingerprint: response.SSHKeyHash.Hash,
+		KeyAlgorithm:   response.SSHKeyHash.AuthType,
+		KeyBits:        response.SSHKeyHash.Bits,
+		KeyStrength:    KeyStrength(strings.ToUpper(response.SSHKeyHash.Rate)),
 	}
 
-	for i := range response.Principals {
-		keyStatus.KeyPrincipals[i] = Principal(response.Principals[i])
+	for _, principal := range response.Principals {
+		if principal = strings.TrimSpace(principal); principal != "" {
+			keyStatus.KeyPrincipals = append(keyStatus.KeyPrincipals, Principal(principal))
+		}
 	}
 
 	return keyStatus, nil
 }
+
+// serverNaiveTimeLayouts are the layouts of the times sent by the server without timezone,
+// interpreted in the server timezone.
+var serverNaiveTimeLayouts = []string{
+	serverTimeLayout,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// serverTimeLayouts are the layouts of the times sent by the server with their timezone.
+var serverTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	time.RFC1123,
+	time.RFC1123Z,
+}
+
+// parseServerTime parses a time sent by the server, in any of the formats used by its different versions.
+// Empty times, like the expiration of pending keys on some versions, are returned as the zero time.
+func parseServerTime(value string, timeZone *time.Location) (time.Time, error) {
+	switch value = strings.TrimSpace(value); strings.ToLower(value) {
+	case "", "none", "null":
+		return time.Time{}, nil
+	}
+
+	if wallClock, isNaive := parseServerNaiveTime(value, timeZone); isNaive {
+		return wallClock, nil
+	}
+
+	for _, layout := range serverTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(timestamp, 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unknown time format %q", value)
+}
+
+// parseServerNaiveTime parses a time sent by the server without timezone.
+func parseServerNaiveTime(value string, timeZone *time.Location) (time.Time, bool) {
+	for _, layout := range serverNaiveTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, timeZone); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// serverExpiryUnits are the units of the expiry durations used by the CASSH server, as accepted by ssh-keygen.
+var serverExpiryUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// parseServerExpiry parses an expiry duration sent by the CASSH server, like "+12h" or "+1d";
+// the plus sign is optional, and durations without unit are in seconds.
+func parseServerExpiry(expiry string) (time.Duration, error) {
+	value := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(expiry)), "+")
+	if value == "" || value == "none" || value == "null" {
+		return 0, nil
+	}
+
+	unit, isUnit := serverExpiryUnits[value[len(value)-1]]
+	if isUnit {
+		value = value[:len(value)-1]
+	} else {
+		unit = time.Second
+	}
+
+	count, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid expiry %q", expiry)
+	}
+
+	return time.Duration(count) * unit, nil
+}
+
+// apiLenientString decodes JSON strings, numbers, booleans, and null as a string.
+type apiLenientString string
+
+func (s *apiLenientString) UnmarshalJSON(raw []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = apiLenientString(v)
+	case json.Number:
+		*s = apiLenientString(v.String())
+	case bool:
+		*s = apiLenientString(strconv.FormatBool(v))
+	default:
+		return fmt.Errorf("unable to decode %s as a string", raw)
+	}
+
+	return nil
+}
+
+// apiLenientInt decodes JSON numbers, numeric strings, empty strings, and null as an integer.
+type apiLenientInt int
+
+func (i *apiLenientInt) UnmarshalJSON(raw []byte) error {
+	var value apiLenientString
+	if err := value.UnmarshalJSON(raw); err != nil {
+		return err
+	}
+
+	if value == "" {
+		*i = 0
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(string(value))
+	if err != nil {
+		return fmt.Errorf("unable to decode %s as an integer", raw)
+	}
+
+	*i = apiLenientInt(parsed)
+	return nil
+}
+
+// apiLenientStrings decodes JSON arrays, comma-separated strings, and null as a list of strings.
+type apiLenientStrings []string
+
+func (s *apiLenientStrings) UnmarshalJSON(raw []byte) error {
+	var values []apiLenientString
+	if err := json.Unmarshal(raw, &values); err == nil {
+		*s = make(apiLenientStrings, len(values))
+		for i, value := range values {
+			(*s)[i] = string(value)
+		}
+		return nil
+	}
+
+	var value apiLenientString
+	if err := value.UnmarshalJSON(raw); err != nil {
+		return fmt.Errorf("unable to decode %s as a list of strings", raw)
+	}
+
+	*s = nil
+	if value != "" {
+		*s = strings.Split(string(value), ",")
+	}
+
+	return nil
+}
@@ -1,10 +1,12 @@
 package cassh
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 )
 
 func Test_dtoUserStatusResponse(t *testing.T) {
@@ -24,15 +26,20 @@ func Test_dtoUserStatusResponse(t *testing.T) {
 			},
 			Status:   "ACTIVE",
 			Username: "foobar",
-		}, time.UTC)
+		}, time.UTC, serverDecoding{})
 		assert.NilError(t, err)
 
 		assert.DeepEqual(t, userStatus, &UserStatus{
-			Name:          "foobar",
-			RealName:      "foo.bar@foo.b-ar",
-			KeyState:      KeyStateActive,
-			KeyExpiration: now.Add(time.Hour),
-			KeyPrincipals: Principals{"foo", "bar", "foobar"},
+			Name:           "foobar",
+			RealName:       "foo.bar@foo.b-ar",
+			KeyState:       KeyStateActive,
+			KeyExpiration:  now.Add(time.Hour),
+			KeyExpiry:      6 * time.Hour,
+			KeyPrincipals:  Principals{"foo", "bar", "foobar"},
+			KeyFingerprint: "SHA512:3423jhb",
+			KeyAlgorithm:   "RSA",
+			KeyBits:        8192,
+			KeyStrength:    KeyStrengthHigh,
 		})
 	})
 
@@ -40,8 +47,147 @@ func Test_dtoUserStatusResponse(t *testing.T) {
 		t.Run("unable to parse expiration date", func(t *testing.T) {
 			_, err := dtoUserStatusResponse(apiUserStatusResponse{
 				Expiration: now.Add(time.Hour).Format(time.RFC822),
-			}, time.UTC)
+			}, time.UTC, serverDecoding{})
 			assert.ErrorContains(t, err, "unable to parse expiration time")
 		})
+
+		t.Run("unable to parse expiry", func(t *testing.T) {
+			_, err := dtoUserStatusResponse(apiUserStatusResponse{
+				Expiration: now.Format("2006-01-02 15:04:05"),
+				Expiry:     "6 hours",
+			}, time.UTC, serverDecoding{})
+			assert.ErrorContains(t, err, "unable to parse expiry: invalid expiry \"6 hours\"")
+		})
 	})
 }
+
+func Test_parseServerExpiry(t *testing.T) {
+	for expiry, expected := range map[string]time.Duration{
+		"":     0,
+		"+":    0,
+		"null": 0,
+		"+12h": 12 * time.Hour,
+		"12H":  12 * time.Hour,
+		"3600": time.Hour,
+		"+1d":  24 * time.Hour,
+		"+2w":  14 * 24 * time.Hour,
+		"+30m": 30 * time.Minute,
+		"+90":  90 * time.Second,
+	} {
+		parsed, err := parseServerExpiry(expiry)
+		assert.Check(t, err, expiry)
+		assert.Check(t, parsed == expected, expiry)
+	}
+
+	for _, expiry := range []string{"+h", "+-1h", "+1y", "12 hours"} {
+		_, err := parseServerExpiry(expiry)
+		assert.Check(t, err != nil, expiry)
+	}
+}
+
+func Test_parseServerTime(t *testing.T) {
+	timezone := time.FixedZone("UTC+2", 2*60*60)
+	expected := time.Date(2024, 1, 2, 3, 4, 5, 0, timezone)
+
+	for _, value := range []string{
+		"2024-01-02 03:04:05",
+		" 2024-01-02 03:04:05 ",
+		"2024-01-02T03:04:05",
+		"2024-01-02T01:04:05Z",
+		"2024-01-02T03:04:05+02:00",
+		"2024-01-02 01:04:05+00:00",
+		"Tue, 02 Jan 2024 01:04:05 GMT",
+		"1704157445",
+	} {
+		parsed, err := parseServerTime(value, timezone)
+		assert.Check(t, err, value)
+		assert.Check(t, parsed.Equal(expected), "%s: %s", value, parsed)
+	}
+
+	parsed, err := parseServerTime("2024-01-02 03:04:05.250000", timezone)
+	assert.NilError(t, err)
+	assert.Check(t, parsed.Equal(expected.Add(250*time.Millisecond)))
+
+	parsed, err = parseServerTime("2024-01-02", timezone)
+	assert.NilError(t, err)
+	assert.Check(t, parsed.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, timezone)))
+
+	for _, value := range []string{"", "None", "null"} {
+		parsed, err := parseServerTime(value, timezone)
+		assert.Check(t, err, value)
+		assert.Check(t, parsed.IsZero(), value)
+	}
+
+	_, err = parseServerTime("next tuesday", timezone)
+	assert.Check(t, cmp.ErrorContains(err, `unknown time format "next tuesday"`))
+}
+
+func Test_apiUserStatusResponse_UnmarshalJSON(t *testing.T) {
+	for name, test := range map[string]struct {
+		raw      string
+		expected apiUserStatusResponse
+	}{
+		"regular": {
+			raw: `{"expiration":"2024-01-02 03:04:05","expiry":"+12h","principals":["a","b"],"realname":"john@corp",` +
+				`"ssh_key_hash":{"auth_type":"RSA","bits":4096,"hash":"SHA256:abc","rate":"HIGH"},"status":"ACTIVE","username":"john"}`,
+			expected: apiUserStatusResponse{
+				Expiration: "2024-01-02 03:04:05",
+				Expiry:     "+12h",
+				Principals: []string{"a", "b"},
+				RealName:   "john@corp",
+				SSHKeyHash: apiUserStatusResponseSSHKeyHash{AuthType: "RSA", Bits: 4096, Hash: "SHA256:abc", Rate: "HIGH"},
+				Status:     "ACTIVE",
+				Username:   "john",
+			},
+		},
+		"null fields": {
+			raw:      `{"expiration":null,"expiry":null,"principals":null,"realname":null,"ssh_key_hash":null,"status":"PENDING","username":"john"}`,
+			expected: apiUserStatusResponse{Principals: []string{}, Status: "PENDING", Username: "john"},
+		},
+		"missing fields": {
+			raw:      `{"username":"john"}`,
+			expected: apiUserStatusResponse{Username: "john"},
+		},
+		"other types": {
+			raw: `{"expiration":1704157445,"expiry":43200,"principals":"a,b","ssh_key_hash":{"bits":"2048","hash":"","rate":null},"username":"john"}`,
+			expected: apiUserStatusResponse{
+				Expiration: "1704157445",
+				Expiry:     "43200",
+				Principals: []string{"a", "b"},
+				SSHKeyHash: apiUserStatusResponseSSHKeyHash{Bits: 2048},
+				Username:   "john",
+			},
+		},
+	} {
+		var response apiUserStatusResponse
+		assert.Check(t, json.Unmarshal([]byte(test.raw), &response), name)
+		assert.Check(t, cmp.DeepEqual(response, test.expected), name)
+	}
+
+	for _, raw := range []string{
+		`{"principals":{"a":"b"}}`,
+		`{"ssh_key_hash":{"bits":"many"}}`,
+		`{"username":["john"]}`,
+	} {
+		var response apiUserStatusResponse
+		assert.Check(t, json.Unmarshal([]byte(raw), &response) != nil, raw)
+	}
+}
+
+func Test_dtoUserStatusResponse_tolerant(t *testing.T) {
+	status, err := dtoUserStatusResponse(apiUserStatusResponse{
+		Principals: []string{"a", " b", ""},
+		SSHKeyHash: apiUserStatusResponseSSHKeyHash{Rate: "medium"},
+		Status:     "disabled",
+		Username:   "john",
+	}, time.UTC, serverDecoding{})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(status.KeyState, KeyStateUnknown))
+	assert.Check(t, status.KeyExpiration.IsZero())
+	assert.Check(t, cmp.DeepEqual(status.KeyPrincipals, Principals{"a", "b"}))
+	assert.Check(t, cmp.Equal(status.KeyStrength, KeyStrengthMedium))
+
+	status, err = dtoUserStatusResponse(apiUserStatusResponse{Status: " active "}, time.UTC, serverDecoding{})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(status.KeyState, KeyStateActive))
+}
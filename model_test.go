@@ -1,8 +1,10 @@
 package cassh
 
 import (
+	"strings"
 	"testing"
 
+	"golang.org/x/crypto/ssh"
 	"gotest.tools/v3/assert"
 )
 
@@ -18,10 +20,43 @@ func Test_UserStatus_String(t *testing.T) {
 	}).String(), "[3] 1 (2)")
 }
 
+func Test_UserStatus_KeyMatches(t *testing.T) {
+	key := newVerificationTestSigner(t).PublicKey()
+	other := newVerificationTestSigner(t).PublicKey()
+
+	assert.Check(t, (UserStatus{KeyFingerprint: ssh.FingerprintSHA256(key)}).KeyMatches(key))
+	assert.Check(t, !(UserStatus{KeyFingerprint: ssh.FingerprintSHA256(key)}).KeyMatches(other))
+	assert.Check(t, (UserStatus{KeyFingerprint: "MD5:" + ssh.FingerprintLegacyMD5(key)}).KeyMatches(key))
+	assert.Check(t, (UserStatus{KeyFingerprint: strings.ToUpper(ssh.FingerprintLegacyMD5(key))}).KeyMatches(key))
+	assert.Check(t, !(UserStatus{KeyFingerprint: ssh.FingerprintLegacyMD5(key)}).KeyMatches(other))
+	assert.Check(t, !(UserStatus{}).KeyMatches(key))
+	assert.Check(t, !(UserStatus{KeyFingerprint: ssh.FingerprintSHA256(key)}).KeyMatches(nil))
+}
+
 func Test_KeyState_String(t *testing.T) {
 	assert.Equal(t, KeyState("foo").String(), "foo")
 }
 
+func Test_KeyState_Valid(t *testing.T) {
+	assert.Check(t, KeyStateActive.Valid())
+	assert.Check(t, KeyStateRevoked.Valid())
+	assert.Check(t, KeyStatePending.Valid())
+	assert.Check(t, !KeyStateUnknown.Valid())
+	assert.Check(t, !KeyState("active").Valid())
+}
+
+func Test_parseKeyState(t *testing.T) {
+	assert.Equal(t, parseKeyState("ACTIVE"), KeyStateActive)
+	assert.Equal(t, parseKeyState(" revoked\n"), KeyStateRevoked)
+	assert.Equal(t, parseKeyState("Pending"), KeyStatePending)
+	assert.Equal(t, parseKeyState("DISABLED"), KeyStateUnknown)
+	assert.Equal(t, parseKeyState(""), KeyStateUnknown)
+}
+
+func Test_KeyStrength_String(t *testing.T) {
+	assert.Equal(t, KeyStrengthHigh.String(), "HIGH")
+}
+
 func Test_Principals_Has(t *testing.T) {
 	principals := Principals{"a", "b", "c"}
 	assert.NilError(t, principals.Has("a", "b", "c"))
@@ -33,7 +68,3 @@ func Test_Principals_Has(t *testing.T) {
 func Test_Principal_String(t *testing.T) {
 	assert.Equal(t, Principal("foo").String(), "foo")
 }
-
-func Test_sentinelError_Error(t *testing.T) {
-	assert.Equal(t, sentinelError("foo").Error(), "foo")
-}
@@ -0,0 +1,373 @@
+package cassh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krostar/httpclient"
+
+	"github.com/krostar/cassh/internal/atomicfile"
+)
+
+const (
+	oidcDeviceCodeGrantType    = "urn:ietf:params:oauth:grant-type:device_code"
+	oidcDefaultPollingInterval = 5 * time.Second
+	oidcSlowDownIncrement      = 5 * time.Second
+)
+
+// OIDCDeviceAuthorization is what the user needs to approve the access requested by an OIDCDeviceFlow.
+type OIDCDeviceAuthorization struct {
+	// UserCode is the code the user has to check or enter on the verification page.
+	UserCode string
+	// VerificationURI is the page the user has to visit.
+	VerificationURI string
+	// VerificationURIComplete is the page the user has to visit, with the user code already filled, if supported.
+	VerificationURIComplete string
+	// Expiry is the time after which the user code cannot be used anymore.
+	Expiry time.Time
+}
+
+// OIDCDeviceFlow authenticates requests with an access token issued by an OpenID Connect provider
+// through the OAuth 2.0 device authorization grant (RFC 8628), useful on machines without a browser.
+// The user is asked to approve the access the first time a token is needed; afterward,
+// tokens are renewed with the refresh token, and the user is only asked again once it is not valid anymore.
+type OIDCDeviceFlow struct {
+	issuer   string
+	clientID string
+	o        *oidcDeviceFlowOptions
+
+	m           sync.Mutex
+	endpoints   *oidcEndpoints
+	token       *oidcToken
+	cacheLoaded bool
+}
+
+// NewOIDCDeviceFlow creates an OIDCDeviceFlow for the provided issuer, discovered using its well-known configuration.
+// Use it with SessionUserOptionAuthenticationMechanism or SessionAdminOptionAuthenticationMechanism.
+func NewOIDCDeviceFlow(issuer, clientID string, opts ...OIDCDeviceFlowOption) *OIDCDeviceFlow {
+	o := oidcDeviceFlowOptionsDefaults()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &OIDCDeviceFlow{
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		clientID: clientID,
+		o:        o,
+	}
+}
+
+type oidcEndpoints struct {
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+}
+
+type oidcToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+type oidcTokenCache struct {
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"client_id"`
+	oidcToken
+}
+
+// oidcError is an error returned by the identity provider, as defined by RFC 6749.
+type oidcError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (err *oidcError) Error() string {
+	if err.Description == "" {
+		return err.Code
+	}
+	return err.Code + ": " + err.Description
+}
+
+// Authenticate implements SessionAuthenticator.
+func (f *OIDCDeviceFlow) Authenticate(ctx context.Context, req *http.Request, _ url.Values) error {
+	token, err := f.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	if f.o.header != "" {
+		req.Header.Set(f.o.header, token.AccessToken)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	}
+
+	return nil
+}
+
+// Token returns a valid access token, asking the user to approve the access if it cannot be obtained otherwise.
+func (f *OIDCDeviceFlow) Token(ctx context.Context) (*BearerToken, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if !f.cacheLoaded {
+		f.token = f.loadCache()
+		f.cacheLoaded = true
+	}
+
+	if f.token != nil {
+		if token := (&BearerToken{AccessToken: f.token.AccessToken, Expiry: f.token.Expiry}); token.valid(f.o.now()) {
+			return token, nil
+		}
+	}
+
+	token, err := f.renewToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCredentialUnavailable, err)
+	}
+
+	f.token = token
+	f.saveCache()
+
+	return &BearerToken{AccessToken: token.AccessToken, Expiry: token.Expiry}, nil
+}
+
+func (f *OIDCDeviceFlow) renewToken(ctx context.Context) (*oidcToken, error) {
+	if err := f.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	if f.token != nil && f.token.RefreshToken != "" {
+		token, err := f.refresh(ctx, f.token.RefreshToken)
+
+		var oauthErr *oidcError
+		switch {
+		case err == nil:
+			return token, nil
+		case errors.As(err, &oauthErr) && oauthErr.Code == "invalid_grant":
+			// the refresh token has expired or has been revoked, the user has to approve the access again
+		default:
+			return nil, fmt.Errorf("unable to refresh token: %v", err)
+		}
+	}
+
+	token, err := f.deviceFlow(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to complete device authorization: %v", err)
+	}
+
+	return token, nil
+}
+
+func (f *OIDCDeviceFlow) discover(ctx context.Context) error {
+	if f.endpoints != nil {
+		return nil
+	}
+
+	var endpoints oidcEndpoints
+
+	if err := httpclient.
+		NewRequest(http.MethodGet, f.issuer+"/.well-known/openid-configuration").
+		Client(f.o.httpDoer).
+		Do(ctx).
+		ReceiveJSON(http.StatusOK, &endpoints).
+		Error(); err != nil {
+		return fmt.Errorf("unable to discover identity provider configuration: %v", err)
+	}
+
+	if endpoints.TokenEndpoint == "" {
+		return fmt.Errorf("identity provider %s does not have a token endpoint", f.issuer)
+	}
+
+	if endpoints.DeviceAuthorizationEndpoint == "" {
+		return fmt.Errorf("identity provider %s does not support the device authorization grant", f.issuer)
+	}
+
+	f.endpoints = &endpoints
+	return nil
+}
+
+func (f *OIDCDeviceFlow) refresh(ctx context.Context, refreshToken string) (*oidcToken, error) {
+	token, err := f.requestToken(ctx, url.Values{
+		"grant_type":    []string{"refresh_token"},
+		"refresh_token": []string{refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// providers may not rotate refresh tokens, in which case the current one is still valid
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+
+	return token, nil
+}
+
+func (f *OIDCDeviceFlow) deviceFlow(ctx context.Context) (*oidcToken, error) {
+	var authorization struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURL         string `json:"verification_url"` // some providers do not follow the RFC naming
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int64  `json:"expires_in"`
+		Interval                int64  `json:"interval"`
+	}
+
+	if err := f.send(ctx, f.endpoints.DeviceAuthorizationEndpoint, url.Values{
+		"scope": []string{strings.Join(f.o.scopes, " ")},
+	}, &authorization); err != nil {
+		return nil, fmt.Errorf("unable to request device authorization: %v", err)
+	}
+
+	if authorization.VerificationURI == "" {
+		authorization.VerificationURI = authorization.VerificationURL
+	}
+
+	expiry := f.o.now().Add(time.Duration(authorization.ExpiresIn) * time.Second)
+
+	if err := f.o.prompt(ctx, OIDCDeviceAuthorization{
+		UserCode:                authorization.UserCode,
+		VerificationURI:         authorization.VerificationURI,
+		VerificationURIComplete: authorization.VerificationURIComplete,
+		Expiry:                  expiry,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to prompt user: %v", err)
+	}
+
+	interval := time.Duration(authorization.Interval) * time.Second
+	if interval <= 0 {
+		interval = oidcDefaultPollingInterval
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-f.o.after(interval):
+		}
+
+		token, err := f.requestToken(ctx, url.Values{
+			"grant_type":  []string{oidcDeviceCodeGrantType},
+			"device_code": []string{authorization.DeviceCode},
+		})
+
+		var oauthErr *oidcError
+		switch {
+		case err == nil:
+			return token, nil
+		case errors.As(err, &oauthErr) && oauthErr.Code == "authorization_pending":
+		case errors.As(err, &oauthErr) && oauthErr.Code == "slow_down":
+			interval += oidcSlowDownIncrement
+		default:
+			return nil, err
+		}
+
+		if authorization.ExpiresIn > 0 && !f.o.now().Before(expiry) {
+			return nil, errors.New("user code expired before the access was approved")
+		}
+	}
+}
+
+func (f *OIDCDeviceFlow) requestToken(ctx context.Context, values url.Values) (*oidcToken, error) {
+	var response struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+
+	if err := f.send(ctx, f.endpoints.TokenEndpoint, values, &response); err != nil {
+		return nil, err
+	}
+
+	if response.AccessToken == "" {
+		return nil, errors.New("identity provider did not return any access token")
+	}
+
+	token := oidcToken{AccessToken: response.AccessToken, RefreshToken: response.RefreshToken}
+	if response.ExpiresIn > 0 {
+		token.Expiry = f.o.now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+
+	return &token, nil
+}
+
+// send posts the provided form, authenticated with the client credentials, and parses the JSON response.
+// Errors returned by the identity provider are returned as *oidcError.
+func (f *OIDCDeviceFlow) send(ctx context.Context, endpoint string, values url.Values, dest any) error {
+	values.Set("client_id", f.clientID)
+	if f.o.clientSecret != "" {
+		values.Set("client_secret", f.o.clientSecret)
+	}
+
+	return httpclient.
+		NewRequest(http.MethodPost, endpoint).
+		Client(f.o.httpDoer).
+		SendForm(values).
+		Do(ctx).
+		ReceiveJSON(http.StatusOK, dest).
+		OnStatuses([]int{http.StatusBadRequest, http.StatusUnauthorized}, func(resp *http.Response) error {
+			var oauthErr oidcError
+			if err := json.NewDecoder(resp.Body).Decode(&oauthErr); err != nil || oauthErr.Code == "" {
+				return fmt.Errorf("identity provider refused the request with status %d", resp.StatusCode)
+			}
+			return &oauthErr
+		}).
+		Error()
+}
+
+// loadCache returns the cached token, if any, and if it has been issued for the same issuer and client.
+func (f *OIDCDeviceFlow) loadCache() *oidcToken {
+	if f.o.cacheFile == "" {
+		return nil
+	}
+
+	raw, err := os.ReadFile(f.o.cacheFile)
+	if err != nil {
+		return nil
+	}
+
+	var cache oidcTokenCache
+	if err := json.Unmarshal(raw, &cache); err != nil || cache.Issuer != f.issuer || cache.ClientID != f.clientID {
+		return nil
+	}
+
+	return &cache.oidcToken
+}
+
+// saveCache caches the current token; failing to do so only means the user may have to approve the access again later.
+func (f *OIDCDeviceFlow) saveCache() {
+	if f.o.cacheFile == "" {
+		return
+	}
+
+	raw, err := json.Marshal(oidcTokenCache{Issuer: f.issuer, ClientID: f.clientID, oidcToken: *f.token})
+	if err != nil {
+		return
+	}
+
+	_ = atomicfile.WriteFile(f.o.cacheFile, raw, 0o600)
+}
+
+func (f *OIDCDeviceFlow) String() string { return "oidc(" + f.issuer + ", " + f.clientID + ")" }
+
+func (f *OIDCDeviceFlow) GoString() string {
+	return fmt.Sprintf("cassh.OIDCDeviceFlow{issuer:%q, clientID:%q}", f.issuer, f.clientID)
+}
+
+// LogValue never logs the tokens.
+func (f *OIDCDeviceFlow) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("mechanism", "oidc"),
+		slog.String("issuer", f.issuer),
+		slog.String("client_id", f.clientID),
+	)
+}
@@ -0,0 +1,93 @@
+package cassh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/krostar/httpclient"
+)
+
+// OIDCDeviceFlowOption defines the signature of all options usable on NewOIDCDeviceFlow.
+type OIDCDeviceFlowOption func(o *oidcDeviceFlowOptions)
+
+type oidcDeviceFlowOptions struct {
+	scopes       []string
+	clientSecret string
+	httpDoer     httpclient.Doer
+	prompt       func(ctx context.Context, authorization OIDCDeviceAuthorization) error
+	cacheFile    string
+	header       string
+
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+func oidcDeviceFlowOptionsDefaults() *oidcDeviceFlowOptions {
+	return &oidcDeviceFlowOptions{
+		scopes:   []string{"openid", "offline_access"},
+		httpDoer: http.DefaultClient,
+		prompt:   oidcDeviceFlowPromptStderr,
+		now:      time.Now,
+		after:    time.After,
+	}
+}
+
+// oidcDeviceFlowPromptStderr asks the user to approve the access on the standard error.
+func oidcDeviceFlowPromptStderr(_ context.Context, authorization OIDCDeviceAuthorization) error {
+	if authorization.VerificationURIComplete != "" {
+		_, err := fmt.Fprintf(os.Stderr, "To authenticate, visit %s and check the code is %s\n", authorization.VerificationURIComplete, authorization.UserCode)
+		return err
+	}
+	_, err := fmt.Fprintf(os.Stderr, "To authenticate, visit %s and enter the code %s\n", authorization.VerificationURI, authorization.UserCode)
+	return err
+}
+
+// OIDCDeviceFlowOptionScopes sets the scopes requested to the identity provider.
+// It defaults to "openid" and "offline_access", the latter being needed by most providers to get a refresh token.
+func OIDCDeviceFlowOptionScopes(scopes ...string) OIDCDeviceFlowOption {
+	return func(o *oidcDeviceFlowOptions) {
+		o.scopes = scopes
+	}
+}
+
+// OIDCDeviceFlowOptionClientSecret sets the secret sent with the client id, for providers not supporting public clients.
+func OIDCDeviceFlowOptionClientSecret(clientSecret string) OIDCDeviceFlowOption {
+	return func(o *oidcDeviceFlowOptions) {
+		o.clientSecret = clientSecret
+	}
+}
+
+// OIDCDeviceFlowOptionHTTPClient sets the http client used to talk to the identity provider.
+func OIDCDeviceFlowOptionHTTPClient(httpDoer httpclient.Doer) OIDCDeviceFlowOption {
+	return func(o *oidcDeviceFlowOptions) {
+		o.httpDoer = httpDoer
+	}
+}
+
+// OIDCDeviceFlowOptionPrompt sets the function asking the user to approve the access.
+// It defaults to printing the verification url and the user code on the standard error.
+func OIDCDeviceFlowOptionPrompt(prompt func(ctx context.Context, authorization OIDCDeviceAuthorization) error) OIDCDeviceFlowOption {
+	return func(o *oidcDeviceFlowOptions) {
+		o.prompt = prompt
+	}
+}
+
+// OIDCDeviceFlowOptionCacheFile sets the file where tokens are cached,
+// so the user does not have to approve the access again as long as the refresh token is valid.
+// The file is only readable by its owner.
+func OIDCDeviceFlowOptionCacheFile(path string) OIDCDeviceFlowOption {
+	return func(o *oidcDeviceFlowOptions) {
+		o.cacheFile = path
+	}
+}
+
+// OIDCDeviceFlowOptionHeader sets the header the access token is sent with, as is,
+// instead of the Authorization header with the Bearer scheme.
+func OIDCDeviceFlowOptionHeader(name string) OIDCDeviceFlowOption {
+	return func(o *oidcDeviceFlowOptions) {
+		o.header = name
+	}
+}
@@ -0,0 +1,60 @@
+package cassh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_oidcDeviceFlowOptionsDefaults(t *testing.T) {
+	opts := oidcDeviceFlowOptionsDefaults()
+	assert.Check(t, cmp.DeepEqual(opts.scopes, []string{"openid", "offline_access"}))
+	assert.Check(t, cmp.Equal(opts.clientSecret, ""))
+	assert.Check(t, opts.httpDoer == http.DefaultClient)
+	assert.Check(t, opts.prompt != nil)
+	assert.Check(t, cmp.Equal(opts.cacheFile, ""))
+	assert.Check(t, cmp.Equal(opts.header, ""))
+	assert.Check(t, opts.now != nil)
+	assert.Check(t, opts.after != nil)
+}
+
+func Test_OIDCDeviceFlowOptionScopes(t *testing.T) {
+	opts := oidcDeviceFlowOptionsDefaults()
+	OIDCDeviceFlowOptionScopes("openid", "cassh")(opts)
+	assert.Check(t, cmp.DeepEqual(opts.scopes, []string{"openid", "cassh"}))
+}
+
+func Test_OIDCDeviceFlowOptionClientSecret(t *testing.T) {
+	opts := oidcDeviceFlowOptionsDefaults()
+	OIDCDeviceFlowOptionClientSecret("secret")(opts)
+	assert.Check(t, cmp.Equal(opts.clientSecret, "secret"))
+}
+
+func Test_OIDCDeviceFlowOptionHTTPClient(t *testing.T) {
+	opts := oidcDeviceFlowOptionsDefaults()
+	client := new(http.Client)
+	OIDCDeviceFlowOptionHTTPClient(client)(opts)
+	assert.Check(t, opts.httpDoer == client)
+}
+
+func Test_OIDCDeviceFlowOptionPrompt(t *testing.T) {
+	opts := oidcDeviceFlowOptionsDefaults()
+	OIDCDeviceFlowOptionPrompt(func(context.Context, OIDCDeviceAuthorization) error { return errors.New("boom") })(opts)
+	assert.Check(t, cmp.Error(opts.prompt(context.Background(), OIDCDeviceAuthorization{}), "boom"))
+}
+
+func Test_OIDCDeviceFlowOptionCacheFile(t *testing.T) {
+	opts := oidcDeviceFlowOptionsDefaults()
+	OIDCDeviceFlowOptionCacheFile("/oidc.json")(opts)
+	assert.Check(t, cmp.Equal(opts.cacheFile, "/oidc.json"))
+}
+
+func Test_OIDCDeviceFlowOptionHeader(t *testing.T) {
+	opts := oidcDeviceFlowOptionsDefaults()
+	OIDCDeviceFlowOptionHeader("X-Forwarded-Access-Token")(opts)
+	assert.Check(t, cmp.Equal(opts.header, "X-Forwarded-Access-Token"))
+}
@@ -0,0 +1,356 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// oidcTestIdP is a fake identity provider supporting the device authorization and refresh token grants.
+type oidcTestIdP struct {
+	*httptest.Server
+
+	m               sync.Mutex
+	pendingPolls    []string // errors returned by the token endpoint before the access is approved
+	issued          int
+	refreshRevoked  bool
+	noDeviceSupport bool
+	requests        []map[string]string
+}
+
+func newOIDCTestIdP(t *testing.T) *oidcTestIdP {
+	idp := new(oidcTestIdP)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		configuration := map[string]string{"issuer": idp.URL, "token_endpoint": idp.URL + "/token"}
+		if !idp.noDeviceSupport {
+			configuration["device_authorization_endpoint"] = idp.URL + "/device"
+		}
+		_ = json.NewEncoder(w).Encode(configuration)
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		idp.record(r)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"device_code":      "device-code",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": idp.URL + "/activate",
+			"expires_in":       600,
+			"interval":         1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		parameters := idp.record(r)
+
+		idp.m.Lock()
+		defer idp.m.Unlock()
+
+		oauthError := func(code string) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+		}
+
+		switch parameters["grant_type"] {
+		case oidcDeviceCodeGrantType:
+			if parameters["device_code"] != "device-code" {
+				oauthError("invalid_grant")
+				return
+			}
+			if len(idp.pendingPolls) > 0 {
+				code := idp.pendingPolls[0]
+				idp.pendingPolls = idp.pendingPolls[1:]
+				oauthError(code)
+				return
+			}
+		case "refresh_token":
+			if idp.refreshRevoked || parameters["refresh_token"] != "refresh" {
+				oauthError("invalid_grant")
+				return
+			}
+		default:
+			oauthError("unsupported_grant_type")
+			return
+		}
+
+		idp.issued++
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", idp.issued),
+			"refresh_token": "refresh",
+			"token_type":    "Bearer",
+			"expires_in":    60,
+		})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+func (idp *oidcTestIdP) record(r *http.Request) map[string]string {
+	_ = r.ParseForm()
+
+	parameters := make(map[string]string)
+	for key := range r.PostForm {
+		parameters[key] = r.PostForm.Get(key)
+	}
+	parameters["path"] = r.URL.Path
+
+	idp.m.Lock()
+	idp.requests = append(idp.requests, parameters)
+	idp.m.Unlock()
+
+	return parameters
+}
+
+// newOIDCTestDeviceFlow creates a device flow against the provided identity provider,
+// which does not wait between polls, and records the prompts and the polling intervals.
+func newOIDCTestDeviceFlow(idp *oidcTestIdP, now *time.Time, prompts *[]OIDCDeviceAuthorization, intervals *[]time.Duration, opts ...OIDCDeviceFlowOption) *OIDCDeviceFlow {
+	flow := NewOIDCDeviceFlow(idp.URL+"/", "cassh", append([]OIDCDeviceFlowOption{
+		OIDCDeviceFlowOptionHTTPClient(idp.Client()),
+		OIDCDeviceFlowOptionPrompt(func(_ context.Context, authorization OIDCDeviceAuthorization) error {
+			*prompts = append(*prompts, authorization)
+			return nil
+		}),
+	}, opts...)...)
+
+	flow.o.now = func() time.Time { return *now }
+	flow.o.after = func(d time.Duration) <-chan time.Time {
+		*intervals = append(*intervals, d)
+		c := make(chan time.Time, 1)
+		c <- *now
+		return c
+	}
+
+	return flow
+}
+
+func Test_OIDCDeviceFlow(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("device flow, refresh, and cache", func(t *testing.T) {
+		idp := newOIDCTestIdP(t)
+		idp.pendingPolls = []string{"authorization_pending", "slow_down", "authorization_pending"}
+
+		var (
+			now       = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			prompts   []OIDCDeviceAuthorization
+			intervals []time.Duration
+			cacheFile = filepath.Join(t.TempDir(), "oidc.json")
+		)
+
+		flow := newOIDCTestDeviceFlow(idp, &now, &prompts, &intervals, OIDCDeviceFlowOptionCacheFile(cacheFile), OIDCDeviceFlowOptionScopes("openid", "cassh"))
+
+		token, err := flow.Token(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(*token, BearerToken{AccessToken: "access-1", Expiry: now.Add(time.Minute)}))
+		assert.Check(t, cmp.DeepEqual(prompts, []OIDCDeviceAuthorization{{
+			UserCode:        "ABCD-EFGH",
+			VerificationURI: idp.URL + "/activate",
+			Expiry:          now.Add(10 * time.Minute),
+		}}))
+		assert.Check(t, cmp.DeepEqual(intervals, []time.Duration{time.Second, time.Second, 6 * time.Second, 6 * time.Second}))
+		assert.Check(t, cmp.DeepEqual(idp.requests[0], map[string]string{"path": "/device", "client_id": "cassh", "scope": "openid cassh"}))
+
+		stat, err := os.Stat(cacheFile)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(stat.Mode().Perm(), os.FileMode(0o600)))
+
+		token, err = flow.Token(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(token.AccessToken, "access-1"), "token is still valid")
+
+		now = now.Add(time.Minute)
+		token, err = flow.Token(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(token.AccessToken, "access-2"), "token is refreshed")
+		assert.Check(t, cmp.DeepEqual(idp.requests[len(idp.requests)-1], map[string]string{
+			"path": "/token", "client_id": "cassh", "grant_type": "refresh_token", "refresh_token": "refresh",
+		}))
+		assert.Check(t, cmp.Len(prompts, 1))
+
+		prompts = nil
+		now = now.Add(time.Hour)
+		token, err = newOIDCTestDeviceFlow(idp, &now, &prompts, &intervals, OIDCDeviceFlowOptionCacheFile(cacheFile)).Token(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(token.AccessToken, "access-3"), "cached refresh token is used")
+		assert.Check(t, cmp.Len(prompts, 0))
+
+		idp.refreshRevoked = true
+		now = now.Add(time.Hour)
+		token, err = newOIDCTestDeviceFlow(idp, &now, &prompts, &intervals, OIDCDeviceFlowOptionCacheFile(cacheFile)).Token(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(token.AccessToken, "access-4"), "user approves again once the refresh token is revoked")
+		assert.Check(t, cmp.Len(prompts, 1))
+	})
+
+	t.Run("cache of another client is ignored", func(t *testing.T) {
+		idp := newOIDCTestIdP(t)
+
+		var (
+			now       = time.Now()
+			prompts   []OIDCDeviceAuthorization
+			intervals []time.Duration
+			cacheFile = filepath.Join(t.TempDir(), "oidc.json")
+		)
+
+		assert.NilError(t, os.WriteFile(cacheFile, []byte(`{"issuer":"https://other.local","client_id":"cassh","access_token":"other"}`), 0o600))
+
+		token, err := newOIDCTestDeviceFlow(idp, &now, &prompts, &intervals, OIDCDeviceFlowOptionCacheFile(cacheFile)).Token(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(token.AccessToken, "access-1"))
+		assert.Check(t, cmp.Len(prompts, 1))
+	})
+
+	t.Run("client secret and custom header", func(t *testing.T) {
+		idp := newOIDCTestIdP(t)
+
+		var (
+			now       = time.Now()
+			prompts   []OIDCDeviceAuthorization
+			intervals []time.Duration
+		)
+
+		flow := newOIDCTestDeviceFlow(idp, &now, &prompts, &intervals, OIDCDeviceFlowOptionClientSecret("secret"), OIDCDeviceFlowOptionHeader("X-Forwarded-Access-Token"))
+
+		req, err := http.NewRequest(http.MethodPost, "https://cassh.local/client", http.NoBody)
+		assert.NilError(t, err)
+		assert.NilError(t, flow.Authenticate(ctx, req, nil))
+		assert.Check(t, cmp.Equal(req.Header.Get("X-Forwarded-Access-Token"), "access-1"))
+		assert.Check(t, cmp.Equal(req.Header.Get("Authorization"), ""))
+
+		for _, request := range idp.requests {
+			assert.Check(t, cmp.Equal(request["client_secret"], "secret"))
+		}
+	})
+
+	t.Run("access denied", func(t *testing.T) {
+		idp := newOIDCTestIdP(t)
+		idp.pendingPolls = []string{"authorization_pending", "access_denied"}
+
+		var (
+			now       = time.Now()
+			prompts   []OIDCDeviceAuthorization
+			intervals []time.Duration
+		)
+
+		_, err := newOIDCTestDeviceFlow(idp, &now, &prompts, &intervals).Token(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "unable to complete device authorization: access_denied"))
+	})
+
+	t.Run("user code expired", func(t *testing.T) {
+		idp := newOIDCTestIdP(t)
+		idp.pendingPolls = []string{"authorization_pending", "authorization_pending"}
+
+		var (
+			now       = time.Now()
+			prompts   []OIDCDeviceAuthorization
+			intervals []time.Duration
+		)
+
+		flow := newOIDCTestDeviceFlow(idp, &now, &prompts, &intervals)
+		flow.o.after = func(time.Duration) <-chan time.Time {
+			now = now.Add(6 * time.Minute)
+			return time.After(0)
+		}
+
+		_, err := flow.Token(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "user code expired before the access was approved"))
+	})
+
+	t.Run("context canceled while polling", func(t *testing.T) {
+		idp := newOIDCTestIdP(t)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		flow := NewOIDCDeviceFlow(idp.URL, "cassh",
+			OIDCDeviceFlowOptionHTTPClient(idp.Client()),
+			OIDCDeviceFlowOptionPrompt(func(context.Context, OIDCDeviceAuthorization) error {
+				cancel()
+				return nil
+			}),
+		)
+
+		_, err := flow.Token(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, context.Canceled.Error()))
+	})
+
+	t.Run("prompt failure", func(t *testing.T) {
+		idp := newOIDCTestIdP(t)
+
+		flow := NewOIDCDeviceFlow(idp.URL, "cassh",
+			OIDCDeviceFlowOptionHTTPClient(idp.Client()),
+			OIDCDeviceFlowOptionPrompt(func(context.Context, OIDCDeviceAuthorization) error { return errors.New("boom") }),
+		)
+
+		_, err := flow.Token(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "unable to prompt user: boom"))
+	})
+
+	t.Run("device authorization grant not supported", func(t *testing.T) {
+		idp := newOIDCTestIdP(t)
+		idp.noDeviceSupport = true
+
+		_, err := NewOIDCDeviceFlow(idp.URL, "cassh", OIDCDeviceFlowOptionHTTPClient(idp.Client())).Token(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "does not support the device authorization grant"))
+	})
+}
+
+func Test_OIDCDeviceFlow_sessions(t *testing.T) {
+	ctx := context.Background()
+	idp := newOIDCTestIdP(t)
+
+	var (
+		now       = time.Now()
+		prompts   []OIDCDeviceAuthorization
+		intervals []time.Duration
+	)
+
+	flow := newOIDCTestDeviceFlow(idp, &now, &prompts, &intervals)
+
+	doer := new(redactionTestDoer)
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	assert.NilError(t, client.SessionUser("john", SessionUserOptionAuthenticationMechanism(flow)).Key(newVerificationTestSigner(t).PublicKey()).Set(ctx))
+	assert.Check(t, cmp.Equal(doer.header.Get("Authorization"), "Bearer access-1"))
+
+	assert.NilError(t, client.SessionAdmin(SessionAdminOptionAuthenticationMechanism(flow)).CheckAuthentication(ctx))
+	assert.Check(t, cmp.Equal(doer.header.Get("Authorization"), "Bearer access-1"))
+
+	assert.Check(t, cmp.Len(prompts, 1))
+}
+
+func Test_OIDCDeviceFlow_String(t *testing.T) {
+	flow := NewOIDCDeviceFlow("https://idp.local/", "cassh")
+	flow.token = &oidcToken{AccessToken: "access", RefreshToken: "refresh"}
+
+	var logs bytes.Buffer
+	slog.New(slog.NewTextHandler(&logs, nil)).Info("session", "auth", flow)
+
+	assert.Check(t, cmp.Equal(fmt.Sprint(flow), "oidc(https://idp.local, cassh)"))
+	assert.Check(t, cmp.Equal(fmt.Sprintf("%#v", flow), `cassh.OIDCDeviceFlow{issuer:"https://idp.local", clientID:"cassh"}`))
+	assert.Check(t, cmp.Contains(logs.String(), "auth.mechanism=oidc auth.issuer=https://idp.local auth.client_id=cassh"))
+	assert.Check(t, !strings.Contains(logs.String(), "access"))
+	assert.Check(t, !strings.Contains(logs.String(), "refresh"))
+}
@@ -0,0 +1,66 @@
+package cassh
+
+import (
+	"context"
+)
+
+// operation identifies a call made to the CASSH server. It is attached to the context of its requests,
+// to let wrapped http clients adapt their behavior to the call being made.
+type operation string
+
+const (
+	operationPing                      operation = "cassh.ping"
+	operationHealth                    operation = "cassh.health"
+	operationAuthority                 operation = "cassh.authority"
+	operationKRL                       operation = "cassh.krl"
+	operationUserStatus                operation = "cassh.user.status"
+	operationUserKeySet                operation = "cassh.user.key.set"
+	operationUserKeySign               operation = "cassh.user.key.sign"
+	operationAdminCheckAuth            operation = "cassh.admin.check_authentication"
+	operationAdminUsers                operation = "cassh.admin.users"
+	operationAdminUserStatus           operation = "cassh.admin.user.status"
+	operationAdminUserKeyActivate      operation = "cassh.admin.user.key.activate"
+	operationAdminUserKeyRevoke        operation = "cassh.admin.user.key.revoke"
+	operationAdminUserKeySetExpiry     operation = "cassh.admin.user.key.set_expiry"
+	operationAdminUserKeyDelete        operation = "cassh.admin.user.key.delete"
+	operationAdminUserPrincipalsAdd    operation = "cassh.admin.user.principals.add"
+	operationAdminUserPrincipalsRemove operation = "cassh.admin.user.principals.remove"
+	operationAdminUserPrincipalsSet    operation = "cassh.admin.user.principals.set"
+	operationAdminUserPrincipalsReset  operation = "cassh.admin.user.principals.reset"
+)
+
+// idempotent returns whenever the operation can be safely made several times.
+func (op operation) idempotent() bool {
+	switch op {
+	case operationPing, operationHealth, operationAuthority, operationKRL, operationUserStatus:
+		return true
+	default:
+		return false
+	}
+}
+
+type operationContextKey struct{}
+
+type operationContext struct {
+	op       operation
+	username Username
+}
+
+func withOperation(ctx context.Context, op operation) context.Context {
+	return context.WithValue(ctx, operationContextKey{}, operationContext{op: op})
+}
+
+// withUserOperation attaches the operation, and the user it is made for, to the context.
+func withUserOperation(ctx context.Context, op operation, username Username) context.Context {
+	return context.WithValue(ctx, operationContextKey{}, operationContext{op: op, username: username})
+}
+
+func operationFromContext(ctx context.Context) (operation, bool) {
+	opCtx, ok := ctx.Value(operationContextKey{}).(operationContext)
+	return opCtx.op, ok
+}
+
+func operationUsernameFromContext(ctx context.Context) Username {
+	opCtx, _ := ctx.Value(operationContextKey{}).(operationContext)
+	return opCtx.username
+}
@@ -0,0 +1,206 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/krostar/httpclient"
+)
+
+// RedactedValue replaces the value of sensitive request parameters and headers.
+const RedactedValue = "[REDACTED]"
+
+// sensitiveRequestParameters lists the request parameters carrying secrets, never exposed as is.
+var sensitiveRequestParameters = map[string]bool{
+	"password": true,
+	"pubkey":   true,
+}
+
+// sensitiveRequestHeaders lists the request headers carrying secrets, never exposed as is.
+var sensitiveRequestHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// sensitiveRequestHeaderWords lists the words found in the name of custom headers carrying secrets, like X-Auth-Token.
+var sensitiveRequestHeaderWords = []string{"auth", "token", "secret", "password", "key", "session"}
+
+// IsSensitiveRequestParameter returns whether the value of the provided request parameter must never be exposed.
+func IsSensitiveRequestParameter(name string) bool {
+	return sensitiveRequestParameters[strings.ToLower(name)]
+}
+
+// IsSensitiveRequestHeader returns whether the value of the provided request header must never be exposed,
+// custom headers being considered sensitive when their name contains words like "auth", "token", or "key".
+func IsSensitiveRequestHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if sensitiveRequestHeaders[name] {
+		return true
+	}
+
+	name = strings.ToLower(name)
+	for _, word := range sensitiveRequestHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RedactRequestParameters returns a copy of the parameters with sensitive values redacted.
+func RedactRequestParameters(parameters url.Values) url.Values {
+	redacted := make(url.Values, len(parameters))
+	for key, values := range parameters {
+		if IsSensitiveRequestParameter(key) {
+			values = []string{RedactedValue}
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+// DoerWrapRedacted wraps the provided doer with the doer created by inspector, which only sees redacted requests:
+// sensitive parameters of the form body and query, and sensitive headers, are replaced before reaching the inspector,
+// and restored before reaching the provided doer. It is meant to safely plug logging or dumping doers.
+//
+//	cassh.ClientOptionHTTPClient(cassh.DoerWrapRedacted(http.DefaultClient, func(doer httpclient.Doer) httpclient.Doer {
+//		return httpclient.DoerWrapDumpB64(doer, dump)
+//	}))
+func DoerWrapRedacted(doer httpclient.Doer, inspector func(httpclient.Doer) httpclient.Doer) httpclient.Doer {
+	if inspector == nil {
+		return doer
+	}
+	return &redactingDoer{doer: inspector(&unredactingDoer{doer: doer}), wrapped: doer, inspector: inspector}
+}
+
+type redactedRequestContextKey struct{}
+
+// redactedRequest stores what has been redacted from a request to restore it later on.
+type redactedRequest struct {
+	rawQuery      string
+	header        http.Header
+	getBody       func() (io.ReadCloser, error)
+	contentLength int64
+}
+
+type redactingDoer struct {
+	doer httpclient.Doer
+
+	// wrapped and inspector allow to wrap again a configured copy of the wrapped doer, like with ClientOptionTLS
+	wrapped   httpclient.Doer
+	inspector func(httpclient.Doer) httpclient.Doer
+}
+
+func (d *redactingDoer) Do(req *http.Request) (*http.Response, error) {
+	redacted, err := redactRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return d.doer.Do(redacted)
+}
+
+type unredactingDoer struct {
+	doer httpclient.Doer
+}
+
+func (d *unredactingDoer) Do(req *http.Request) (*http.Response, error) {
+	unredacted, err := unredactRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return d.doer.Do(unredacted)
+}
+
+// redactRequest returns a copy of the request without sensitive values, the original request being kept in the context.
+func redactRequest(req *http.Request) (*http.Request, error) {
+	original := redactedRequest{
+		rawQuery:      req.URL.RawQuery,
+		header:        req.Header,
+		getBody:       req.GetBody,
+		contentLength: req.ContentLength,
+	}
+
+	redacted := req.Clone(context.WithValue(req.Context(), redactedRequestContextKey{}, original))
+
+	if req.URL.RawQuery != "" {
+		redacted.URL.RawQuery = RedactRequestParameters(req.URL.Query()).Encode()
+	}
+
+	for name := range redacted.Header {
+		if IsSensitiveRequestHeader(name) {
+			redacted.Header[name] = []string{RedactedValue}
+		}
+	}
+
+	if parameters := requestParameters(req); parameters != nil {
+		body := []byte(RedactRequestParameters(parameters).Encode())
+		redacted.ContentLength = int64(len(body))
+		redacted.Body = io.NopCloser(bytes.NewReader(body))
+		redacted.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	} else if req.Body != nil && req.Body != http.NoBody && req.GetBody != nil {
+		// the body is consumed by the inspector, give it its own
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		redacted.Body = body
+	}
+
+	return redacted, nil
+}
+
+// unredactRequest restores the sensitive values of a request redacted by redactRequest.
+// Redacted requests must never be sent, an error is returned if the original body cannot be restored.
+func unredactRequest(req *http.Request) (*http.Request, error) {
+	original, ok := req.Context().Value(redactedRequestContextKey{}).(redactedRequest)
+	if !ok {
+		return req, nil
+	}
+
+	restored := req.Clone(req.Context())
+	restored.URL.RawQuery = original.rawQuery
+
+	for name, values := range original.header {
+		if IsSensitiveRequestHeader(name) {
+			restored.Header[name] = values
+		}
+	}
+
+	if original.getBody != nil {
+		body, err := original.getBody()
+		if err != nil {
+			return nil, fmt.Errorf("unable to restore redacted request body: %v", err)
+		}
+		restored.Body = body
+		restored.GetBody = original.getBody
+		restored.ContentLength = original.contentLength
+	}
+
+	return restored, nil
+}
+
+// redactSecret returns the value to print in place of the provided secret, empty secrets staying empty.
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return RedactedValue
+}
+
+// describeSecretHolder describes something holding secrets, like an authentication mechanism or a credential provider,
+// without exposing anything it may hold.
+func describeSecretHolder(v any) string {
+	if stringer, ok := v.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", v)
+}
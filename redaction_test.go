@@ -0,0 +1,161 @@
+package cassh
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/krostar/httpclient"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type redactionTestDoer struct {
+	query  string
+	header http.Header
+	body   string
+	length int64
+	next   httpclient.Doer
+}
+
+func (d *redactionTestDoer) Do(req *http.Request) (*http.Response, error) {
+	d.query = req.URL.RawQuery
+	d.header = req.Header.Clone()
+	d.length = req.ContentLength
+	if req.Body != nil {
+		raw, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		d.body = string(raw)
+	}
+
+	if d.next != nil {
+		return d.next.Do(req)
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func Test_IsSensitiveRequestParameter(t *testing.T) {
+	assert.Check(t, IsSensitiveRequestParameter("password"))
+	assert.Check(t, IsSensitiveRequestParameter("Password"))
+	assert.Check(t, IsSensitiveRequestParameter("pubkey"))
+	assert.Check(t, !IsSensitiveRequestParameter("realname"))
+	assert.Check(t, !IsSensitiveRequestParameter("username"))
+}
+
+func Test_RedactRequestParameters(t *testing.T) {
+	parameters := url.Values{"username": {"john"}, "password": {"secret"}, "pubkey": {"ssh-ed25519 AAAA"}}
+
+	assert.Check(t, cmp.DeepEqual(RedactRequestParameters(parameters), url.Values{
+		"username": {"john"},
+		"password": {"[REDACTED]"},
+		"pubkey":   {"[REDACTED]"},
+	}))
+	assert.Check(t, cmp.Equal(parameters.Get("password"), "secret"), "parameters must not be modified")
+}
+
+func Test_DoerWrapRedacted(t *testing.T) {
+	newRequest := func(t *testing.T) *http.Request {
+		body := url.Values{"realname": {"john"}, "password": {"secret"}}.Encode()
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "https://cassh.local/client?password=secret&v=1", strings.NewReader(body))
+		assert.NilError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("X-Auth-Token", "token")
+		req.Header.Set("Accept", "application/json")
+		return req
+	}
+
+	t.Run("inspector sees redacted requests, server sees the original ones", func(t *testing.T) {
+		server := new(redactionTestDoer)
+		inspector := new(redactionTestDoer)
+
+		doer := DoerWrapRedacted(server, func(doer httpclient.Doer) httpclient.Doer {
+			inspector.next = doer
+			return inspector
+		})
+
+		req := newRequest(t)
+		resp, err := doer.Do(req)
+		assert.NilError(t, err)
+		assert.NilError(t, resp.Body.Close())
+
+		assert.Check(t, cmp.Equal(inspector.query, "password=%5BREDACTED%5D&v=1"))
+		assert.Check(t, cmp.Equal(inspector.header.Get("Authorization"), RedactedValue))
+		assert.Check(t, cmp.Equal(inspector.header.Get("X-Auth-Token"), RedactedValue))
+		assert.Check(t, cmp.Equal(inspector.header.Get("Accept"), "application/json"))
+		assert.Check(t, cmp.Equal(inspector.body, "password=%5BREDACTED%5D&realname=john"))
+		assert.Check(t, cmp.Equal(inspector.length, int64(len(inspector.body))))
+
+		assert.Check(t, cmp.Equal(server.query, "password=secret&v=1"))
+		assert.Check(t, cmp.Equal(server.header.Get("Authorization"), "Bearer token"))
+		assert.Check(t, cmp.Equal(server.header.Get("X-Auth-Token"), "token"))
+		assert.Check(t, cmp.Equal(server.header.Get("Accept"), "application/json"))
+		assert.Check(t, cmp.Equal(server.body, "password=secret&realname=john"))
+		assert.Check(t, cmp.Equal(server.length, int64(len(server.body))))
+
+		assert.Check(t, cmp.Equal(req.Header.Get("Authorization"), "Bearer token"), "original request must not be modified")
+	})
+
+	t.Run("dumping doer never sees secrets", func(t *testing.T) {
+		server := new(redactionTestDoer)
+
+		var dump string
+		doer := DoerWrapRedacted(server, func(doer httpclient.Doer) httpclient.Doer {
+			return httpclient.DoerWrapDumpB64(doer, func(requestB64, _ string) { dump = requestB64 })
+		})
+
+		resp, err := doer.Do(newRequest(t))
+		assert.NilError(t, err)
+		assert.NilError(t, resp.Body.Close())
+
+		raw, err := base64.StdEncoding.DecodeString(dump)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Contains(string(raw), "realname=john"))
+		assert.Check(t, !strings.Contains(string(raw), "secret"))
+		assert.Check(t, cmp.Equal(server.body, "password=secret&realname=john"))
+	})
+
+	t.Run("body not restored", func(t *testing.T) {
+		server := new(redactionTestDoer)
+		doer := DoerWrapRedacted(server, func(doer httpclient.Doer) httpclient.Doer { return doer })
+
+		req := newRequest(t)
+		getBody, calls := req.GetBody, 0
+		req.GetBody = func() (io.ReadCloser, error) {
+			if calls++; calls > 1 {
+				return nil, errors.New("boom")
+			}
+			return getBody()
+		}
+
+		_, err := doer.Do(req)
+		assert.Check(t, cmp.ErrorContains(err, "unable to restore redacted request body: boom"))
+		assert.Check(t, server.header == nil, "redacted request must not be sent")
+	})
+
+	t.Run("without inspector", func(t *testing.T) {
+		server := new(redactionTestDoer)
+		assert.Check(t, DoerWrapRedacted(server, nil) == httpclient.Doer(server))
+	})
+}
+
+func Test_IsSensitiveRequestHeader(t *testing.T) {
+	for _, name := range []string{"Authorization", "authorization", "Proxy-Authorization", "Cookie", "X-Auth-Token", "X-Api-Key", "X-Forwarded-Access-Token", "X-Session-Id"} {
+		assert.Check(t, IsSensitiveRequestHeader(name), name)
+	}
+	for _, name := range []string{"Accept", "Content-Type", "User-Agent", "Client_version", "X-Request-Id"} {
+		assert.Check(t, !IsSensitiveRequestHeader(name), name)
+	}
+}
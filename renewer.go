@@ -0,0 +1,215 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/krostar/cassh/internal/atomicfile"
+)
+
+// Renewer creates a renewer keeping the certificate of the user key, written at the provided path, fresh.
+func (s *SessionUserKey) Renewer(certificatePath string, opts ...RenewerOption) *Renewer {
+	o := renewerOptionsDefaults()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Renewer{
+		key:             s,
+		certificatePath: certificatePath,
+		o:               o,
+	}
+}
+
+// Renewer signs a user key again each time its certificate is about to expire.
+type Renewer struct {
+	key             *SessionUserKey
+	certificatePath string
+	o               *renewerOptions
+}
+
+// RenewerEventType defines the different kinds of events emitted by the renewer.
+type RenewerEventType string
+
+const (
+	// RenewerEventScheduled is emitted when the next renewal is planned, At being the time of the renewal.
+	RenewerEventScheduled RenewerEventType = "scheduled"
+	// RenewerEventRenewed is emitted when a new certificate has been written, Certificate being the new certificate.
+	RenewerEventRenewed RenewerEventType = "renewed"
+	// RenewerEventFailed is emitted when a renewal failed, Err being the cause and At the time of the next attempt.
+	RenewerEventFailed RenewerEventType = "failed"
+)
+
+// RenewerEvent describes something that happened in the renewer.
+type RenewerEvent struct {
+	Type        RenewerEventType
+	At          time.Time
+	Certificate *ssh.Certificate
+	Err         error
+}
+
+// Certificate returns the certificate currently written, or nil if there is none for the user key.
+func (r *Renewer) Certificate() (*ssh.Certificate, error) {
+	raw, err := os.ReadFile(r.certificatePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read certificate: %v", err)
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %v", err)
+	}
+
+	certificate, ok := publicKey.(*ssh.Certificate)
+	if !ok || !bytes.Equal(certificate.Key.Marshal(), r.key.key.Marshal()) {
+		return nil, nil
+	}
+
+	return certificate, nil
+}
+
+// NextRenewal returns the time at which the current certificate should be renewed.
+// Missing, unreadable, or certificates of other keys should be renewed right away.
+// A renew before duration not shorter than the certificate validity is ignored, see RenewerOptionRenewBefore.
+func (r *Renewer) NextRenewal() time.Time {
+	certificate, err := r.Certificate()
+	if err != nil || certificate == nil {
+		return r.o.now()
+	}
+
+	if certificate.ValidBefore > math.MaxInt64 {
+		return time.Unix(math.MaxInt64, 0)
+	}
+
+	validBefore := time.Unix(int64(certificate.ValidBefore), 0)
+
+	if r.o.renewBefore > 0 && r.checkRenewBefore(certificate) == nil {
+		return validBefore.Add(-r.o.renewBefore)
+	}
+
+	validity, known := certificateValidity(certificate)
+	if !known {
+		return validBefore
+	}
+
+	return validBefore.Add(-time.Duration(float64(validity) * r.o.renewBeforeRatio))
+}
+
+// checkRenewBefore ensures the renew before duration is shorter than the certificate validity,
+// otherwise the certificate would need to be renewed as soon as it is signed.
+func (r *Renewer) checkRenewBefore(certificate *ssh.Certificate) error {
+	validity, known := certificateValidity(certificate)
+	if r.o.renewBefore > 0 && known && r.o.renewBefore >= validity {
+		return fmt.Errorf("renew before %s is not shorter than the certificate validity %s, it is ignored", r.o.renewBefore, validity)
+	}
+	return nil
+}
+
+func certificateValidity(certificate *ssh.Certificate) (time.Duration, bool) {
+	if certificate.ValidAfter == 0 || certificate.ValidAfter >= certificate.ValidBefore || certificate.ValidBefore > math.MaxInt64 {
+		return 0, false
+	}
+	return time.Unix(int64(certificate.ValidBefore), 0).Sub(time.Unix(int64(certificate.ValidAfter), 0)), true
+}
+
+// Renew signs the user key and writes the certificate, regardless of the current certificate validity.
+func (r *Renewer) Renew(ctx context.Context) (*ssh.Certificate, error) {
+	certificate, err := r.key.Sign(ctx, r.o.signOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := atomicfile.WriteFile(r.certificatePath, ssh.MarshalAuthorizedKey(certificate), 0o600); err != nil {
+		return nil, fmt.Errorf("unable to write certificate: %v", err)
+	}
+
+	r.o.onEvent(RenewerEvent{Type: RenewerEventRenewed, At: r.o.now(), Certificate: certificate})
+
+	return certificate, nil
+}
+
+// Run renews the certificate each time it is needed, retrying failed renewals, until the context is done.
+// Successful renewals are spaced by at least the minimum interval, even if the server signs certificates
+// already needing a renewal. It always returns the context error.
+func (r *Renewer) Run(ctx context.Context) error {
+	var earliestRenewal time.Time
+
+	for {
+		nextRenewal := r.nextRenewalAfter(earliestRenewal)
+		r.o.onEvent(RenewerEvent{Type: RenewerEventScheduled, At: nextRenewal})
+
+		if err := r.sleep(ctx, nextRenewal.Sub(r.o.now())); err != nil {
+			return err
+		}
+
+		for failures := 0; ; failures++ {
+			certificate, err := r.Renew(ctx)
+			if err == nil {
+				earliestRenewal = r.o.now().Add(r.o.minInterval)
+				if err := r.checkRenewBefore(certificate); err != nil {
+					r.o.onEvent(RenewerEvent{Type: RenewerEventFailed, At: r.nextRenewalAfter(earliestRenewal), Err: err})
+				}
+				break
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			delay := r.backoff(failures)
+			r.o.onEvent(RenewerEvent{Type: RenewerEventFailed, At: r.o.now().Add(delay), Err: err})
+
+			if err := r.sleep(ctx, delay); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *Renewer) nextRenewalAfter(earliest time.Time) time.Time {
+	if nextRenewal := r.NextRenewal(); nextRenewal.After(earliest) {
+		return nextRenewal
+	}
+	return earliest
+}
+
+func (r *Renewer) sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil || d <= 0 {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.o.after(d):
+		return nil
+	}
+}
+
+func (r *Renewer) backoff(failures int) time.Duration {
+	delay := r.o.minBackoff
+	for i := 0; i < failures && delay < r.o.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > r.o.maxBackoff {
+		delay = r.o.maxBackoff
+	}
+
+	if delay < 2 {
+		return delay
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2))) //nolint:gosec // jitter does not need to be cryptographically secure
+}
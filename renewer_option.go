@@ -0,0 +1,72 @@
+package cassh
+
+import (
+	"time"
+)
+
+// RenewerOption defines the signature of all options usable on SessionUserKey.Renewer.
+type RenewerOption func(o *renewerOptions)
+
+type renewerOptions struct {
+	renewBefore      time.Duration
+	renewBeforeRatio float64
+	minInterval      time.Duration
+	minBackoff       time.Duration
+	maxBackoff       time.Duration
+	signOptions      []SessionUserKeySignOption
+	onEvent          func(RenewerEvent)
+
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+func renewerOptionsDefaults() *renewerOptions {
+	return &renewerOptions{
+		renewBeforeRatio: 0.2,
+		minInterval:      time.Minute,
+		minBackoff:       10 * time.Second,
+		maxBackoff:       5 * time.Minute,
+		onEvent:          func(RenewerEvent) {},
+		now:              time.Now,
+		after:            time.After,
+	}
+}
+
+// RenewerOptionRenewBefore sets how long before the certificate expiration it gets renewed.
+// By default, certificates are renewed once 80% of their validity has elapsed, which is also used
+// for certificates whose validity is not longer than the provided duration.
+func RenewerOptionRenewBefore(renewBefore time.Duration) RenewerOption {
+	return func(o *renewerOptions) {
+		o.renewBefore = renewBefore
+	}
+}
+
+// RenewerOptionMinInterval sets the minimum delay between two successful renewals, one minute by default.
+func RenewerOptionMinInterval(minInterval time.Duration) RenewerOption {
+	return func(o *renewerOptions) {
+		o.minInterval = minInterval
+	}
+}
+
+// RenewerOptionBackoff sets the bounds of the delay between two failed renewal attempts.
+// The delay doubles after each failure, and is randomly reduced by up to half to avoid synchronized retries.
+func RenewerOptionBackoff(minBackoff, maxBackoff time.Duration) RenewerOption {
+	return func(o *renewerOptions) {
+		o.minBackoff = minBackoff
+		o.maxBackoff = maxBackoff
+	}
+}
+
+// RenewerOptionSignOptions sets the options used on each signature request.
+func RenewerOptionSignOptions(opts ...SessionUserKeySignOption) RenewerOption {
+	return func(o *renewerOptions) {
+		o.signOptions = opts
+	}
+}
+
+// RenewerOptionOnEvent sets a callback called synchronously on each renewer event.
+func RenewerOptionOnEvent(onEvent func(RenewerEvent)) RenewerOption {
+	return func(o *renewerOptions) {
+		o.onEvent = onEvent
+	}
+}
@@ -0,0 +1,53 @@
+package cassh
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_renewerOptionsDefaults(t *testing.T) {
+	opts := renewerOptionsDefaults()
+	assert.Check(t, cmp.Equal(opts.renewBefore, time.Duration(0)))
+	assert.Check(t, cmp.Equal(opts.renewBeforeRatio, 0.2))
+	assert.Check(t, cmp.Equal(opts.minInterval, time.Minute))
+	assert.Check(t, opts.minBackoff > 0 && opts.minBackoff < opts.maxBackoff)
+	assert.Check(t, opts.onEvent != nil)
+	assert.Check(t, opts.now != nil)
+	assert.Check(t, opts.after != nil)
+}
+
+func Test_RenewerOptionRenewBefore(t *testing.T) {
+	opts := renewerOptionsDefaults()
+	RenewerOptionRenewBefore(time.Hour)(opts)
+	assert.Check(t, cmp.Equal(opts.renewBefore, time.Hour))
+}
+
+func Test_RenewerOptionMinInterval(t *testing.T) {
+	opts := renewerOptionsDefaults()
+	RenewerOptionMinInterval(time.Hour)(opts)
+	assert.Check(t, cmp.Equal(opts.minInterval, time.Hour))
+}
+
+func Test_RenewerOptionBackoff(t *testing.T) {
+	opts := renewerOptionsDefaults()
+	RenewerOptionBackoff(time.Second, time.Minute)(opts)
+	assert.Check(t, cmp.Equal(opts.minBackoff, time.Second))
+	assert.Check(t, cmp.Equal(opts.maxBackoff, time.Minute))
+}
+
+func Test_RenewerOptionSignOptions(t *testing.T) {
+	opts := renewerOptionsDefaults()
+	RenewerOptionSignOptions(SessionUserKeySignOptionForce())(opts)
+	assert.Check(t, cmp.Len(opts.signOptions, 1))
+}
+
+func Test_RenewerOptionOnEvent(t *testing.T) {
+	var called bool
+	opts := renewerOptionsDefaults()
+	RenewerOptionOnEvent(func(RenewerEvent) { called = true })(opts)
+	opts.onEvent(RenewerEvent{})
+	assert.Check(t, called)
+}
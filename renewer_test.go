@@ -0,0 +1,275 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type renewerTestSigner struct {
+	t         *testing.T
+	authority ssh.Signer
+	key       ssh.PublicKey
+	validity  time.Duration
+
+	m        sync.Mutex
+	failures int
+	signed   int
+}
+
+func newRenewerTestSigner(t *testing.T, validity time.Duration) *renewerTestSigner {
+	_, authorityKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	authority, err := ssh.NewSignerFromKey(authorityKey)
+	assert.NilError(t, err)
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	key, err := ssh.NewPublicKey(publicKey)
+	assert.NilError(t, err)
+
+	return &renewerTestSigner{t: t, authority: authority, key: key, validity: validity}
+}
+
+func (s *renewerTestSigner) certificate(validAfter time.Time, key ssh.PublicKey) *ssh.Certificate {
+	certificate := &ssh.Certificate{
+		Key:         key,
+		KeyId:       "john",
+		CertType:    ssh.UserCert,
+		ValidAfter:  uint64(validAfter.Unix()),
+		ValidBefore: uint64(validAfter.Add(s.validity).Unix()),
+	}
+	assert.NilError(s.t, certificate.SignCert(rand.Reader, s.authority))
+	return certificate
+}
+
+func (s *renewerTestSigner) Do(req *http.Request) (*http.Response, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if req.URL.Path == "/ca" {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Body:          io.NopCloser(bytes.NewReader(ssh.MarshalAuthorizedKey(s.authority.PublicKey()))),
+			ContentLength: -1,
+			Request:       req,
+		}, nil
+	}
+
+	if s.failures > 0 {
+		s.failures--
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody, Request: req}, nil
+	}
+
+	s.signed++
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Body:          io.NopCloser(bytes.NewReader(ssh.MarshalAuthorizedKey(s.certificate(time.Now(), s.key)))),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func newTestRenewer(t *testing.T, signer *renewerTestSigner, opts ...RenewerOption) (*Renewer, string) {
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(signer))
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "id_ed25519-cert.pub")
+	return client.SessionUser("john").Key(signer.key).Renewer(path, opts...), path
+}
+
+func Test_Renewer_NextRenewal(t *testing.T) {
+	signer := newRenewerTestSigner(t, 10*time.Hour)
+	now := time.Now().Truncate(time.Second)
+
+	renewer, path := newTestRenewer(t, signer)
+	renewer.o.now = func() time.Time { return now }
+
+	assert.Check(t, renewer.NextRenewal().Equal(now), "missing certificate must be renewed now")
+
+	assert.NilError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+	assert.Check(t, renewer.NextRenewal().Equal(now), "unparsable certificate must be renewed now")
+
+	assert.NilError(t, os.WriteFile(path, ssh.MarshalAuthorizedKey(signer.certificate(now, signer.authority.PublicKey())), 0o600))
+	assert.Check(t, renewer.NextRenewal().Equal(now), "certificate of another key must be renewed now")
+
+	assert.NilError(t, os.WriteFile(path, ssh.MarshalAuthorizedKey(signer.certificate(now, signer.key)), 0o600))
+	assert.Check(t, cmp.Equal(renewer.NextRenewal(), now.Add(8*time.Hour)))
+
+	RenewerOptionRenewBefore(time.Hour)(renewer.o)
+	assert.Check(t, cmp.Equal(renewer.NextRenewal(), now.Add(9*time.Hour)))
+
+	RenewerOptionRenewBefore(10 * time.Hour)(renewer.o)
+	assert.Check(t, cmp.Equal(renewer.NextRenewal(), now.Add(8*time.Hour)), "renew before not shorter than the validity must be ignored")
+}
+
+func Test_Renewer_Renew(t *testing.T) {
+	signer := newRenewerTestSigner(t, time.Hour)
+
+	var events []RenewerEvent
+	renewer, path := newTestRenewer(t, signer, RenewerOptionOnEvent(func(event RenewerEvent) { events = append(events, event) }))
+
+	certificate, err := renewer.Renew(context.Background())
+	assert.NilError(t, err)
+
+	written, err := renewer.Certificate()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(written.Marshal(), certificate.Marshal()))
+
+	info, err := os.Stat(path)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(info.Mode().Perm(), os.FileMode(0o600)))
+
+	assert.Assert(t, cmp.Len(events, 1))
+	assert.Check(t, cmp.Equal(events[0].Type, RenewerEventRenewed))
+	assert.Check(t, events[0].Certificate == certificate)
+
+	signer.failures = 1
+	_, err = renewer.Renew(context.Background())
+	assert.Check(t, cmp.ErrorIs(err, ErrServerFailure))
+	assert.Check(t, cmp.Len(events, 1))
+}
+
+func Test_Renewer_Run(t *testing.T) {
+	signer := newRenewerTestSigner(t, time.Hour)
+	signer.failures = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		events []RenewerEvent
+		sleeps []time.Duration
+	)
+
+	renewer, _ := newTestRenewer(t, signer,
+		RenewerOptionBackoff(time.Second, time.Minute),
+		RenewerOptionOnEvent(func(event RenewerEvent) {
+			events = append(events, event)
+			if len(events) == 6 {
+				cancel()
+			}
+		}),
+	)
+	renewer.o.after = func(d time.Duration) <-chan time.Time {
+		sleeps = append(sleeps, d)
+		c := make(chan time.Time, 1)
+		c <- time.Now()
+		return c
+	}
+
+	assert.Check(t, cmp.ErrorIs(renewer.Run(ctx), context.Canceled))
+
+	types := make([]RenewerEventType, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+
+	assert.Check(t, cmp.DeepEqual(types, []RenewerEventType{
+		RenewerEventScheduled,
+		RenewerEventFailed, RenewerEventFailed, RenewerEventRenewed,
+		RenewerEventScheduled,
+		RenewerEventRenewed,
+		RenewerEventScheduled,
+	}))
+	assert.Check(t, cmp.ErrorIs(events[1].Err, ErrServerFailure))
+	assert.Check(t, cmp.Equal(signer.signed, 2))
+
+	assert.Assert(t, cmp.Len(sleeps, 3))
+	assert.Check(t, sleeps[0] >= 500*time.Millisecond && sleeps[0] < time.Second, sleeps[0])
+	assert.Check(t, sleeps[1] >= time.Second && sleeps[1] < 2*time.Second, sleeps[1])
+	assert.Check(t, sleeps[2] > 40*time.Minute && sleeps[2] <= 48*time.Minute, sleeps[2])
+	assert.Check(t, events[6].At.After(time.Now().Add(40*time.Minute)))
+}
+
+func Test_Renewer_backoff(t *testing.T) {
+	renewer := (&SessionUserKey{}).Renewer("", RenewerOptionBackoff(time.Second, 10*time.Second))
+
+	for failures, bounds := range map[int][2]time.Duration{
+		0:   {500 * time.Millisecond, time.Second},
+		1:   {time.Second, 2 * time.Second},
+		3:   {4 * time.Second, 8 * time.Second},
+		4:   {5 * time.Second, 10 * time.Second},
+		100: {5 * time.Second, 10 * time.Second},
+	} {
+		delay := renewer.backoff(failures)
+		assert.Check(t, delay >= bounds[0] && delay < bounds[1], "%d failures: %s", failures, delay)
+	}
+}
+
+func Test_Renewer_Run_minInterval(t *testing.T) {
+	for name, test := range map[string]struct {
+		validity    time.Duration
+		renewBefore time.Duration
+		expectedErr string
+	}{
+		"short-lived certificates": {
+			validity: 30 * time.Second,
+		},
+		"renew before not shorter than the validity": {
+			validity:    30 * time.Second,
+			renewBefore: time.Hour,
+			expectedErr: "renew before 1h0m0s is not shorter than the certificate validity 30s, it is ignored",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			signer := newRenewerTestSigner(t, test.validity)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var (
+				events []RenewerEvent
+				sleeps []time.Duration
+			)
+
+			renewer, _ := newTestRenewer(t, signer,
+				RenewerOptionRenewBefore(test.renewBefore),
+				RenewerOptionOnEvent(func(event RenewerEvent) { events = append(events, event) }),
+			)
+			renewer.o.after = func(d time.Duration) <-chan time.Time {
+				sleeps = append(sleeps, d)
+				c := make(chan time.Time, 1)
+				if len(sleeps) == 2 {
+					cancel()
+					return c
+				}
+				c <- time.Now()
+				return c
+			}
+
+			assert.Check(t, cmp.ErrorIs(renewer.Run(ctx), context.Canceled))
+			assert.Check(t, cmp.Equal(signer.signed, 2))
+
+			assert.Assert(t, cmp.Len(sleeps, 2))
+			for _, sleep := range sleeps {
+				assert.Check(t, sleep > 50*time.Second && sleep <= time.Minute, sleep)
+			}
+
+			var failures []error
+			for _, event := range events {
+				if event.Type == RenewerEventFailed {
+					failures = append(failures, event.Err)
+				}
+			}
+			if test.expectedErr == "" {
+				assert.Check(t, cmp.Len(failures, 0))
+				return
+			}
+			assert.Assert(t, cmp.Len(failures, 2))
+			assert.Check(t, cmp.Error(failures[0], test.expectedErr))
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package cassh
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/krostar/httpclient"
+)
+
+// retryDoer wraps a doer to retry requests of idempotent operations
+// failing because of the network, the server being unavailable, or rate limiting.
+type retryDoer struct {
+	doer httpclient.Doer
+	o    *retryPolicyOptions
+}
+
+func (d *retryDoer) Do(req *http.Request) (*http.Response, error) {
+	if !d.retryable(req) {
+		return d.doer.Do(req)
+	}
+
+	ctx := req.Context()
+
+	var deadline time.Time
+	if d.o.maxElapsedTime > 0 {
+		deadline = d.o.now().Add(d.o.maxElapsedTime)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := d.doer.Do(attemptReq)
+		if attempt >= d.o.maxAttempts || ctx.Err() != nil || !retryableResponse(resp, err) {
+			return resp, err
+		}
+
+		delay := d.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(resp, d.o.now()); ok {
+			delay = retryAfter
+		}
+
+		if !deadline.IsZero() && d.o.now().Add(delay).After(deadline) {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-d.o.after(delay):
+		}
+	}
+}
+
+// retryable returns whenever the request can be made several times.
+// Requests with a body that cannot be read again are never retried.
+func (d *retryDoer) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	op, ok := operationFromContext(req.Context())
+	if !ok {
+		return false
+	}
+
+	return op.idempotent() || (op == operationUserKeySign && d.o.retrySign)
+}
+
+// retryableResponse returns whenever the request failed because of the network,
+// the server being unavailable, or the request being rate limited.
+func retryableResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
+}
+
+// backoff returns the delay to wait after the provided attempt failed.
+func (d *retryDoer) backoff(attempt int) time.Duration {
+	delay := d.o.minBackoff
+	for i := 1; i < attempt && delay < d.o.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > d.o.maxBackoff {
+		delay = d.o.maxBackoff
+	}
+
+	return delay - time.Duration(float64(delay)*d.o.jitter*d.o.random())
+}
+
+// parseRetryAfter returns the delay requested by the server through the Retry-After header, if any.
+func parseRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
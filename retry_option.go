@@ -0,0 +1,75 @@
+package cassh
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicyOption defines the signature of all options usable on ClientOptionRetryPolicy.
+type RetryPolicyOption func(o *retryPolicyOptions)
+
+type retryPolicyOptions struct {
+	maxAttempts    int
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	jitter         float64
+	maxElapsedTime time.Duration
+	retrySign      bool
+
+	now    func() time.Time
+	after  func(time.Duration) <-chan time.Time
+	random func() float64
+}
+
+func retryPolicyOptionsDefaults() *retryPolicyOptions {
+	return &retryPolicyOptions{
+		maxAttempts: 4,
+		minBackoff:  200 * time.Millisecond,
+		maxBackoff:  5 * time.Second,
+		jitter:      0.5,
+		now:         time.Now,
+		after:       time.After,
+		random:      rand.Float64, //nolint:gosec // jitter does not need to be cryptographically secure
+	}
+}
+
+// RetryPolicyOptionMaxAttempts sets the maximum number of attempts of a request, including the first one. It defaults to 4.
+func RetryPolicyOptionMaxAttempts(maxAttempts int) RetryPolicyOption {
+	return func(o *retryPolicyOptions) {
+		o.maxAttempts = maxAttempts
+	}
+}
+
+// RetryPolicyOptionBackoff sets the bounds of the delay between two attempts. The delay doubles after each attempt.
+// It defaults to 200ms and 5s.
+func RetryPolicyOptionBackoff(minBackoff, maxBackoff time.Duration) RetryPolicyOption {
+	return func(o *retryPolicyOptions) {
+		o.minBackoff = minBackoff
+		o.maxBackoff = maxBackoff
+	}
+}
+
+// RetryPolicyOptionJitter sets the ratio, between 0 and 1, by which the delay between two attempts is randomly reduced,
+// to avoid synchronized retries of several clients. It defaults to 0.5.
+func RetryPolicyOptionJitter(jitter float64) RetryPolicyOption {
+	return func(o *retryPolicyOptions) {
+		o.jitter = jitter
+	}
+}
+
+// RetryPolicyOptionMaxElapsedTime bounds the time spent on a request, retries included.
+// No attempt is made if it would start after this duration, or after the context deadline.
+// By default, only the context deadline bounds retries.
+func RetryPolicyOptionMaxElapsedTime(maxElapsedTime time.Duration) RetryPolicyOption {
+	return func(o *retryPolicyOptions) {
+		o.maxElapsedTime = maxElapsedTime
+	}
+}
+
+// RetryPolicyOptionRetrySign also retries SessionUserKey.Sign requests.
+// As signing a key is not idempotent, a retried request may make the server sign the key several times.
+func RetryPolicyOptionRetrySign() RetryPolicyOption {
+	return func(o *retryPolicyOptions) {
+		o.retrySign = true
+	}
+}
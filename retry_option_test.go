@@ -0,0 +1,53 @@
+package cassh
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_retryPolicyOptionsDefaults(t *testing.T) {
+	opts := retryPolicyOptionsDefaults()
+	assert.Check(t, cmp.Equal(opts.maxAttempts, 4))
+	assert.Check(t, cmp.Equal(opts.minBackoff, 200*time.Millisecond))
+	assert.Check(t, cmp.Equal(opts.maxBackoff, 5*time.Second))
+	assert.Check(t, cmp.Equal(opts.jitter, 0.5))
+	assert.Check(t, cmp.Equal(opts.maxElapsedTime, time.Duration(0)))
+	assert.Check(t, !opts.retrySign)
+	assert.Check(t, opts.now != nil)
+	assert.Check(t, opts.after != nil)
+	assert.Check(t, opts.random != nil)
+}
+
+func Test_RetryPolicyOptionMaxAttempts(t *testing.T) {
+	opts := retryPolicyOptionsDefaults()
+	RetryPolicyOptionMaxAttempts(10)(opts)
+	assert.Check(t, cmp.Equal(opts.maxAttempts, 10))
+}
+
+func Test_RetryPolicyOptionBackoff(t *testing.T) {
+	opts := retryPolicyOptionsDefaults()
+	RetryPolicyOptionBackoff(time.Second, time.Minute)(opts)
+	assert.Check(t, cmp.Equal(opts.minBackoff, time.Second))
+	assert.Check(t, cmp.Equal(opts.maxBackoff, time.Minute))
+}
+
+func Test_RetryPolicyOptionJitter(t *testing.T) {
+	opts := retryPolicyOptionsDefaults()
+	RetryPolicyOptionJitter(0.1)(opts)
+	assert.Check(t, cmp.Equal(opts.jitter, 0.1))
+}
+
+func Test_RetryPolicyOptionMaxElapsedTime(t *testing.T) {
+	opts := retryPolicyOptionsDefaults()
+	RetryPolicyOptionMaxElapsedTime(time.Minute)(opts)
+	assert.Check(t, cmp.Equal(opts.maxElapsedTime, time.Minute))
+}
+
+func Test_RetryPolicyOptionRetrySign(t *testing.T) {
+	opts := retryPolicyOptionsDefaults()
+	RetryPolicyOptionRetrySign()(opts)
+	assert.Check(t, opts.retrySign)
+}
@@ -0,0 +1,242 @@
+package cassh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type retryTestResponse struct {
+	status     int
+	retryAfter string
+	err        error
+}
+
+type retryTestDoer struct {
+	m         sync.Mutex
+	responses []retryTestResponse
+	bodies    []string
+}
+
+func (d *retryTestDoer) Do(req *http.Request) (*http.Response, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	var body string
+	if req.Body != nil {
+		raw, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(raw)
+	}
+	d.bodies = append(d.bodies, body)
+
+	response := retryTestResponse{status: http.StatusOK}
+	if len(d.responses) > 0 {
+		response, d.responses = d.responses[0], d.responses[1:]
+	}
+
+	if response.err != nil {
+		return nil, response.err
+	}
+
+	resp := &http.Response{
+		StatusCode:    response.status,
+		ContentLength: -1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(`{"name":"cassh","version":"1.0.0"}`)),
+		Request:       req,
+	}
+	if response.retryAfter != "" {
+		resp.Header.Set("Retry-After", response.retryAfter)
+	}
+
+	return resp, nil
+}
+
+func newRetryTestClient(t *testing.T, doer *retryTestDoer, opts ...RetryPolicyOption) (*Client, *[]time.Duration) {
+	var sleeps []time.Duration
+
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	client, err := NewClient("https://cassh.local",
+		ClientOptionHTTPClient(doer),
+		ClientOptionRetryPolicy(append([]RetryPolicyOption{func(o *retryPolicyOptions) {
+			o.now = func() time.Time { return now }
+			o.random = func() float64 { return 0 }
+			o.after = func(d time.Duration) <-chan time.Time {
+				sleeps = append(sleeps, d)
+				now = now.Add(d)
+				c := make(chan time.Time, 1)
+				c <- now
+				return c
+			}
+		}}, opts...)...),
+	)
+	assert.NilError(t, err)
+
+	return client, &sleeps
+}
+
+func Test_retryDoer_idempotent(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retried until success", func(t *testing.T) {
+		doer := &retryTestDoer{responses: []retryTestResponse{
+			{status: http.StatusBadGateway},
+			{err: errors.New("connection reset by peer")},
+			{status: http.StatusTooManyRequests},
+		}}
+		client, sleeps := newRetryTestClient(t, doer)
+
+		_, _, err := client.Health(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Len(doer.bodies, 4))
+		assert.Check(t, cmp.DeepEqual(*sleeps, []time.Duration{200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond}))
+	})
+
+	t.Run("body sent again", func(t *testing.T) {
+		doer := &retryTestDoer{responses: []retryTestResponse{{status: http.StatusServiceUnavailable}}}
+		client, _ := newRetryTestClient(t, doer)
+
+		_, _ = client.SessionUser("john").Status(ctx)
+		assert.Assert(t, cmp.Len(doer.bodies, 2))
+		assert.Check(t, cmp.Equal(doer.bodies[0], "username=john"))
+		assert.Check(t, cmp.Equal(doer.bodies[1], doer.bodies[0]))
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		doer := &retryTestDoer{responses: []retryTestResponse{
+			{status: http.StatusBadGateway}, {status: http.StatusBadGateway}, {status: http.StatusBadGateway},
+		}}
+		client, sleeps := newRetryTestClient(t, doer, RetryPolicyOptionMaxAttempts(3))
+
+		err := client.Ping(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrServerFailure))
+		assert.Check(t, cmp.Len(doer.bodies, 3))
+		assert.Check(t, cmp.Len(*sleeps, 2))
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		for _, status := range []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotImplemented} {
+			doer := &retryTestDoer{responses: []retryTestResponse{{status: status}}}
+			client, _ := newRetryTestClient(t, doer)
+
+			assert.Check(t, client.Ping(ctx) != nil)
+			assert.Check(t, cmp.Len(doer.bodies, 1), "status %d", status)
+		}
+	})
+}
+
+func Test_retryDoer_sign(t *testing.T) {
+	ctx := context.Background()
+	key := newVerificationTestSigner(t).PublicKey()
+
+	doer := &retryTestDoer{responses: []retryTestResponse{{status: http.StatusBadGateway}, {status: http.StatusBadGateway}}}
+	client, _ := newRetryTestClient(t, doer)
+
+	_, err := client.SessionUser("john").Key(key).Sign(ctx, SessionUserKeySignOptionSkipVerification())
+	assert.Check(t, cmp.ErrorIs(err, ErrServerFailure))
+	assert.Check(t, cmp.Len(doer.bodies, 1), "sign must not be retried by default")
+
+	doer = &retryTestDoer{responses: []retryTestResponse{{status: http.StatusBadGateway}, {status: http.StatusBadRequest}}}
+	client, _ = newRetryTestClient(t, doer, RetryPolicyOptionRetrySign())
+
+	_, err = client.SessionUser("john").Key(key).Sign(ctx, SessionUserKeySignOptionSkipVerification())
+	assert.Check(t, cmp.ErrorIs(err, ErrBadRequest))
+	assert.Check(t, cmp.Len(doer.bodies, 2))
+}
+
+func Test_retryDoer_retryAfter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("seconds", func(t *testing.T) {
+		doer := &retryTestDoer{responses: []retryTestResponse{{status: http.StatusTooManyRequests, retryAfter: "3"}}}
+		client, sleeps := newRetryTestClient(t, doer)
+
+		assert.NilError(t, client.Ping(ctx))
+		assert.Check(t, cmp.DeepEqual(*sleeps, []time.Duration{3 * time.Second}))
+	})
+
+	t.Run("date", func(t *testing.T) {
+		doer := &retryTestDoer{responses: []retryTestResponse{{status: http.StatusServiceUnavailable, retryAfter: "Mon, 02 Jan 2006 15:04:15 GMT"}}}
+		client, sleeps := newRetryTestClient(t, doer)
+
+		assert.NilError(t, client.Ping(ctx))
+		assert.Check(t, cmp.DeepEqual(*sleeps, []time.Duration{10 * time.Second}))
+	})
+
+	t.Run("beyond max elapsed time", func(t *testing.T) {
+		doer := &retryTestDoer{responses: []retryTestResponse{{status: http.StatusServiceUnavailable, retryAfter: "120"}}}
+		client, sleeps := newRetryTestClient(t, doer, RetryPolicyOptionMaxElapsedTime(time.Minute))
+
+		assert.Check(t, cmp.ErrorIs(client.Ping(ctx), ErrServerFailure))
+		assert.Check(t, cmp.Len(doer.bodies, 1))
+		assert.Check(t, cmp.Len(*sleeps, 0))
+	})
+}
+
+func Test_retryDoer_context(t *testing.T) {
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+
+		var sleeps []time.Duration
+		doer := &retryTestDoer{responses: []retryTestResponse{{status: http.StatusBadGateway, retryAfter: "7200"}}}
+		client, err := NewClient("https://cassh.local",
+			ClientOptionHTTPClient(doer),
+			ClientOptionRetryPolicy(func(o *retryPolicyOptions) {
+				o.after = func(d time.Duration) <-chan time.Time {
+					sleeps = append(sleeps, d)
+					return time.After(0)
+				}
+			}),
+		)
+		assert.NilError(t, err)
+
+		assert.Check(t, cmp.ErrorIs(client.Ping(ctx), ErrServerFailure))
+		assert.Check(t, cmp.Len(doer.bodies, 1))
+		assert.Check(t, cmp.Len(sleeps, 0))
+	})
+
+	t.Run("canceled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		doer := &retryTestDoer{responses: []retryTestResponse{{status: http.StatusBadGateway}}}
+		client, err := NewClient("https://cassh.local",
+			ClientOptionHTTPClient(doer),
+			ClientOptionRetryPolicy(func(o *retryPolicyOptions) {
+				o.after = func(time.Duration) <-chan time.Time {
+					cancel()
+					return make(chan time.Time)
+				}
+			}),
+		)
+		assert.NilError(t, err)
+
+		assert.Check(t, cmp.ErrorIs(client.Ping(ctx), context.Canceled))
+		assert.Check(t, cmp.Len(doer.bodies, 1))
+	})
+}
+
+func Test_retryDoer_backoff(t *testing.T) {
+	o := retryPolicyOptionsDefaults()
+	o.random = func() float64 { return 1 }
+	doer := &retryDoer{o: o}
+
+	assert.Check(t, cmp.Equal(doer.backoff(1), 100*time.Millisecond))
+	assert.Check(t, cmp.Equal(doer.backoff(2), 200*time.Millisecond))
+	assert.Check(t, cmp.Equal(doer.backoff(10), 2500*time.Millisecond))
+
+	o.jitter = 0
+	assert.Check(t, cmp.Equal(doer.backoff(10), 5*time.Second))
+}
@@ -0,0 +1,215 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec // sha1 fingerprints are part of the key revocation list format
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+)
+
+// RevocationReason describes which kind of key revocation list entry revokes a key.
+type RevocationReason string
+
+const (
+	// RevocationReasonExplicitKey is used when the key is explicitly listed.
+	RevocationReasonExplicitKey RevocationReason = "explicit key"
+	// RevocationReasonFingerprintSHA1 is used when the SHA1 hash of the key is listed.
+	RevocationReasonFingerprintSHA1 RevocationReason = "sha1 fingerprint"
+	// RevocationReasonFingerprintSHA256 is used when the SHA256 hash of the key is listed.
+	RevocationReasonFingerprintSHA256 RevocationReason = "sha256 fingerprint"
+	// RevocationReasonCertificateSerial is used when the certificate serial is listed, or part of a listed range or bitmap.
+	RevocationReasonCertificateSerial RevocationReason = "certificate serial"
+	// RevocationReasonCertificateKeyID is used when the certificate key id is listed.
+	RevocationReasonCertificateKeyID RevocationReason = "certificate key id"
+	// RevocationReasonCertificateAuthority is used when the key that signed the certificate is revoked.
+	RevocationReasonCertificateAuthority RevocationReason = "certificate authority"
+)
+
+// Revocation explains which entry of a key revocation list revokes a key.
+type Revocation struct {
+	// Reason is the kind of entry that matched.
+	Reason RevocationReason
+	// ListVersion is the version of the key revocation list.
+	ListVersion uint64
+	// Key is the revoked key; it is the key of the certificate, or its authority, when a certificate is checked.
+	Key ssh.PublicKey
+	// Authority is the authority certificate entries apply to, nil if they apply to any authority
+	// or if the reason is not related to certificates.
+	Authority ssh.PublicKey
+	// Serial is the revoked serial, set when Reason is RevocationReasonCertificateSerial.
+	Serial uint64
+	// KeyID is the revoked key id, set when Reason is RevocationReasonCertificateKeyID.
+	KeyID string
+}
+
+// String implements fmt.Stringer.
+func (r Revocation) String() string {
+	var what string
+
+	switch r.Reason {
+	case RevocationReasonCertificateSerial:
+		what = fmt.Sprintf("certificate serial %d", r.Serial)
+	case RevocationReasonCertificateKeyID:
+		what = fmt.Sprintf("certificate key id %q", r.KeyID)
+	case RevocationReasonCertificateAuthority:
+		what = "certificate authority " + ssh.FingerprintSHA256(r.Key)
+	default:
+		what = "key " + ssh.FingerprintSHA256(r.Key)
+	}
+
+	if r.Authority != nil {
+		what += " signed by " + ssh.FingerprintSHA256(r.Authority)
+	}
+
+	return fmt.Sprintf("%s revoked by %s entry of key revocation list version %d", what, r.Reason, r.ListVersion)
+}
+
+// CheckRevocation returns the entry of the key revocation list that revokes the provided key or certificate,
+// or nil if it is not revoked. Like OpenSSH, certificates are also revoked when their key, or their authority, is.
+func CheckRevocation(list *krl.KRL, key ssh.PublicKey) *Revocation {
+	certificate, isCertificate := key.(*ssh.Certificate)
+	if isCertificate {
+		key = certificate.Key
+	}
+
+	var revocation *Revocation
+
+	for _, section := range list.Sections {
+		switch section := section.(type) {
+		case *krl.KRLCertificateSection:
+			if isCertificate {
+				revocation = checkCertificateRevocation(section, certificate)
+			}
+		default:
+			if reason, revoked := checkKeyRevocation(section, key); revoked {
+				revocation = &Revocation{Reason: reason, Key: key}
+			} else if isCertificate && checkKeyRevoked(section, certificate.SignatureKey) {
+				revocation = &Revocation{Reason: RevocationReasonCertificateAuthority, Key: certificate.SignatureKey}
+			}
+		}
+
+		if revocation != nil {
+			revocation.ListVersion = list.Version
+			return revocation
+		}
+	}
+
+	return nil
+}
+
+func checkKeyRevocation(section krl.KRLSection, key ssh.PublicKey) (RevocationReason, bool) {
+	raw := key.Marshal()
+
+	switch section := section.(type) {
+	case *krl.KRLExplicitKeySection:
+		for _, revoked := range *section {
+			if certificate, ok := revoked.(*ssh.Certificate); ok {
+				revoked = certificate.Key
+			}
+			if bytes.Equal(raw, revoked.Marshal()) {
+				return RevocationReasonExplicitKey, true
+			}
+		}
+	case *krl.KRLFingerprintSection:
+		fingerprint := sha1.Sum(raw) //nolint:gosec // sha1 fingerprints are part of the key revocation list format
+		for _, revoked := range *section {
+			if revoked == fingerprint {
+				return RevocationReasonFingerprintSHA1, true
+			}
+		}
+	case *krl.KRLFingerprintSHA256Section:
+		fingerprint := sha256.Sum256(raw)
+		for _, revoked := range *section {
+			if revoked == fingerprint {
+				return RevocationReasonFingerprintSHA256, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func checkKeyRevoked(section krl.KRLSection, key ssh.PublicKey) bool {
+	_, revoked := checkKeyRevocation(section, key)
+	return revoked
+}
+
+func checkCertificateRevocation(section *krl.KRLCertificateSection, certificate *ssh.Certificate) *Revocation {
+	if section.CA != nil && !bytes.Equal(section.CA.Marshal(), certificate.SignatureKey.Marshal()) {
+		return nil
+	}
+
+	serialRevocation := &Revocation{
+		Reason:    RevocationReasonCertificateSerial,
+		Key:       certificate.Key,
+		Authority: section.CA,
+		Serial:    certificate.Serial,
+	}
+
+	for _, subsection := range section.Sections {
+		switch subsection := subsection.(type) {
+		case *krl.KRLCertificateSerialList:
+			for _, serial := range *subsection {
+				if serial == certificate.Serial {
+					return serialRevocation
+				}
+			}
+		case *krl.KRLCertificateSerialRange:
+			if subsection.Min <= certificate.Serial && certificate.Serial <= subsection.Max {
+				return serialRevocation
+			}
+		case *krl.KRLCertificateSerialBitmap:
+			if certificate.Serial >= subsection.Offset && certificate.Serial-subsection.Offset < uint64(subsection.Bitmap.BitLen()) &&
+				subsection.Bitmap.Bit(int(certificate.Serial-subsection.Offset)) == 1 {
+				return serialRevocation
+			}
+		case *krl.KRLCertificateKeyID:
+			for _, keyID := range *subsection {
+				if keyID == certificate.KeyId {
+					return &Revocation{
+						Reason:    RevocationReasonCertificateKeyID,
+						Key:       certificate.Key,
+						Authority: section.CA,
+						KeyID:     keyID,
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Revocation fetches the key revocation list and returns the entry that revokes the provided key or certificate,
+// or nil if it is not revoked.
+func (c *Client) Revocation(ctx context.Context, key ssh.PublicKey) (*Revocation, error) {
+	list, err := c.KeyRevocationList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get key revocation list: %w", err)
+	}
+	return CheckRevocation(list, key), nil
+}
+
+// IsRevoked fetches the key revocation list and returns whenever the provided key or certificate is revoked.
+// Use Revocation to know why.
+func (c *Client) IsRevoked(ctx context.Context, key ssh.PublicKey) (bool, error) {
+	revocation, err := c.Revocation(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return revocation != nil, nil
+}
+
+// Revocation fetches the key revocation list and returns the entry that revokes the user key,
+// or nil if it is not revoked.
+func (s *SessionUserKey) Revocation(ctx context.Context) (*Revocation, error) {
+	list, err := s.keyRevocationList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get key revocation list: %w", err)
+	}
+	return CheckRevocation(list, s.key), nil
+}
@@ -0,0 +1,175 @@
+package cassh
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // sha1 fingerprints are part of the key revocation list format
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_CheckRevocation(t *testing.T) {
+	authority := newVerificationTestSigner(t).PublicKey()
+	otherAuthority := newVerificationTestSigner(t).PublicKey()
+	key := newVerificationTestSigner(t).PublicKey()
+
+	certificate := &ssh.Certificate{Key: key, Serial: 42, KeyId: "john", SignatureKey: authority}
+
+	for name, tc := range map[string]struct {
+		section  krl.KRLSection
+		key      ssh.PublicKey
+		expected *Revocation
+	}{
+		"explicit key": {
+			section:  &krl.KRLExplicitKeySection{key},
+			key:      key,
+			expected: &Revocation{Reason: RevocationReasonExplicitKey, Key: key},
+		},
+		"explicit key of certificate": {
+			section:  &krl.KRLExplicitKeySection{key},
+			key:      certificate,
+			expected: &Revocation{Reason: RevocationReasonExplicitKey, Key: key},
+		},
+		"explicit key of authority": {
+			section:  &krl.KRLExplicitKeySection{authority},
+			key:      certificate,
+			expected: &Revocation{Reason: RevocationReasonCertificateAuthority, Key: authority},
+		},
+		"sha1 fingerprint": {
+			section:  &krl.KRLFingerprintSection{sha1.Sum(key.Marshal())}, //nolint:gosec // see import
+			key:      key,
+			expected: &Revocation{Reason: RevocationReasonFingerprintSHA1, Key: key},
+		},
+		"sha256 fingerprint": {
+			section:  &krl.KRLFingerprintSHA256Section{sha256.Sum256(key.Marshal())},
+			key:      key,
+			expected: &Revocation{Reason: RevocationReasonFingerprintSHA256, Key: key},
+		},
+		"serial list": {
+			section: &krl.KRLCertificateSection{CA: authority, Sections: []krl.KRLCertificateSubsection{
+				&krl.KRLCertificateSerialList{1, 42},
+			}},
+			key:      certificate,
+			expected: &Revocation{Reason: RevocationReasonCertificateSerial, Key: key, Authority: authority, Serial: 42},
+		},
+		"serial range": {
+			section: &krl.KRLCertificateSection{Sections: []krl.KRLCertificateSubsection{
+				&krl.KRLCertificateSerialRange{Min: 40, Max: 50},
+			}},
+			key:      certificate,
+			expected: &Revocation{Reason: RevocationReasonCertificateSerial, Key: key, Serial: 42},
+		},
+		"serial bitmap": {
+			section: &krl.KRLCertificateSection{Sections: []krl.KRLCertificateSubsection{
+				&krl.KRLCertificateSerialBitmap{Offset: 40, Bitmap: big.NewInt(0b100)},
+			}},
+			key:      certificate,
+			expected: &Revocation{Reason: RevocationReasonCertificateSerial, Key: key, Serial: 42},
+		},
+		"serial bitmap not matching": {
+			section: &krl.KRLCertificateSection{Sections: []krl.KRLCertificateSubsection{
+				&krl.KRLCertificateSerialBitmap{Offset: 40, Bitmap: big.NewInt(0b011)},
+			}},
+			key: certificate,
+		},
+		"key id": {
+			section: &krl.KRLCertificateSection{CA: authority, Sections: []krl.KRLCertificateSubsection{
+				&krl.KRLCertificateKeyID{"jane", "john"},
+			}},
+			key:      certificate,
+			expected: &Revocation{Reason: RevocationReasonCertificateKeyID, Key: key, Authority: authority, KeyID: "john"},
+		},
+		"other authority": {
+			section: &krl.KRLCertificateSection{CA: otherAuthority, Sections: []krl.KRLCertificateSubsection{
+				&krl.KRLCertificateKeyID{"john"},
+			}},
+			key: certificate,
+		},
+		"certificate section on plain key": {
+			section: &krl.KRLCertificateSection{Sections: []krl.KRLCertificateSubsection{
+				&krl.KRLCertificateSerialRange{Min: 0, Max: 100},
+			}},
+			key: key,
+		},
+		"not revoked": {
+			section: &krl.KRLExplicitKeySection{otherAuthority},
+			key:     certificate,
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			list := &krl.KRL{Version: 3, Sections: []krl.KRLSection{tc.section}}
+
+			revocation := CheckRevocation(list, tc.key)
+
+			if tc.expected == nil {
+				assert.Check(t, revocation == nil, "%v", revocation)
+				return
+			}
+
+			tc.expected.ListVersion = 3
+			assert.Assert(t, revocation != nil)
+			assert.Check(t, cmp.DeepEqual(*revocation, *tc.expected))
+		})
+	}
+}
+
+func Test_Revocation_String(t *testing.T) {
+	authority := newVerificationTestSigner(t).PublicKey()
+	key := newVerificationTestSigner(t).PublicKey()
+
+	assert.Check(t, cmp.Equal(
+		Revocation{Reason: RevocationReasonExplicitKey, ListVersion: 3, Key: key}.String(),
+		"key "+ssh.FingerprintSHA256(key)+" revoked by explicit key entry of key revocation list version 3",
+	))
+	assert.Check(t, cmp.Equal(
+		Revocation{Reason: RevocationReasonCertificateSerial, ListVersion: 3, Key: key, Authority: authority, Serial: 42}.String(),
+		"certificate serial 42 signed by "+ssh.FingerprintSHA256(authority)+" revoked by certificate serial entry of key revocation list version 3",
+	))
+	assert.Check(t, cmp.Equal(
+		Revocation{Reason: RevocationReasonCertificateKeyID, ListVersion: 3, Key: key, KeyID: "john"}.String(),
+		`certificate key id "john" revoked by certificate key id entry of key revocation list version 3`,
+	))
+	assert.Check(t, cmp.Equal(
+		Revocation{Reason: RevocationReasonCertificateAuthority, ListVersion: 3, Key: authority}.String(),
+		"certificate authority "+ssh.FingerprintSHA256(authority)+" revoked by certificate authority entry of key revocation list version 3",
+	))
+}
+
+func Test_Client_Revocation(t *testing.T) {
+	ctx := context.Background()
+	revoked := newVerificationTestSigner(t).PublicKey()
+	other := newVerificationTestSigner(t).PublicKey()
+
+	doer := &krlCacheTestDoer{t: t}
+	doer.revoke(revoked, 1)
+
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	isRevoked, err := client.IsRevoked(ctx, revoked)
+	assert.NilError(t, err)
+	assert.Check(t, isRevoked)
+
+	isRevoked, err = client.IsRevoked(ctx, other)
+	assert.NilError(t, err)
+	assert.Check(t, !isRevoked)
+
+	revocation, err := client.SessionUser("john").Key(revoked).Revocation(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(revocation, &Revocation{Reason: RevocationReasonExplicitKey, ListVersion: 1, Key: revoked}))
+
+	revocation, err = client.SessionUser("john").Key(other).Revocation(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, revocation == nil)
+
+	doer.fail = true
+	_, err = client.Revocation(ctx, revoked)
+	assert.Check(t, cmp.ErrorIs(err, ErrServerFailure))
+	assert.Check(t, cmp.ErrorContains(err, "unable to get key revocation list"))
+}
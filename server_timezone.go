@@ -0,0 +1,111 @@
+package cassh
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// serverTimeLayout is the layout of the times sent by the CASSH server, without timezone.
+	serverTimeLayout = "2006-01-02 15:04:05"
+	// serverTimezoneOffsetPrecision is the precision of detected offsets; every timezone offset is a multiple of it,
+	// which absorbs the few seconds between the certificate validity and the expiration stored by the server.
+	serverTimezoneOffsetPrecision = 15 * time.Minute
+	// serverTimezoneMaxOffset is the maximum offset of a timezone, observations beyond it are ignored.
+	serverTimezoneMaxOffset = 14 * time.Hour
+)
+
+// serverTimezone interprets the times sent by the CASSH server without timezone.
+// When detection is enabled, the server offset is derived from observations and cached,
+// otherwise the configured timezone is used.
+type serverTimezone struct {
+	configured           *time.Location
+	configuredExplicitly bool
+	detection            bool
+	logger               *slog.Logger
+
+	m        sync.Mutex
+	detected *time.Location
+	warned   map[int]bool
+}
+
+func newServerTimezone(o *clientOptions) *serverTimezone {
+	tz := &serverTimezone{
+		configured:           o.serverTimezone,
+		configuredExplicitly: o.serverTimezoneConfigured,
+		detection:            o.serverTimezoneDetection,
+		logger:               o.serverTimezoneLogger,
+		warned:               make(map[int]bool),
+	}
+	if tz.logger == nil {
+		tz.logger = slog.Default()
+	}
+	return tz
+}
+
+// location returns the timezone to interpret the server times with.
+func (tz *serverTimezone) location() *time.Location {
+	tz.m.Lock()
+	defer tz.m.Unlock()
+
+	if tz.detected != nil {
+		return tz.detected
+	}
+	return tz.configured
+}
+
+// needsObservation returns whether signed certificates are to be compared with the server:
+// while the offset is still to be detected, and always when a timezone is configured, to warn when the server disagrees.
+func (tz *serverTimezone) needsObservation() bool {
+	tz.m.Lock()
+	defer tz.m.Unlock()
+
+	return tz.configuredExplicitly || (tz.detection && tz.detected == nil)
+}
+
+// observe compares a time sent by the server without timezone with the instant it is known to represent,
+// like the expiration of a freshly signed certificate. The HTTP Date header cannot be used for this, as it is always in GMT.
+// The observed offset is cached when detection is enabled, and a warning is logged when it disagrees with the timezone
+// explicitly configured, or with the default one when detection is disabled.
+func (tz *serverTimezone) observe(value string, instant time.Time) {
+	wallClock, isNaive := parseServerNaiveTime(value, time.UTC)
+	if !isNaive {
+		return
+	}
+
+	offset := wallClock.Sub(instant.Truncate(time.Second)).Round(serverTimezoneOffsetPrecision)
+	if offset > serverTimezoneMaxOffset || offset < -serverTimezoneMaxOffset {
+		return
+	}
+
+	offsetSeconds := int(offset / time.Second)
+	_, configuredOffsetSeconds := instant.In(tz.configured).Zone()
+
+	tz.m.Lock()
+	defer tz.m.Unlock()
+
+	if tz.detection {
+		tz.detected = time.FixedZone(formatServerTimezoneOffset(offsetSeconds), offsetSeconds)
+	}
+
+	if offsetSeconds != configuredOffsetSeconds && (tz.configuredExplicitly || !tz.detection) && !tz.warned[offsetSeconds] {
+		tz.warned[offsetSeconds] = true
+		tz.logger.Warn("cassh server timezone differs from the configured one",
+			slog.String("configured", tz.configured.String()),
+			slog.String("configured_offset", formatServerTimezoneOffset(configuredOffsetSeconds)),
+			slog.String("observed_offset", formatServerTimezoneOffset(offsetSeconds)),
+			slog.Bool("detection", tz.detection),
+		)
+	}
+}
+
+func formatServerTimezoneOffset(offsetSeconds int) string {
+	sign := '+'
+	if offsetSeconds < 0 {
+		sign = '-'
+		offsetSeconds = -offsetSeconds
+	}
+	return fmt.Sprintf("UTC%c%02d:%02d", sign, offsetSeconds/3600, offsetSeconds%3600/60)
+}
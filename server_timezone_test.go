@@ -0,0 +1,89 @@
+package cassh
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_serverTimezone(t *testing.T) {
+	instant := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	newTimezone := func(opts ...ClientOption) (*serverTimezone, *bytes.Buffer) {
+		var logs bytes.Buffer
+		o := clientOptionsDefaults()
+		for _, opt := range opts {
+			opt(o)
+		}
+		tz := newServerTimezone(o)
+		tz.logger = slog.New(slog.NewTextHandler(&logs, nil))
+		return tz, &logs
+	}
+
+	t.Run("detection", func(t *testing.T) {
+		tz, logs := newTimezone(ClientOptionServerTimezoneDetection(nil))
+		assert.Check(t, tz.needsObservation())
+		assert.Check(t, cmp.Equal(tz.location(), time.UTC), "configured timezone is used until detected")
+
+		tz.observe("2024-01-02 08:34:07", instant)
+		assert.Check(t, !tz.needsObservation())
+		assert.Check(t, cmp.Equal(tz.location().String(), "UTC+05:30"))
+
+		parsed, err := time.ParseInLocation(serverTimeLayout, "2024-01-02 08:34:05", tz.location())
+		assert.NilError(t, err)
+		assert.Check(t, parsed.Equal(instant))
+
+		tz.observe("2024-01-01 22:04:05", instant)
+		assert.Check(t, cmp.Equal(tz.location().String(), "UTC-05:00"), "last observation wins")
+
+		assert.Check(t, cmp.Equal(logs.Len(), 0), "default timezone is not worth a warning")
+	})
+
+	t.Run("detection disagreeing with the configured timezone", func(t *testing.T) {
+		tz, logs := newTimezone(ClientOptionServerTimezone(time.UTC), ClientOptionServerTimezoneDetection(nil))
+
+		tz.observe("2024-01-02 05:04:05", instant)
+		tz.observe("2024-01-02 05:04:05", instant)
+		assert.Check(t, tz.needsObservation(), "configured timezone is checked on every observation")
+		assert.Check(t, cmp.Equal(tz.location().String(), "UTC+02:00"))
+		assert.Check(t, cmp.Contains(logs.String(), "level=WARN msg=\"cassh server timezone differs from the configured one\" configured=UTC configured_offset=UTC+00:00 observed_offset=UTC+02:00 detection=true"))
+		assert.Check(t, cmp.Equal(bytes.Count(logs.Bytes(), []byte("\n")), 1), "warning is logged once")
+
+		logs.Reset()
+		tz.observe("2024-01-02 03:04:05", instant)
+		assert.Check(t, cmp.Equal(logs.Len(), 0), "agreeing observation")
+	})
+
+	t.Run("without detection", func(t *testing.T) {
+		tz, logs := newTimezone()
+		assert.Check(t, !tz.needsObservation())
+
+		tz.observe("2024-01-02 05:04:05", instant)
+		assert.Check(t, cmp.Equal(tz.location(), time.UTC), "configured timezone is kept")
+		assert.Check(t, cmp.Contains(logs.String(), "observed_offset=UTC+02:00 detection=false"))
+	})
+
+	t.Run("configured without detection", func(t *testing.T) {
+		tz, _ := newTimezone(ClientOptionServerTimezone(time.UTC))
+		assert.Check(t, tz.needsObservation())
+	})
+
+	t.Run("invalid observations are ignored", func(t *testing.T) {
+		tz, logs := newTimezone(ClientOptionServerTimezoneDetection(nil))
+
+		tz.observe("not a time", instant)
+		tz.observe("2024-01-03 03:04:05", instant)
+		assert.Check(t, tz.needsObservation())
+		assert.Check(t, cmp.Equal(logs.Len(), 0))
+	})
+}
+
+func Test_formatServerTimezoneOffset(t *testing.T) {
+	assert.Check(t, cmp.Equal(formatServerTimezoneOffset(0), "UTC+00:00"))
+	assert.Check(t, cmp.Equal(formatServerTimezoneOffset(5*3600+45*60), "UTC+05:45"))
+	assert.Check(t, cmp.Equal(formatServerTimezoneOffset(-(9*3600+30*60)), "UTC-09:30"))
+}
@@ -0,0 +1,108 @@
+package cassh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverVersion negotiates the name and version of the CASSH server, asked once using Health and cached afterward,
+// as the payloads vary between server releases.
+type serverVersion struct {
+	health func(ctx context.Context) (string, string, error)
+
+	m          sync.Mutex
+	negotiated bool
+	name       string
+	version    string
+}
+
+// get returns the name and version of the server. Servers not exposing the /health endpoint,
+// like older releases, have an empty name and version.
+func (v *serverVersion) get(ctx context.Context) (string, string, error) {
+	v.m.Lock()
+	defer v.m.Unlock()
+
+	if v.negotiated {
+		return v.name, v.version, nil
+	}
+
+	name, version, err := v.health(ctx)
+
+	var apiErr *APIError
+	switch {
+	case err == nil:
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		name, version = "", ""
+	default:
+		return "", "", fmt.Errorf("unable to get server health: %w", err)
+	}
+
+	v.negotiated = true
+	v.name = strings.TrimSpace(name)
+	v.version = strings.TrimSpace(version)
+
+	return v.name, v.version, nil
+}
+
+// cached returns the name and version of the server, and whenever they have been negotiated, without negotiating them.
+func (v *serverVersion) cached() (string, string, bool) {
+	v.m.Lock()
+	defer v.m.Unlock()
+
+	return v.name, v.version, v.negotiated
+}
+
+// describe describes the server for error messages, using the negotiated version if any.
+func (v *serverVersion) describe() string {
+	name, version, negotiated := v.cached()
+	switch {
+	case !negotiated || version == "":
+		return "unknown server version"
+	case name == "":
+		return "server version " + version
+	default:
+		return "server " + name + " " + version
+	}
+}
+
+// decodingError adds the server version to errors decoding the responses of the server.
+func (v *serverVersion) decodingError(err error) error {
+	return fmt.Errorf("%w (%s)", err, v.describe())
+}
+
+// decoding returns how to decode the responses of the server, depending on its negotiated version.
+func (v *serverVersion) decoding() serverDecoding {
+	_, version, negotiated := v.cached()
+	return serverDecoding{naiveTimesOnly: negotiated && version == ""}
+}
+
+// serverDecoding defines how the responses of a server release are decoded.
+// Servers whose version has not been negotiated are decoded accepting the formats of all releases.
+type serverDecoding struct {
+	// naiveTimesOnly is true for servers not exposing their version, which predate the /health endpoint
+	// and only send times without timezone, like "2006-01-02 15:04:05", in their timezone.
+	naiveTimesOnly bool
+}
+
+// parseTime parses a time sent by the server.
+func (d serverDecoding) parseTime(value string, timeZone *time.Location) (time.Time, error) {
+	if !d.naiveTimesOnly {
+		return parseServerTime(value, timeZone)
+	}
+
+	switch value = strings.TrimSpace(value); strings.ToLower(value) {
+	case "", "none", "null":
+		return time.Time{}, nil
+	}
+
+	if wallClock, isNaive := parseServerNaiveTime(value, timeZone); isNaive {
+		return wallClock, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unknown time format %q, servers without version send times without timezone", value)
+}
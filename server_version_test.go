@@ -0,0 +1,160 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_serverVersion(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("asked once", func(t *testing.T) {
+		var calls int
+		v := &serverVersion{health: func(context.Context) (string, string, error) {
+			calls++
+			return "cassh", " 1.12.0\n", nil
+		}}
+
+		for i := 0; i < 2; i++ {
+			name, version, err := v.get(ctx)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Equal(name, "cassh"))
+			assert.Check(t, cmp.Equal(version, "1.12.0"))
+		}
+		assert.Check(t, cmp.Equal(calls, 1))
+		assert.Check(t, cmp.Equal(v.describe(), "server cassh 1.12.0"))
+	})
+
+	t.Run("servers without health endpoint", func(t *testing.T) {
+		var calls int
+		v := &serverVersion{health: func(context.Context) (string, string, error) {
+			calls++
+			return "", "", newAPIError(http.MethodGet, "/health", http.StatusNotFound, "not found")
+		}}
+
+		name, version, err := v.get(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(name, ""))
+		assert.Check(t, cmp.Equal(version, ""))
+		assert.Check(t, cmp.Equal(v.describe(), "unknown server version"))
+		assert.Check(t, cmp.Equal(calls, 1))
+	})
+
+	t.Run("failures are not cached", func(t *testing.T) {
+		var calls int
+		v := &serverVersion{health: func(context.Context) (string, string, error) {
+			calls++
+			if calls == 1 {
+				return "", "", errors.New("boom")
+			}
+			return "", "2.0.0", nil
+		}}
+
+		_, _, err := v.get(ctx)
+		assert.Check(t, cmp.ErrorContains(err, "unable to get server health: boom"))
+		assert.Check(t, cmp.Equal(v.describe(), "unknown server version"))
+
+		_, _, err = v.get(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(v.describe(), "server version 2.0.0"))
+	})
+
+	t.Run("decoding error", func(t *testing.T) {
+		var calls int
+		v := &serverVersion{health: func(context.Context) (string, string, error) {
+			calls++
+			return "cassh", "1.12.0", nil
+		}}
+		cause := errors.New("unable to parse expiration time")
+
+		err := v.decodingError(cause)
+		assert.Check(t, cmp.ErrorIs(err, cause))
+		assert.Check(t, cmp.Error(err, "unable to parse expiration time (unknown server version)"))
+		assert.Check(t, cmp.Equal(calls, 0), "errors must not negotiate the version")
+
+		_, _, err = v.get(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Error(v.decodingError(cause), "unable to parse expiration time (server cassh 1.12.0)"))
+	})
+}
+
+func Test_serverVersion_decoding(t *testing.T) {
+	ctx := context.Background()
+	timezone := time.FixedZone("CEST", 2*3600)
+
+	for version, naiveTimesOnly := range map[string]bool{"": true, "1.12.0": false} {
+		v := &serverVersion{health: func(context.Context) (string, string, error) { return "", version, nil }}
+		assert.Check(t, !v.decoding().naiveTimesOnly, "versions not negotiated accept all formats")
+
+		_, _, err := v.get(ctx)
+		assert.NilError(t, err)
+		decoding := v.decoding()
+		assert.Check(t, cmp.Equal(decoding.naiveTimesOnly, naiveTimesOnly), version)
+
+		parsed, err := decoding.parseTime("2024-01-02 03:04:05", timezone)
+		assert.NilError(t, err)
+		assert.Check(t, parsed.Equal(time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC)), version)
+
+		parsed, err = decoding.parseTime("", timezone)
+		assert.NilError(t, err)
+		assert.Check(t, parsed.IsZero(), version)
+
+		_, err = decoding.parseTime("2024-01-02T03:04:05Z", timezone)
+		if naiveTimesOnly {
+			assert.Check(t, cmp.ErrorContains(err, "servers without version send times without timezone"))
+		} else {
+			assert.NilError(t, err)
+		}
+	}
+}
+
+type serverVersionTestDoer struct {
+	status string
+}
+
+func (d *serverVersionTestDoer) Do(req *http.Request) (*http.Response, error) {
+	body := d.status
+	if req.URL.Path == "/health" {
+		body = `{"name":"cassh","version":"1.12.0"}`
+	}
+
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func Test_Client_ServerVersion(t *testing.T) {
+	ctx := context.Background()
+	doer := &serverVersionTestDoer{status: `{"username":"john","status":"ACTIVE","expiration":"someday","principals":null}`}
+
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	name, version, err := client.ServerVersion(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(name, "cassh"))
+	assert.Check(t, cmp.Equal(version, "1.12.0"))
+
+	_, err = client.SessionUser("john").Status(ctx)
+	assert.Check(t, cmp.ErrorContains(err, `unable to parse expiration time: unknown time format "someday" (server cassh 1.12.0)`))
+
+	_, err = client.SessionAdmin().User("john").Status(ctx)
+	assert.Check(t, cmp.ErrorContains(err, `unable to parse expiration time: unknown time format "someday" (server cassh 1.12.0)`))
+
+	doer.status = `{"username":"john","status":"DISABLED","expiration":null,"principals":"a,b"}`
+	status, err := client.SessionUser("john").Status(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(status.KeyState, KeyStateUnknown))
+	assert.Check(t, cmp.DeepEqual(status.KeyPrincipals, Principals{"a", "b"}))
+}
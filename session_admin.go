@@ -3,7 +3,6 @@ package cassh
 import (
 	"context"
 	"net/url"
-	"time"
 
 	"github.com/krostar/httpclient"
 )
@@ -15,8 +14,10 @@ func (c *Client) SessionAdmin(opts ...SessionAdminOption) *SessionAdmin {
 		opt(o)
 	}
 	return &SessionAdmin{
-		api:            c.api.Clone(),
+		api:            c.api.Clone().WithRequestOverrideFunc(authenticateRequest(o.authMechanism)),
 		serverTimezone: c.serverTimezone,
+		serverVersion:  c.serverVersion,
+		serverFeatures: c.serverFeatures,
 		authMechanism:  o.authMechanism,
 	}
 }
@@ -24,17 +25,17 @@ func (c *Client) SessionAdmin(opts ...SessionAdminOption) *SessionAdmin {
 // SessionAdmin stores attributes useful to make admin related requests to the CASSH server.
 type SessionAdmin struct {
 	api            *httpclient.API
-	serverTimezone *time.Location
-	authMechanism  SessionAuth
+	serverTimezone *serverTimezone
+	serverVersion  *serverVersion
+	serverFeatures *serverFeatures
+	authMechanism  SessionAuthenticator
 }
 
 func (s *SessionAdmin) createRequestParameters() url.Values {
-	parameters := make(url.Values)
-	s.authMechanism.ExtendRequestParameters(parameters)
-	return parameters
+	return make(url.Values)
 }
 
 // CheckAuthentication checks whenever the provided admin authentication mechanism is valid and authorized.
 func (s *SessionAdmin) CheckAuthentication(ctx context.Context) error {
-	return s.api.Execute(ctx, s.api.Post("/test_auth").SendForm(s.createRequestParameters()))
+	return s.api.Execute(withOperation(ctx, operationAdminCheckAuth), s.api.Post("/test_auth").SendForm(s.createRequestParameters()))
 }
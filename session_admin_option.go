@@ -5,20 +5,52 @@ type SessionAdminOption func(o *sessionAdminOptions)
 
 func sessionAdminOptionsDefaults() *sessionAdminOptions {
 	return &sessionAdminOptions{
-		authMechanism: new(sessionAuthNoop),
+		authMechanism: NewSessionAuthenticator(new(sessionAuthNoop)),
 	}
 }
 
 type sessionAdminOptions struct {
-	authMechanism SessionAuth
+	authMechanism SessionAuthenticator
+}
+
+// SessionAdminOptionAuthenticationMechanism sets the provided authentication mechanism for the entire session.
+// Use NewSessionAuthenticator to provide a SessionAuth.
+func SessionAdminOptionAuthenticationMechanism(auth SessionAuthenticator) SessionAdminOption {
+	return func(o *sessionAdminOptions) {
+		o.authMechanism = auth
+	}
 }
 
 // SessionAdminOptionAuthenticationMechanismLDAP sets the authentication mechanism to LDAP for the entire session.
 func SessionAdminOptionAuthenticationMechanismLDAP(ldapName, ldapPassword string) SessionAdminOption {
 	return func(o *sessionAdminOptions) {
-		o.authMechanism = &sessionAuthLDAP{
+		o.authMechanism = NewSessionAuthenticator(&sessionAuthLDAP{
 			name:     ldapName,
 			password: ldapPassword,
-		}
+		})
+	}
+}
+
+// SessionAdminOptionAuthenticationMechanismLDAPCredential sets the authentication mechanism to LDAP for the entire session,
+// the credential being asked to the provider on each request.
+func SessionAdminOptionAuthenticationMechanismLDAPCredential(provider CredentialProvider) SessionAdminOption {
+	return func(o *sessionAdminOptions) {
+		o.authMechanism = &sessionAuthLDAPCredential{provider: provider}
+	}
+}
+
+// SessionAdminOptionAuthenticationMechanismHeader sets the authentication mechanism to a static header for the entire session,
+// like an API key expected by a proxy in front of the CASSH server.
+func SessionAdminOptionAuthenticationMechanismHeader(name, value string) SessionAdminOption {
+	return func(o *sessionAdminOptions) {
+		o.authMechanism = &sessionAuthHeader{name: name, value: value}
+	}
+}
+
+// SessionAdminOptionAuthenticationMechanismBearer sets the authentication mechanism to a bearer token for the entire session.
+// The refresh function, if not nil, is called to get a new token when the current one is missing or about to expire.
+func SessionAdminOptionAuthenticationMechanismBearer(token BearerToken, refresh BearerTokenRefreshFunc) SessionAdminOption {
+	return func(o *sessionAdminOptions) {
+		o.authMechanism = newSessionAuthBearer(&token, refresh)
 	}
 }
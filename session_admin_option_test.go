@@ -1,13 +1,17 @@
 package cassh
 
 import (
+	"context"
+	"net/http"
+	"net/url"
 	"testing"
 
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 )
 
 func SessionAdminOptionAuthenticationMechanismForTesting() SessionAdminOption {
-	return func(o *sessionAdminOptions) { o.authMechanism = new(sessionAuthTesting) }
+	return func(o *sessionAdminOptions) { o.authMechanism = NewSessionAuthenticator(new(sessionAuthTesting)) }
 }
 
 func Test_SessionAdminOptionAuthenticationMechanismLDAP(t *testing.T) {
@@ -17,3 +21,35 @@ func Test_SessionAdminOptionAuthenticationMechanismLDAP(t *testing.T) {
 	SessionAdminOptionAuthenticationMechanismLDAP("user", "pwd")(opts)
 	assert.Check(t, opts.authMechanism != nil)
 }
+
+func Test_SessionAdminOptionAuthenticationMechanismLDAPCredential(t *testing.T) {
+	opts := sessionAdminOptionsDefaults()
+	opts.authMechanism = nil
+	SessionAdminOptionAuthenticationMechanismLDAPCredential(CredentialProviderStatic("user", "pwd"))(opts)
+	assert.Check(t, opts.authMechanism != nil)
+}
+
+func Test_SessionAdminOptionAuthenticationMechanism(t *testing.T) {
+	opts := sessionAdminOptionsDefaults()
+	opts.authMechanism = nil
+	SessionAdminOptionAuthenticationMechanism(SessionAuthenticatorFunc(func(context.Context, *http.Request, url.Values) error { return nil }))(opts)
+	assert.Check(t, opts.authMechanism != nil)
+}
+
+func Test_SessionAdminOptionAuthenticationMechanismHeader(t *testing.T) {
+	opts := sessionAdminOptionsDefaults()
+	SessionAdminOptionAuthenticationMechanismHeader("X-Api-Key", "secret")(opts)
+	auth, ok := opts.authMechanism.(*sessionAuthHeader)
+	assert.Assert(t, ok)
+	assert.Check(t, cmp.Equal(*auth, sessionAuthHeader{name: "X-Api-Key", value: "secret"}))
+}
+
+func Test_SessionAdminOptionAuthenticationMechanismBearer(t *testing.T) {
+	opts := sessionAdminOptionsDefaults()
+	SessionAdminOptionAuthenticationMechanismBearer(BearerToken{AccessToken: "token"}, nil)(opts)
+
+	auth, ok := opts.authMechanism.(*sessionAuthBearer)
+	assert.Assert(t, ok)
+	assert.Check(t, cmp.Equal(auth.token.AccessToken, "token"))
+	assert.Check(t, auth.refresh == nil)
+}
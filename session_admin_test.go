@@ -64,7 +64,7 @@ func Test_SessionAdmin_CheckAuthentication(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
-	"time"
 
 	"github.com/krostar/httpclient"
 )
@@ -13,8 +12,11 @@ import (
 // User sets the user on which further commands will be applied.
 func (s *SessionAdmin) User(username Username) *SessionAdminUser {
 	return &SessionAdminUser{
-		api:                           s.api.Clone(),
+		api:                           s.api.Clone().WithRequestOverrideFunc(authenticateRequest(s.authMechanism)),
+		authMechanism:                 s.authMechanism,
 		serverTimezone:                s.serverTimezone,
+		serverVersion:                 s.serverVersion,
+		serverFeatures:                s.serverFeatures,
 		parentCreateRequestParameters: s.createRequestParameters,
 
 		username: username,
@@ -24,7 +26,10 @@ func (s *SessionAdmin) User(username Username) *SessionAdminUser {
 // SessionAdminUser stores attributes useful to make admin requests related to a specific user, to the CASSH server.
 type SessionAdminUser struct {
 	api                           *httpclient.API
-	serverTimezone                *time.Location
+	authMechanism                 SessionAuthenticator
+	serverTimezone                *serverTimezone
+	serverVersion                 *serverVersion
+	serverFeatures                *serverFeatures
 	parentCreateRequestParameters func() url.Values
 
 	username Username
@@ -42,7 +47,7 @@ func (s *SessionAdminUser) Status(ctx context.Context) (*UserStatus, error) {
 	var response apiUserStatusResponse
 
 	if err := s.api.
-		Do(ctx, s.api.
+		Do(withUserOperation(ctx, operationAdminUserStatus, s.username), s.api.
 			Post("/admin/{username}").
 			PathReplacer("{username}", s.username.String()).
 			SendForm(requestParameters)).
@@ -51,5 +56,10 @@ func (s *SessionAdminUser) Status(ctx context.Context) (*UserStatus, error) {
 		return nil, err
 	}
 
-	return dtoUserStatusResponse(response, s.serverTimezone)
+	status, err := dtoUserStatusResponse(response, s.serverTimezone.location(), s.serverVersion.decoding())
+	if err != nil {
+		return nil, s.serverVersion.decodingError(err)
+	}
+
+	return status, nil
 }
@@ -0,0 +1,153 @@
+package cassh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PendingUsers returns the users whose key is waiting for an admin approval.
+func (s *SessionAdmin) PendingUsers(ctx context.Context) ([]UserStatus, error) {
+	return s.Users(ctx, UsersFilter{KeyStates: []KeyState{KeyStatePending}})
+}
+
+// ApprovalStep is an action made while approving or rejecting a user key, and its outcome.
+type ApprovalStep struct {
+	Action string
+	Err    error
+}
+
+// ApprovalResult summarizes the actions made while approving or rejecting a user key.
+type ApprovalResult struct {
+	Username Username
+	Steps    []ApprovalStep
+}
+
+// Succeeded returns the actions which succeeded.
+func (r *ApprovalResult) Succeeded() []string {
+	var actions []string
+	for _, step := range r.Steps {
+		if step.Err == nil {
+			actions = append(actions, step.Action)
+		}
+	}
+	return actions
+}
+
+// Failed returns the steps which failed.
+func (r *ApprovalResult) Failed() []ApprovalStep {
+	var steps []ApprovalStep
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func (r *ApprovalResult) record(action string, err error) error {
+	r.Steps = append(r.Steps, ApprovalStep{Action: action, Err: err})
+	return err
+}
+
+func (r *ApprovalResult) err() error {
+	var errs []error
+	for _, step := range r.Failed() {
+		errs = append(errs, fmt.Errorf("unable to %s: %w", step.Action, step.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// Approve activates the pending key of the user, after updating its certificates validity and its principals
+// if requested by the options. The key is activated last, so it is never usable with a partial configuration:
+// if any action fails, the actions already made are reverted, on a best effort basis, and the key stays pending.
+// The returned result lists all the actions made, and is returned even on errors.
+func (s *SessionAdminUser) Approve(ctx context.Context, opts ...SessionAdminUserApproveOption) (*ApprovalResult, error) {
+	o := sessionAdminUserApproveOptionsDefault()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	result := &ApprovalResult{Username: s.username}
+
+	if o.expiry != 0 && o.expiry < time.Hour {
+		return result, fmt.Errorf("invalid expiry %s, smallest is 1h", o.expiry.String())
+	}
+
+	previous, err := s.pendingStatus(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	var rollbacks []func()
+	rollback := func() {
+		for i := len(rollbacks) - 1; i >= 0; i-- {
+			rollbacks[i]()
+		}
+	}
+	// reverting must not be prevented by the cancellation of the context that made the approval fail
+	rollbackCtx := context.WithoutCancel(ctx)
+
+	if len(o.principals) > 0 {
+		if result.record("set principals", s.Principals().Set(ctx, o.principals[0], o.principals[1:]...)) != nil {
+			return result, result.err()
+		}
+		rollbacks = append(rollbacks, func() {
+			if len(previous.KeyPrincipals) == 0 {
+				_ = result.record("restore principals", s.Principals().Reset(rollbackCtx))
+				return
+			}
+			_ = result.record("restore principals", s.Principals().Set(rollbackCtx, previous.KeyPrincipals[0], previous.KeyPrincipals[1:]...))
+		})
+	}
+
+	if o.expiry > 0 {
+		if result.record("set expiry", s.Key().SetExpiry(ctx, o.expiry)) != nil {
+			rollback()
+			return result, result.err()
+		}
+		if previous.KeyExpiry > 0 {
+			rollbacks = append(rollbacks, func() {
+				_ = result.record("restore expiry", s.Key().SetExpiry(rollbackCtx, previous.KeyExpiry))
+			})
+		}
+	}
+
+	if result.record("activate", s.Key().Activate(ctx)) != nil {
+		rollback()
+		return result, result.err()
+	}
+
+	return result, nil
+}
+
+// Reject deletes the pending key of the user. Keys already activated are not deleted.
+// The returned result lists all the actions made, and is returned even on errors.
+func (s *SessionAdminUser) Reject(ctx context.Context) (*ApprovalResult, error) {
+	result := &ApprovalResult{Username: s.username}
+
+	if _, err := s.pendingStatus(ctx); err != nil {
+		return result, err
+	}
+
+	if result.record("delete", s.Key().Delete(ctx)) != nil {
+		return result, result.err()
+	}
+
+	return result, nil
+}
+
+// pendingStatus returns the user status, ensuring its key is waiting for an admin approval.
+func (s *SessionAdminUser) pendingStatus(ctx context.Context) (*UserStatus, error) {
+	status, err := s.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get user status: %w", err)
+	}
+
+	if status.KeyState != KeyStatePending {
+		return nil, fmt.Errorf("%w: key of user %s is %s", ErrKeyNotPending, s.username, status.KeyState)
+	}
+
+	return status, nil
+}
@@ -0,0 +1,31 @@
+package cassh
+
+import (
+	"time"
+)
+
+// SessionAdminUserApproveOption defines the signature of all options usable on SessionAdminUser.Approve.
+type SessionAdminUserApproveOption func(o *sessionAdminUserApproveOptions)
+
+func sessionAdminUserApproveOptionsDefault() *sessionAdminUserApproveOptions {
+	return &sessionAdminUserApproveOptions{}
+}
+
+type sessionAdminUserApproveOptions struct {
+	expiry     time.Duration
+	principals Principals
+}
+
+// SessionAdminUserApproveOptionExpiry sets the validity duration of the user certificates before activating the key.
+func SessionAdminUserApproveOptionExpiry(expiry time.Duration) SessionAdminUserApproveOption {
+	return func(o *sessionAdminUserApproveOptions) {
+		o.expiry = expiry
+	}
+}
+
+// SessionAdminUserApproveOptionPrincipals replaces the user principals before activating the key.
+func SessionAdminUserApproveOptionPrincipals(principal Principal, principals ...Principal) SessionAdminUserApproveOption {
+	return func(o *sessionAdminUserApproveOptions) {
+		o.principals = append(Principals{principal}, principals...)
+	}
+}
@@ -0,0 +1,23 @@
+package cassh
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_SessionAdminUserApproveOptionExpiry(t *testing.T) {
+	opts := sessionAdminUserApproveOptionsDefault()
+	assert.Check(t, cmp.Equal(opts.expiry, time.Duration(0)))
+	SessionAdminUserApproveOptionExpiry(12 * time.Hour)(opts)
+	assert.Check(t, cmp.Equal(opts.expiry, 12*time.Hour))
+}
+
+func Test_SessionAdminUserApproveOptionPrincipals(t *testing.T) {
+	opts := sessionAdminUserApproveOptionsDefault()
+	assert.Check(t, cmp.Len(opts.principals, 0))
+	SessionAdminUserApproveOptionPrincipals("jane", "web")(opts)
+	assert.Check(t, cmp.DeepEqual(opts.principals, Principals{"jane", "web"}))
+}
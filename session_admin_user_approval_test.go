@@ -0,0 +1,224 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// approvalTestDoer answers requests based on their method, path, and the action of their form,
+// and records the handled requests.
+type approvalTestDoer struct {
+	status    string
+	failures  map[string]int
+	requests  []string
+	onRequest func(route string)
+}
+
+func (d *approvalTestDoer) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	form, _ := url.ParseQuery(string(body))
+
+	route := req.Method + " " + req.URL.Path
+	switch {
+	case form.Get("status") == "true":
+		route += " status"
+	case form.Get("update") != "":
+		route += " update=" + form.Get("update")
+	case form.Get("purge") == "true":
+		route += " purge"
+	case form.Get("expiry") != "":
+		route += " expiry=" + form.Get("expiry")
+	}
+	d.requests = append(d.requests, route)
+	if d.onRequest != nil {
+		d.onRequest(route)
+	}
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	statusCode, responseBody := http.StatusOK, "OK"
+	if status, exists := d.failures[route]; exists {
+		statusCode, responseBody = status, http.StatusText(status)
+	} else if form.Get("status") == "true" {
+		responseBody = d.status
+	}
+
+	return &http.Response{
+		StatusCode:    statusCode,
+		Body:          io.NopCloser(bytes.NewReader([]byte(responseBody))),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func newApprovalTestSession(t *testing.T, doer *approvalTestDoer) *SessionAdminUser {
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer), ClientOptionRetryPolicy(RetryPolicyOptionMaxAttempts(1)))
+	assert.NilError(t, err)
+	return client.SessionAdmin().User("jane")
+}
+
+func Test_SessionAdminUser_Approve(t *testing.T) {
+	ctx := context.Background()
+	pending := `{"username":"jane","status":"PENDING","expiry":"+24h","principals":["jane"]}`
+	opts := []SessionAdminUserApproveOption{
+		SessionAdminUserApproveOptionPrincipals("jane", "web"),
+		SessionAdminUserApproveOptionExpiry(12 * time.Hour),
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		doer := &approvalTestDoer{status: pending}
+
+		result, err := newApprovalTestSession(t, doer).Approve(ctx, opts...)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(result.Username, Username("jane")))
+		assert.Check(t, cmp.DeepEqual(result.Succeeded(), []string{"set principals", "set expiry", "activate"}))
+		assert.Check(t, cmp.Len(result.Failed(), 0))
+		assert.Check(t, cmp.DeepEqual(doer.requests, []string{
+			"POST /admin/jane status",
+			"POST /admin/jane/principals update=jane",
+			"PATCH /admin/jane expiry=12h",
+			"POST /admin/jane",
+		}))
+	})
+
+	t.Run("without options", func(t *testing.T) {
+		doer := &approvalTestDoer{status: pending}
+
+		result, err := newApprovalTestSession(t, doer).Approve(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(result.Succeeded(), []string{"activate"}))
+		assert.Check(t, cmp.DeepEqual(doer.requests, []string{"POST /admin/jane status", "POST /admin/jane"}))
+	})
+
+	t.Run("not pending", func(t *testing.T) {
+		doer := &approvalTestDoer{status: `{"username":"jane","status":"ACTIVE"}`}
+
+		result, err := newApprovalTestSession(t, doer).Approve(ctx, opts...)
+		assert.Check(t, cmp.ErrorIs(err, ErrKeyNotPending))
+		assert.Check(t, cmp.ErrorContains(err, "key of user jane is ACTIVE"))
+		assert.Check(t, cmp.Len(result.Steps, 0))
+		assert.Check(t, cmp.DeepEqual(doer.requests, []string{"POST /admin/jane status"}))
+	})
+
+	t.Run("invalid expiry", func(t *testing.T) {
+		doer := &approvalTestDoer{status: pending}
+
+		_, err := newApprovalTestSession(t, doer).Approve(ctx, SessionAdminUserApproveOptionExpiry(30*time.Minute))
+		assert.Check(t, cmp.ErrorContains(err, "invalid expiry 30m0s, smallest is 1h"))
+		assert.Check(t, cmp.Len(doer.requests, 0))
+	})
+
+	t.Run("activation failure is reverted", func(t *testing.T) {
+		doer := &approvalTestDoer{status: pending, failures: map[string]int{"POST /admin/jane": http.StatusBadRequest}}
+
+		result, err := newApprovalTestSession(t, doer).Approve(ctx, opts...)
+		assert.Check(t, cmp.ErrorIs(err, ErrBadRequest))
+		assert.Check(t, cmp.ErrorContains(err, "unable to activate: "))
+		assert.Check(t, cmp.ErrorContains(err, "request POST /admin/jane failed with status 400"))
+		assert.Check(t, cmp.DeepEqual(result.Succeeded(), []string{"set principals", "set expiry", "restore expiry", "restore principals"}))
+		assert.Assert(t, cmp.Len(result.Failed(), 1))
+		assert.Check(t, cmp.Equal(result.Failed()[0].Action, "activate"))
+		assert.Check(t, cmp.DeepEqual(doer.requests[len(doer.requests)-2:], []string{
+			"PATCH /admin/jane expiry=24h",
+			"POST /admin/jane/principals update=jane",
+		}))
+	})
+
+	t.Run("expiry failure is reverted", func(t *testing.T) {
+		doer := &approvalTestDoer{
+			status: `{"username":"jane","status":"PENDING"}`,
+			failures: map[string]int{
+				"PATCH /admin/jane expiry=12h":      http.StatusMethodNotAllowed,
+				"POST /admin/jane/principals purge": http.StatusBadGateway,
+			},
+		}
+
+		result, err := newApprovalTestSession(t, doer).Approve(ctx, opts...)
+		assert.Check(t, cmp.ErrorIs(err, ErrUnsupportedByServer))
+		assert.Check(t, cmp.ErrorIs(err, ErrServerFailure))
+		assert.Check(t, cmp.ErrorContains(err, "unable to restore principals"))
+		assert.Check(t, cmp.DeepEqual(result.Succeeded(), []string{"set principals"}))
+		assert.Check(t, cmp.Len(result.Failed(), 2))
+		assert.Check(t, cmp.DeepEqual(doer.requests, []string{
+			"POST /admin/jane status",
+			"POST /admin/jane/principals update=jane",
+			"PATCH /admin/jane expiry=12h",
+			"POST /admin/jane/principals purge",
+		}))
+	})
+
+	t.Run("reverted despite cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		doer := &approvalTestDoer{status: pending, onRequest: func(route string) {
+			if route == "PATCH /admin/jane expiry=12h" {
+				cancel()
+			}
+		}}
+		session := newApprovalTestSession(t, doer)
+
+		result, err := session.Approve(ctx, opts...)
+		assert.Check(t, cmp.ErrorIs(err, context.Canceled))
+		assert.Check(t, cmp.ErrorContains(err, "unable to set expiry"))
+		assert.Check(t, cmp.DeepEqual(result.Succeeded(), []string{"set principals", "restore principals"}))
+	})
+}
+
+func Test_SessionAdminUser_Reject(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ok", func(t *testing.T) {
+		doer := &approvalTestDoer{status: `{"username":"jane","status":"PENDING"}`}
+
+		result, err := newApprovalTestSession(t, doer).Reject(ctx)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(result.Succeeded(), []string{"delete"}))
+		assert.Check(t, cmp.DeepEqual(doer.requests, []string{"POST /admin/jane status", "DELETE /admin/jane"}))
+	})
+
+	t.Run("not pending", func(t *testing.T) {
+		doer := &approvalTestDoer{status: `{"username":"jane","status":"REVOKED"}`}
+
+		_, err := newApprovalTestSession(t, doer).Reject(ctx)
+		assert.Check(t, cmp.ErrorIs(err, ErrKeyNotPending))
+		assert.Check(t, cmp.DeepEqual(doer.requests, []string{"POST /admin/jane status"}))
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		doer := &approvalTestDoer{failures: map[string]int{"POST /admin/jane status": http.StatusBadRequest}}
+
+		_, err := newApprovalTestSession(t, doer).Reject(ctx)
+		assert.Check(t, cmp.ErrorContains(err, "unable to get user status"))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		doer := &approvalTestDoer{status: `{"username":"jane","status":"PENDING"}`, failures: map[string]int{"DELETE /admin/jane": http.StatusBadRequest}}
+
+		result, err := newApprovalTestSession(t, doer).Reject(ctx)
+		assert.Check(t, cmp.ErrorContains(err, "unable to delete"))
+		assert.Check(t, cmp.Len(result.Succeeded(), 0))
+		assert.Check(t, cmp.Len(result.Failed(), 1))
+	})
+}
+
+func Test_SessionAdmin_PendingUsers(t *testing.T) {
+	doer := &usersTestDoer{body: `[{"username":"jane","status":"PENDING"},{"username":"john","status":"ACTIVE"}]`}
+
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	users, err := client.SessionAdmin().PendingUsers(context.Background())
+	assert.NilError(t, err)
+	assert.Assert(t, cmp.Len(users, 1))
+	assert.Check(t, cmp.Equal(users[0].Name, Username("jane")))
+}
@@ -13,7 +13,9 @@ import (
 // Key allows the manipulation of the user key as admin.
 func (s *SessionAdminUser) Key() *SessionAdminUserKey {
 	return &SessionAdminUserKey{
-		api:                           s.api.Clone(),
+		api:                           s.api.Clone().WithRequestOverrideFunc(authenticateRequest(s.authMechanism)),
+		authMechanism:                 s.authMechanism,
+		serverFeatures:                s.serverFeatures,
 		username:                      s.username,
 		parentCreateRequestParameters: s.createRequestParameters,
 	}
@@ -22,6 +24,8 @@ func (s *SessionAdminUser) Key() *SessionAdminUserKey {
 // SessionAdminUserKey stores attributes useful to make admin requests related to keys for a specific user, to the CASSH server.
 type SessionAdminUserKey struct {
 	api                           *httpclient.API
+	authMechanism                 SessionAuthenticator
+	serverFeatures                *serverFeatures
 	username                      Username
 	parentCreateRequestParameters func() url.Values
 }
@@ -33,7 +37,7 @@ func (s *SessionAdminUserKey) createRequestParameters() url.Values {
 // Activate activates the user's key.
 func (s *SessionAdminUserKey) Activate(ctx context.Context) error {
 	return s.api.
-		Execute(ctx, s.api.
+		Execute(withUserOperation(ctx, operationAdminUserKeyActivate, s.username), s.api.
 			Post("/admin/{username}").
 			PathReplacer("{username}", s.username.String()).
 			SendForm(s.createRequestParameters()))
@@ -45,7 +49,7 @@ func (s *SessionAdminUserKey) Revoke(ctx context.Context) error {
 	requestParameters.Set("revoke", strconv.FormatBool(true))
 
 	return s.api.
-		Execute(ctx, s.api.
+		Execute(withUserOperation(ctx, operationAdminUserKeyRevoke, s.username), s.api.
 			Post("/admin/{username}").
 			PathReplacer("{username}", s.username.String()).
 			SendForm(requestParameters))
@@ -60,17 +64,19 @@ func (s *SessionAdminUserKey) SetExpiry(ctx context.Context, expiry time.Duratio
 	requestParameters := s.createRequestParameters()
 	requestParameters.Set("expiry", strconv.FormatUint(uint64(expiry.Hours()), 10)+"h")
 
-	return s.api.
-		Execute(ctx, s.api.
-			Patch("/admin/{username}").
-			PathReplacer("{username}", s.username.String()).
-			SendForm(requestParameters))
+	return s.serverFeatures.use(ServerFeatureExpiryUpdate, func() error {
+		return s.api.
+			Execute(withUserOperation(ctx, operationAdminUserKeySetExpiry, s.username), s.api.
+				Patch("/admin/{username}").
+				PathReplacer("{username}", s.username.String()).
+				SendForm(requestParameters))
+	})
 }
 
 // Delete deletes the user's key (but it does not revoke it).
 func (s *SessionAdminUserKey) Delete(ctx context.Context) error {
 	return s.api.
-		Execute(ctx, s.api.
+		Execute(withUserOperation(ctx, operationAdminUserKeyDelete, s.username), s.api.
 			Delete("/admin/{username}").
 			PathReplacer("{username}", s.username.String()).
 			SendForm(s.createRequestParameters()))
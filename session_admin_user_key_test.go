@@ -69,7 +69,7 @@ func Test_SessionAdminUserKey_Activate(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -133,7 +133,7 @@ func Test_SessionAdminUserKey_Revoke(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -196,7 +196,7 @@ func Test_SessionAdminUserKey_SetExpiry(t *testing.T) {
 					return nil
 				},
 				check: func(err error) {
-					assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+					assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 				},
 			},
 		} {
@@ -271,7 +271,7 @@ func Test_SessionAdminUserKey_Delete(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
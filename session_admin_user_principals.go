@@ -11,7 +11,9 @@ import (
 // Principals handles user principals as admin.
 func (s *SessionAdminUser) Principals() *SessionAdminUserPrincipals {
 	return &SessionAdminUserPrincipals{
-		api:                           s.api.Clone(),
+		api:                           s.api.Clone().WithRequestOverrideFunc(authenticateRequest(s.authMechanism)),
+		authMechanism:                 s.authMechanism,
+		serverFeatures:                s.serverFeatures,
 		username:                      s.username,
 		parentCreateRequestParameters: s.createRequestParameters,
 	}
@@ -20,6 +22,8 @@ func (s *SessionAdminUser) Principals() *SessionAdminUserPrincipals {
 // SessionAdminUserPrincipals stores attributes useful to make admin requests related to user principals, to the CASSH server.
 type SessionAdminUserPrincipals struct {
 	api                           *httpclient.API
+	authMechanism                 SessionAuthenticator
+	serverFeatures                *serverFeatures
 	username                      Username
 	parentCreateRequestParameters func() url.Values
 }
@@ -37,11 +41,7 @@ func (s *SessionAdminUserPrincipals) Add(ctx context.Context, principal Principa
 		request.Add("add", principal.String())
 	}
 
-	return s.api.
-		Execute(ctx, s.api.
-			Post("/admin/{username}/principals").
-			PathReplacer("{username}", s.username.String()).
-			SendForm(request))
+	return s.execute(ctx, operationAdminUserPrincipalsAdd, request)
 }
 
 // Remove removes the provided principals from the user principals.
@@ -53,11 +53,7 @@ func (s *SessionAdminUserPrincipals) Remove(ctx context.Context, principal Princ
 		request.Add("remove", principal.String())
 	}
 
-	return s.api.
-		Execute(ctx, s.api.
-			Post("/admin/{username}/principals").
-			PathReplacer("{username}", s.username.String()).
-			SendForm(request))
+	return s.execute(ctx, operationAdminUserPrincipalsRemove, request)
 }
 
 // Set replaces the user principals with the provided principals.
@@ -69,11 +65,7 @@ func (s *SessionAdminUserPrincipals) Set(ctx context.Context, principal Principa
 		request.Add("update", principal.String())
 	}
 
-	return s.api.
-		Execute(ctx, s.api.
-			Post("/admin/{username}/principals").
-			PathReplacer("{username}", s.username.String()).
-			SendForm(request))
+	return s.execute(ctx, operationAdminUserPrincipalsSet, request)
 }
 
 // Reset removes all the user principals.
@@ -81,9 +73,16 @@ func (s *SessionAdminUserPrincipals) Reset(ctx context.Context) error {
 	request := s.createRequestParameters()
 	request.Set("purge", strconv.FormatBool(true))
 
-	return s.api.
-		Execute(ctx, s.api.
-			Post("/admin/{username}/principals").
-			PathReplacer("{username}", s.username.String()).
-			SendForm(request))
+	return s.execute(ctx, operationAdminUserPrincipalsReset, request)
+}
+
+// execute sends the request to the principals endpoint, which is not available on all server versions.
+func (s *SessionAdminUserPrincipals) execute(ctx context.Context, op operation, request url.Values) error {
+	return s.serverFeatures.use(ServerFeaturePrincipals, func() error {
+		return s.api.
+			Execute(withUserOperation(ctx, op, s.username), s.api.
+				Post("/admin/{username}/principals").
+				PathReplacer("{username}", s.username.String()).
+				SendForm(request))
+	})
 }
@@ -69,7 +69,7 @@ func Test_SessionAdminUserPrincipals_Add(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -133,7 +133,7 @@ func Test_SessionAdminUserPrincipals_Remove(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -197,7 +197,7 @@ func Test_SessionAdminUserPrincipals_Reset(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -261,7 +261,7 @@ func Test_SessionAdminUserPrincipals_Set(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -0,0 +1,172 @@
+package cassh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+// UsersFilter selects the users listed by SessionAdmin.Users. Zero fields match all users.
+type UsersFilter struct {
+	// KeyStates keeps the users whose key is in any of the states.
+	KeyStates []KeyState
+	// Principal keeps the users having the principal.
+	Principal Principal
+	// ExpiringBefore keeps the users whose key expires before the time; users without expiration are excluded.
+	ExpiringBefore time.Time
+	// NamePattern keeps the users whose name matches the pattern, using the syntax of path.Match, like "john*".
+	NamePattern string
+}
+
+// Match returns whenever the user status matches the filter.
+func (f UsersFilter) Match(status UserStatus) bool {
+	if len(f.KeyStates) > 0 {
+		var found bool
+		for _, state := range f.KeyStates {
+			if state == status.KeyState {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.Principal != "" && status.KeyPrincipals.Has(f.Principal) != nil {
+		return false
+	}
+
+	if !f.ExpiringBefore.IsZero() && (status.KeyExpiration.IsZero() || !status.KeyExpiration.Before(f.ExpiringBefore)) {
+		return false
+	}
+
+	if f.NamePattern != "" {
+		if matched, _ := path.Match(f.NamePattern, status.Name.String()); !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f UsersFilter) validate() error {
+	if _, err := path.Match(f.NamePattern, ""); err != nil {
+		return fmt.Errorf("invalid name pattern %q: %w", f.NamePattern, err)
+	}
+	return nil
+}
+
+// Users returns the status of all the users matching the filter.
+func (s *SessionAdmin) Users(ctx context.Context, filter UsersFilter) ([]UserStatus, error) {
+	var users []UserStatus
+
+	if err := s.EachUser(ctx, filter, func(status UserStatus) error {
+		users = append(users, status)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// EachUser calls fn with the status of each user matching the filter. The users are decoded one at a time
+// while the response is read, which keeps the memory usage low on large directories.
+// The CASSH server always sends all the users, the filter is applied client side.
+// Iteration stops on the first error returned by fn, which is returned as is.
+func (s *SessionAdmin) EachUser(ctx context.Context, filter UsersFilter, fn func(status UserStatus) error) error {
+	if err := filter.validate(); err != nil {
+		return err
+	}
+
+	requestParameters := s.createRequestParameters()
+	requestParameters.Set("status", strconv.FormatBool(true))
+
+	// errors of fn are kept aside to be returned as is, instead of being seen as answers of the server
+	var fnErr error
+
+	decoding := s.serverVersion.decoding()
+
+	err := s.serverFeatures.use(ServerFeatureAdminListing, func() error {
+		return s.api.
+			Do(withOperation(ctx, operationAdminUsers), s.api.
+				Post("/admin/all").
+				SendForm(requestParameters)).
+			BodySizeReadLimit(-1).
+			OnStatus(http.StatusOK, func(resp *http.Response) error {
+				return decodeUsers(json.NewDecoder(resp.Body), func(response apiUserStatusResponse) error {
+					status, err := dtoUserStatusResponse(response, s.serverTimezone.location(), decoding)
+					if err != nil {
+						return s.serverVersion.decodingError(err)
+					}
+
+					if !filter.Match(*status) {
+						return nil
+					}
+
+					if fnErr = fn(*status); fnErr != nil {
+						return errUsersIterationStopped
+					}
+
+					return nil
+				})
+			}).
+			Error()
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+
+	return err
+}
+
+var errUsersIterationStopped = errors.New("users iteration stopped")
+
+// decodeUsers decodes the users sent by the server one at a time. Depending on the server version, users are sent
+// as a list, or as an object indexed by username.
+func decodeUsers(decoder *json.Decoder, fn func(response apiUserStatusResponse) error) error {
+	delim, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("unable to parse users: %v", err)
+	}
+
+	indexed := delim == json.Delim('{')
+	if !indexed && delim != json.Delim('[') {
+		return fmt.Errorf("unable to parse users: unexpected %v", delim)
+	}
+
+	for decoder.More() {
+		var username string
+		if indexed {
+			key, err := decoder.Token()
+			if err != nil {
+				return fmt.Errorf("unable to parse users: %v", err)
+			}
+			username, _ = key.(string)
+		}
+
+		var response apiUserStatusResponse
+		if err := decoder.Decode(&response); err != nil {
+			return fmt.Errorf("unable to parse users: %v", err)
+		}
+		if response.Username == "" {
+			response.Username = username
+		}
+
+		if err := fn(response); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("unable to parse users: %v", err)
+	}
+
+	return nil
+}
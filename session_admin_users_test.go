@@ -0,0 +1,204 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_UsersFilter_Match(t *testing.T) {
+	expiration := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	status := UserStatus{
+		Name:          "john",
+		KeyState:      KeyStatePending,
+		KeyExpiration: expiration,
+		KeyPrincipals: Principals{"john", "root"},
+	}
+
+	for name, test := range map[string]struct {
+		filter   UsersFilter
+		expected bool
+	}{
+		"empty":                  {filter: UsersFilter{}, expected: true},
+		"matching state":         {filter: UsersFilter{KeyStates: []KeyState{KeyStateActive, KeyStatePending}}, expected: true},
+		"other state":            {filter: UsersFilter{KeyStates: []KeyState{KeyStateActive}}, expected: false},
+		"matching principal":     {filter: UsersFilter{Principal: "root"}, expected: true},
+		"missing principal":      {filter: UsersFilter{Principal: "admin"}, expected: false},
+		"expiring before":        {filter: UsersFilter{ExpiringBefore: expiration.Add(time.Second)}, expected: true},
+		"expiring after":         {filter: UsersFilter{ExpiringBefore: expiration}, expected: false},
+		"matching name pattern":  {filter: UsersFilter{NamePattern: "jo*"}, expected: true},
+		"other name pattern":     {filter: UsersFilter{NamePattern: "ja*"}, expected: false},
+		"all fields matching":    {filter: UsersFilter{KeyStates: []KeyState{KeyStatePending}, Principal: "john", ExpiringBefore: expiration.Add(time.Hour), NamePattern: "john"}, expected: true},
+		"one field not matching": {filter: UsersFilter{KeyStates: []KeyState{KeyStatePending}, Principal: "admin"}, expected: false},
+	} {
+		assert.Check(t, cmp.Equal(test.filter.Match(status), test.expected), name)
+	}
+
+	assert.Check(t, !UsersFilter{ExpiringBefore: expiration}.Match(UserStatus{Name: "jane"}), "users without expiration never expire")
+}
+
+type usersTestDoer struct {
+	body       string
+	statusCode int
+	requests   []*http.Request
+}
+
+func (d *usersTestDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requests = append(d.requests, req)
+
+	statusCode := d.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	return &http.Response{
+		StatusCode:    statusCode,
+		Body:          io.NopCloser(bytes.NewReader([]byte(d.body))),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func Test_SessionAdmin_Users(t *testing.T) {
+	ctx := context.Background()
+
+	newSession := func(t *testing.T, doer *usersTestDoer) *SessionAdmin {
+		client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+		assert.NilError(t, err)
+		return client.SessionAdmin()
+	}
+
+	t.Run("indexed by username", func(t *testing.T) {
+		doer := &usersTestDoer{body: `{
+			"jane": {"status": "PENDING", "expiration": "2024-06-01 12:00:00", "principals": "jane,web"},
+			"john": {"username": "john", "status": "ACTIVE", "expiration": null, "principals": ["john"]}
+		}`}
+
+		users, err := newSession(t, doer).Users(ctx, UsersFilter{})
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Len(users, 2))
+		assert.Check(t, cmp.Equal(users[0].Name, Username("jane")))
+		assert.Check(t, cmp.Equal(users[0].KeyState, KeyStatePending))
+		assert.Check(t, users[0].KeyExpiration.Equal(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)))
+		assert.Check(t, cmp.DeepEqual(users[0].KeyPrincipals, Principals{"jane", "web"}))
+		assert.Check(t, cmp.Equal(users[1].Name, Username("john")))
+		assert.Check(t, users[1].KeyExpiration.IsZero())
+
+		assert.Assert(t, cmp.Len(doer.requests, 1))
+		assert.Check(t, cmp.Equal(doer.requests[0].Method, http.MethodPost))
+		assert.Check(t, cmp.Equal(doer.requests[0].URL.Path, "/admin/all"))
+		body, err := io.ReadAll(doer.requests[0].Body)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(string(body), "status=true"))
+	})
+
+	t.Run("list with filter", func(t *testing.T) {
+		doer := &usersTestDoer{body: `[
+			{"username": "jane", "status": "PENDING"},
+			{"username": "john", "status": "ACTIVE"},
+			{"username": "jack", "status": "PENDING"}
+		]`}
+
+		users, err := newSession(t, doer).Users(ctx, UsersFilter{KeyStates: []KeyState{KeyStatePending}, NamePattern: "ja*"})
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Len(users, 2))
+		assert.Check(t, cmp.Equal(users[0].Name, Username("jane")))
+		assert.Check(t, cmp.Equal(users[1].Name, Username("jack")))
+	})
+
+	t.Run("no users", func(t *testing.T) {
+		users, err := newSession(t, &usersTestDoer{body: `{}`}).Users(ctx, UsersFilter{})
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Len(users, 0))
+	})
+
+	t.Run("invalid name pattern", func(t *testing.T) {
+		doer := &usersTestDoer{body: `[]`}
+		_, err := newSession(t, doer).Users(ctx, UsersFilter{NamePattern: "[a"})
+		assert.Check(t, cmp.ErrorContains(err, `invalid name pattern "[a"`))
+		assert.Check(t, cmp.Len(doer.requests, 0))
+	})
+
+	t.Run("invalid responses", func(t *testing.T) {
+		for body, expected := range map[string]string{
+			`"users"`:                      "unable to parse users: unexpected users",
+			`[{"username": "john"}`:        "unable to parse users: unexpected end of JSON input",
+			`[{"username": ["john"]}]`:     "unable to parse users",
+			`[{"expiration": "someday"}]`:  `unable to parse expiration time: unknown time format "someday" (unknown server version)`,
+			`{"john": {"status": "ACTIVE"`: "unable to parse users",
+		} {
+			_, err := newSession(t, &usersTestDoer{body: body}).Users(ctx, UsersFilter{})
+			assert.Check(t, cmp.ErrorContains(err, expected), body)
+		}
+	})
+
+	t.Run("unsupported by server", func(t *testing.T) {
+		doer := &usersTestDoer{body: "Method Not Allowed", statusCode: http.StatusMethodNotAllowed}
+		session := newSession(t, doer)
+
+		_, err := session.Users(ctx, UsersFilter{})
+		assert.Check(t, cmp.ErrorIs(err, ErrUnsupportedByServer))
+		_, err = session.Users(ctx, UsersFilter{})
+		assert.Check(t, cmp.ErrorIs(err, ErrUnsupportedByServer))
+		assert.Check(t, cmp.Len(doer.requests, 1))
+	})
+}
+
+func Test_SessionAdmin_EachUser(t *testing.T) {
+	ctx := context.Background()
+
+	users := make([]string, 100)
+	for i := range users {
+		users[i] = `{"username": "user` + strings.Repeat("x", i) + `", "status": "ACTIVE"}`
+	}
+	doer := &usersTestDoer{body: "[" + strings.Join(users, ",") + "]"}
+
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+	session := client.SessionAdmin()
+
+	var count int
+	assert.NilError(t, session.EachUser(ctx, UsersFilter{}, func(UserStatus) error {
+		count++
+		return nil
+	}))
+	assert.Check(t, cmp.Equal(count, 100))
+
+	t.Run("stops on callback errors", func(t *testing.T) {
+		stop := newAPIError(http.MethodPost, "/admin/user/principals", http.StatusNotFound, "Not Found")
+
+		var count int
+		err := session.EachUser(ctx, UsersFilter{}, func(UserStatus) error {
+			if count++; count == 3 {
+				return stop
+			}
+			return nil
+		})
+		assert.Check(t, cmp.Equal(err, error(stop)))
+		assert.Check(t, cmp.Equal(count, 3))
+		assert.Check(t, client.serverFeatures.supported(ServerFeatureAdminListing), "callback errors are not answers of the server")
+	})
+}
+
+func Test_decodeUsers(t *testing.T) {
+	var usernames []string
+	err := decodeUsers(json.NewDecoder(strings.NewReader(`{"a": {}, "b": {"username": "c"}}`)), func(response apiUserStatusResponse) error {
+		usernames = append(usernames, response.Username)
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(usernames, []string{"a", "c"}))
+
+	stop := errors.New("stop")
+	err = decodeUsers(json.NewDecoder(strings.NewReader(`[{}, {}]`)), func(apiUserStatusResponse) error { return stop })
+	assert.Check(t, cmp.Equal(err, stop))
+}
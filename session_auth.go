@@ -1,18 +1,111 @@
 package cassh
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"net/url"
+	"strings"
+
+	"github.com/krostar/httpclient"
 )
 
 // SessionAuth defines a way to authenticate a request.
+// Implementations should never expose their secrets when printed or logged.
+// Mechanisms needing a context, headers, or that may fail should implement SessionAuthenticator instead.
 type SessionAuth interface {
 	ExtendRequestParameters(url.Values)
 }
 
+// SessionAuthenticator defines a way to authenticate a request, right before it is sent.
+// It can set headers on the request, modify the form parameters sent with it, or abort it by returning an error.
+// Implementations should never expose their secrets when printed or logged.
+type SessionAuthenticator interface {
+	Authenticate(ctx context.Context, req *http.Request, parameters url.Values) error
+}
+
+// SessionAuthenticatorFunc is a function implementing the SessionAuthenticator interface.
+type SessionAuthenticatorFunc func(ctx context.Context, req *http.Request, parameters url.Values) error
+
+// Authenticate implements SessionAuthenticator.
+func (f SessionAuthenticatorFunc) Authenticate(ctx context.Context, req *http.Request, parameters url.Values) error {
+	return f(ctx, req, parameters)
+}
+
+func (SessionAuthenticatorFunc) String() string { return "func" }
+
+// NewSessionAuthenticator adapts the provided authentication mechanism to a SessionAuthenticator.
+func NewSessionAuthenticator(auth SessionAuth) SessionAuthenticator {
+	if authenticator, ok := auth.(SessionAuthenticator); ok {
+		return authenticator
+	}
+	return sessionAuthAdapter{auth: auth}
+}
+
+// sessionAuthAdapter authenticates requests with a SessionAuth.
+type sessionAuthAdapter struct {
+	auth SessionAuth
+}
+
+func (a sessionAuthAdapter) Authenticate(_ context.Context, _ *http.Request, parameters url.Values) error {
+	a.auth.ExtendRequestParameters(parameters)
+	return nil
+}
+
+func (a sessionAuthAdapter) String() string { return describeSecretHolder(a.auth) }
+
+func (a sessionAuthAdapter) GoString() string {
+	if goStringer, ok := a.auth.(fmt.GoStringer); ok {
+		return goStringer.GoString()
+	}
+	return fmt.Sprintf("%T", a.auth)
+}
+
+func (a sessionAuthAdapter) LogValue() slog.Value {
+	if logValuer, ok := a.auth.(slog.LogValuer); ok {
+		return logValuer.LogValue()
+	}
+	return slog.StringValue(describeSecretHolder(a.auth))
+}
+
+// authenticateRequest returns a function authenticating the form requests, right before they are sent.
+func authenticateRequest(auth SessionAuthenticator) httpclient.RequestOverrideFunc {
+	return func(req *http.Request) (*http.Request, error) {
+		parameters := requestParameters(req)
+		if parameters == nil {
+			return nil, errors.New("only form requests can be authenticated")
+		}
+
+		if err := auth.Authenticate(req.Context(), req, parameters); err != nil {
+			return nil, err
+		}
+
+		body := parameters.Encode()
+		req.ContentLength = int64(len(body))
+		req.Body = io.NopCloser(strings.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(body)), nil
+		}
+
+		return req, nil
+	}
+}
+
 type sessionAuthNoop struct{}
 
 func (sessionAuthNoop) ExtendRequestParameters(url.Values) {}
 
+func (sessionAuthNoop) String() string { return "none" }
+
+func (sessionAuthNoop) GoString() string { return "cassh.sessionAuthNoop{}" }
+
+func (sessionAuthNoop) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("mechanism", "none"))
+}
+
 type sessionAuthLDAP struct {
 	name     string
 	password string
@@ -22,3 +115,52 @@ func (auth sessionAuthLDAP) ExtendRequestParameters(values url.Values) {
 	values.Set("realname", auth.name)
 	values.Set("password", auth.password)
 }
+
+func (auth sessionAuthLDAP) String() string {
+	return fmt.Sprintf("ldap(realname=%s, password=%s)", auth.name, redactSecret(auth.password))
+}
+
+func (auth sessionAuthLDAP) GoString() string {
+	return fmt.Sprintf("cassh.sessionAuthLDAP{name:%q, password:%q}", auth.name, redactSecret(auth.password))
+}
+
+func (auth sessionAuthLDAP) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("mechanism", "ldap"),
+		slog.String("realname", auth.name),
+		slog.String("password", redactSecret(auth.password)),
+	)
+}
+
+// sessionAuthLDAPCredential authenticates with the LDAP credential provided on each request.
+type sessionAuthLDAPCredential struct {
+	provider CredentialProvider
+}
+
+func (auth sessionAuthLDAPCredential) Authenticate(ctx context.Context, _ *http.Request, parameters url.Values) error {
+	credential, err := auth.provider.Credential(ctx)
+	if err != nil {
+		if errors.Is(err, ErrCredentialUnavailable) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrCredentialUnavailable, err)
+	}
+
+	sessionAuthLDAP{name: credential.Name, password: credential.Password}.ExtendRequestParameters(parameters)
+	return nil
+}
+
+func (auth sessionAuthLDAPCredential) String() string {
+	return "ldap(credential=" + describeSecretHolder(auth.provider) + ")"
+}
+
+func (auth sessionAuthLDAPCredential) GoString() string {
+	return fmt.Sprintf("cassh.sessionAuthLDAPCredential{provider:%q}", describeSecretHolder(auth.provider))
+}
+
+func (auth sessionAuthLDAPCredential) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("mechanism", "ldap"),
+		slog.String("credential", describeSecretHolder(auth.provider)),
+	)
+}
@@ -1,8 +1,16 @@
 package cassh
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -38,3 +46,221 @@ func Test_sessionAuthLDAP_ExtendRequestParameters(t *testing.T) {
 		"realname": {"ldapName"},
 	}))
 }
+
+func Test_sessionAuthNoop_String(t *testing.T) {
+	auth := new(sessionAuthNoop)
+
+	assert.Check(t, cmp.Equal(fmt.Sprint(auth), "none"))
+	assert.Check(t, cmp.Equal(fmt.Sprintf("%#v", auth), "cassh.sessionAuthNoop{}"))
+}
+
+func Test_sessionAuthLDAP_String(t *testing.T) {
+	auth := &sessionAuthLDAP{
+		name:     "ldapName",
+		password: "ldapPwd",
+	}
+
+	assert.Check(t, cmp.Equal(fmt.Sprint(auth), "ldap(realname=ldapName, password=[REDACTED])"))
+	assert.Check(t, cmp.Equal(fmt.Sprintf("%+v", auth), "ldap(realname=ldapName, password=[REDACTED])"))
+	assert.Check(t, cmp.Equal(fmt.Sprintf("%#v", auth), `cassh.sessionAuthLDAP{name:"ldapName", password:"[REDACTED]"}`))
+	assert.Check(t, cmp.Equal(fmt.Sprint(&sessionAuthLDAP{name: "ldapName"}), "ldap(realname=ldapName, password=)"))
+}
+
+func Test_sessionAuthLDAP_LogValue(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	logger.Info("session", "auth", &sessionAuthLDAP{
+		name:     "ldapName",
+		password: "ldapPwd",
+	})
+
+	assert.Check(t, cmp.Contains(logs.String(), "auth.mechanism=ldap auth.realname=ldapName auth.password=[REDACTED]"))
+	assert.Check(t, !strings.Contains(logs.String(), "ldapPwd"))
+}
+
+func Test_sessionAuthLDAPCredential(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ok", func(t *testing.T) {
+		password := "ldapPwd"
+		auth := &sessionAuthLDAPCredential{provider: CredentialProviderFunc(func(context.Context) (*Credential, error) {
+			return &Credential{Name: "ldapName", Password: password}, nil
+		})}
+
+		values := make(url.Values)
+		assert.NilError(t, auth.Authenticate(ctx, nil, values))
+		assert.Check(t, cmp.DeepEqual(values, url.Values{
+			"password": {"ldapPwd"},
+			"realname": {"ldapName"},
+		}))
+
+		password = "rotatedPwd"
+		values = make(url.Values)
+		assert.NilError(t, auth.Authenticate(ctx, nil, values))
+		assert.Check(t, cmp.Equal(values.Get("password"), "rotatedPwd"), "credential must be provided on each request")
+	})
+
+	t.Run("ko", func(t *testing.T) {
+		auth := &sessionAuthLDAPCredential{provider: CredentialProviderFunc(func(context.Context) (*Credential, error) {
+			return nil, errors.New("boom")
+		})}
+
+		values := make(url.Values)
+		err := auth.Authenticate(ctx, nil, values)
+		assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "boom"))
+		assert.Check(t, cmp.Len(values, 0))
+	})
+
+	t.Run("string", func(t *testing.T) {
+		auth := &sessionAuthLDAPCredential{provider: CredentialProviderStatic("ldapName", "ldapPwd")}
+
+		assert.Check(t, cmp.Equal(fmt.Sprint(auth), "ldap(credential=static(ldapName))"))
+		assert.Check(t, cmp.Equal(fmt.Sprintf("%#v", auth), `cassh.sessionAuthLDAPCredential{provider:"static(ldapName)"}`))
+
+		var logs bytes.Buffer
+		slog.New(slog.NewTextHandler(&logs, nil)).Info("session", "auth", auth)
+		assert.Check(t, cmp.Contains(logs.String(), "auth.mechanism=ldap auth.credential=static(ldapName)"))
+		assert.Check(t, !strings.Contains(logs.String(), "ldapPwd"))
+	})
+}
+
+func Test_NewSessionAuthenticator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("adapts SessionAuth", func(t *testing.T) {
+		auth := NewSessionAuthenticator(&sessionAuthLDAP{name: "ldapName", password: "ldapPwd"})
+
+		values := make(url.Values)
+		assert.NilError(t, auth.Authenticate(ctx, nil, values))
+		assert.Check(t, cmp.DeepEqual(values, url.Values{
+			"password": {"ldapPwd"},
+			"realname": {"ldapName"},
+		}))
+
+		assert.Check(t, cmp.Equal(fmt.Sprint(auth), "ldap(realname=ldapName, password=[REDACTED])"))
+		assert.Check(t, cmp.Equal(fmt.Sprintf("%#v", auth), `cassh.sessionAuthLDAP{name:"ldapName", password:"[REDACTED]"}`))
+
+		var logs bytes.Buffer
+		slog.New(slog.NewTextHandler(&logs, nil)).Info("session", "auth", auth)
+		assert.Check(t, cmp.Contains(logs.String(), "auth.mechanism=ldap auth.realname=ldapName auth.password=[REDACTED]"))
+	})
+
+	t.Run("adapts SessionAuth without description", func(t *testing.T) {
+		auth := NewSessionAuthenticator(new(sessionAuthTesting))
+
+		values := make(url.Values)
+		assert.NilError(t, auth.Authenticate(ctx, nil, values))
+		assert.Check(t, cmp.DeepEqual(values, url.Values{"testAuthPropagated": {"true"}}))
+		assert.Check(t, cmp.Equal(fmt.Sprint(auth), "*cassh.sessionAuthTesting"))
+	})
+
+	t.Run("keeps SessionAuthenticator", func(t *testing.T) {
+		auth := &sessionAuthenticatorTesting{}
+		assert.Check(t, NewSessionAuthenticator(auth) == SessionAuthenticator(auth))
+	})
+}
+
+type sessionAuthenticatorTesting struct{ sessionAuthTesting }
+
+func (sessionAuthenticatorTesting) Authenticate(context.Context, *http.Request, url.Values) error {
+	return nil
+}
+
+func Test_authenticateRequest(t *testing.T) {
+	newRequest := func(t *testing.T, contentType string) *http.Request {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "https://cassh.local/client", strings.NewReader("username=john"))
+		assert.NilError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		return req
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		req, err := authenticateRequest(SessionAuthenticatorFunc(func(ctx context.Context, req *http.Request, parameters url.Values) error {
+			assert.Check(t, cmp.Equal(parameters.Get("username"), "john"))
+			req.Header.Set("Authorization", "Bearer token")
+			parameters.Set("realname", "john@corp")
+			return nil
+		}))(newRequest(t, "application/x-www-form-urlencoded"))
+		assert.NilError(t, err)
+
+		assert.Check(t, cmp.Equal(req.Header.Get("Authorization"), "Bearer token"))
+		body, err := io.ReadAll(req.Body)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(string(body), "realname=john%40corp&username=john"))
+		assert.Check(t, cmp.Equal(req.ContentLength, int64(len(body))))
+
+		rewind, err := req.GetBody()
+		assert.NilError(t, err)
+		body, err = io.ReadAll(rewind)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(string(body), "realname=john%40corp&username=john"))
+	})
+
+	t.Run("ko authentication", func(t *testing.T) {
+		_, err := authenticateRequest(SessionAuthenticatorFunc(func(context.Context, *http.Request, url.Values) error {
+			return errors.New("boom")
+		}))(newRequest(t, "application/x-www-form-urlencoded"))
+		assert.Check(t, cmp.ErrorContains(err, "boom"))
+	})
+
+	t.Run("ko not a form", func(t *testing.T) {
+		_, err := authenticateRequest(NewSessionAuthenticator(new(sessionAuthNoop)))(newRequest(t, "application/json"))
+		assert.Check(t, cmp.ErrorContains(err, "only form requests can be authenticated"))
+	})
+}
+
+func Test_sessions_authenticator(t *testing.T) {
+	ctx := context.Background()
+
+	doer := new(redactionTestDoer)
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	auth := SessionAuthenticatorFunc(func(ctx context.Context, req *http.Request, parameters url.Values) error {
+		op, _ := operationFromContext(ctx)
+		req.Header.Set("X-Operation", string(op))
+		parameters.Set("token", "secret")
+		return nil
+	})
+
+	for op, call := range map[operation]func() error{
+		operationUserKeySet: func() error {
+			return client.SessionUser("john", SessionUserOptionAuthenticationMechanism(auth)).Key(newVerificationTestSigner(t).PublicKey()).Set(ctx)
+		},
+		operationAdminCheckAuth: func() error {
+			return client.SessionAdmin(SessionAdminOptionAuthenticationMechanism(auth)).CheckAuthentication(ctx)
+		},
+		operationAdminUserKeyRevoke: func() error {
+			return client.SessionAdmin(SessionAdminOptionAuthenticationMechanism(auth)).User("john").Key().Revoke(ctx)
+		},
+		operationAdminUserPrincipalsReset: func() error {
+			return client.SessionAdmin(SessionAdminOptionAuthenticationMechanism(auth)).User("john").Principals().Reset(ctx)
+		},
+	} {
+		assert.Check(t, call(), op)
+		assert.Check(t, cmp.Equal(doer.header.Get("X-Operation"), string(op)))
+		assert.Check(t, cmp.Contains(doer.body, "token=secret"), op)
+	}
+}
+
+func Test_sessions_credentialUnavailable(t *testing.T) {
+	ctx := context.Background()
+
+	doer := new(redactionTestDoer)
+	client, err := NewClient("https://cassh.local", ClientOptionHTTPClient(doer))
+	assert.NilError(t, err)
+
+	provider := CredentialProviderEnv("ldapName", "CASSH_TEST_UNSET_PASSWORD")
+
+	_, err = client.SessionUser("john", SessionUserOptionAuthenticationMechanismLDAPCredential(provider)).Status(ctx)
+	assert.Check(t, cmp.ErrorIs(err, ErrCredentialUnavailable))
+
+	admin := client.SessionAdmin(SessionAdminOptionAuthenticationMechanismLDAPCredential(provider))
+	assert.Check(t, cmp.ErrorIs(admin.CheckAuthentication(ctx), ErrCredentialUnavailable))
+	assert.Check(t, cmp.ErrorIs(admin.User("john").Key().Revoke(ctx), ErrCredentialUnavailable))
+	assert.Check(t, cmp.ErrorIs(admin.User("john").Principals().Reset(ctx), ErrCredentialUnavailable))
+
+	assert.Check(t, doer.header == nil, "no request must be sent without credential")
+}
@@ -0,0 +1,135 @@
+package cassh
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// bearerTokenExpiryLeeway is how long before its expiry a bearer token is refreshed.
+const bearerTokenExpiryLeeway = 30 * time.Second
+
+// sessionAuthHeader authenticates requests with a static header, like an API key expected by a proxy.
+type sessionAuthHeader struct {
+	name  string
+	value string
+}
+
+func (auth sessionAuthHeader) Authenticate(_ context.Context, req *http.Request, _ url.Values) error {
+	req.Header.Set(auth.name, auth.value)
+	return nil
+}
+
+func (auth sessionAuthHeader) String() string {
+	return fmt.Sprintf("header(%s=%s)", http.CanonicalHeaderKey(auth.name), redactSecret(auth.value))
+}
+
+func (auth sessionAuthHeader) GoString() string {
+	return fmt.Sprintf("cassh.sessionAuthHeader{name:%q, value:%q}", auth.name, redactSecret(auth.value))
+}
+
+func (auth sessionAuthHeader) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("mechanism", "header"),
+		slog.String("name", http.CanonicalHeaderKey(auth.name)),
+		slog.String("value", redactSecret(auth.value)),
+	)
+}
+
+// BearerToken is a token sent using the Authorization header.
+type BearerToken struct {
+	AccessToken string
+	// Expiry is the time after which the token is not valid anymore, the zero value meaning it never expires.
+	Expiry time.Time
+}
+
+func (t BearerToken) String() string {
+	if t.Expiry.IsZero() {
+		return redactSecret(t.AccessToken)
+	}
+	return redactSecret(t.AccessToken) + " (expires " + t.Expiry.Format(time.RFC3339) + ")"
+}
+
+// GoString never prints the access token, even with %#v.
+func (t BearerToken) GoString() string {
+	return fmt.Sprintf("cassh.BearerToken{AccessToken:%q, Expiry:%s}", redactSecret(t.AccessToken), t.Expiry.Format(time.RFC3339))
+}
+
+// LogValue never logs the access token.
+func (t BearerToken) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("access_token", redactSecret(t.AccessToken)),
+		slog.Time("expiry", t.Expiry),
+	)
+}
+
+// valid returns whether the token can still be used at the provided time.
+func (t *BearerToken) valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(bearerTokenExpiryLeeway).Before(t.Expiry))
+}
+
+// BearerTokenRefreshFunc returns a new bearer token, when the current one is missing or about to expire.
+type BearerTokenRefreshFunc func(ctx context.Context) (*BearerToken, error)
+
+// sessionAuthBearer authenticates requests with a bearer token, refreshed when it is about to expire.
+type sessionAuthBearer struct {
+	refresh BearerTokenRefreshFunc
+	now     func() time.Time
+
+	m     sync.Mutex
+	token *BearerToken
+}
+
+func newSessionAuthBearer(token *BearerToken, refresh BearerTokenRefreshFunc) *sessionAuthBearer {
+	return &sessionAuthBearer{
+		refresh: refresh,
+		now:     time.Now,
+		token:   token,
+	}
+}
+
+func (auth *sessionAuthBearer) Authenticate(ctx context.Context, req *http.Request, _ url.Values) error {
+	token, err := auth.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
+
+func (auth *sessionAuthBearer) currentToken(ctx context.Context) (*BearerToken, error) {
+	auth.m.Lock()
+	defer auth.m.Unlock()
+
+	if auth.token.valid(auth.now()) {
+		return auth.token, nil
+	}
+
+	if auth.refresh == nil {
+		return nil, fmt.Errorf("%w: bearer token expired and cannot be refreshed", ErrCredentialUnavailable)
+	}
+
+	token, err := auth.refresh(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to refresh bearer token: %v", ErrCredentialUnavailable, err)
+	}
+	if token == nil || token.AccessToken == "" {
+		return nil, fmt.Errorf("%w: refreshed bearer token is empty", ErrCredentialUnavailable)
+	}
+
+	auth.token = token
+	return token, nil
+}
+
+func (*sessionAuthBearer) String() string { return "bearer" }
+
+func (*sessionAuthBearer) GoString() string { return "cassh.sessionAuthBearer{}" }
+
+func (*sessionAuthBearer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("mechanism", "bearer"))
+}
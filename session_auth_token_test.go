@@ -0,0 +1,123 @@
+package cassh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_sessionAuthHeader(t *testing.T) {
+	auth := &sessionAuthHeader{name: "x-api-key", value: "secret"}
+
+	req, err := http.NewRequest(http.MethodPost, "http://cassh.local/client", http.NoBody)
+	assert.NilError(t, err)
+	assert.NilError(t, auth.Authenticate(context.Background(), req, nil))
+	assert.Check(t, cmp.Equal(req.Header.Get("X-Api-Key"), "secret"))
+
+	var logs bytes.Buffer
+	slog.New(slog.NewTextHandler(&logs, nil)).Info("session", "auth", auth)
+
+	assert.Check(t, cmp.Equal(fmt.Sprint(auth), "header(X-Api-Key=[REDACTED])"))
+	assert.Check(t, cmp.Equal(fmt.Sprintf("%#v", auth), `cassh.sessionAuthHeader{name:"x-api-key", value:"[REDACTED]"}`))
+	assert.Check(t, cmp.Contains(logs.String(), "auth.mechanism=header auth.name=X-Api-Key auth.value=[REDACTED]"))
+	assert.Check(t, !strings.Contains(logs.String(), "secret"))
+}
+
+func Test_BearerToken_String(t *testing.T) {
+	token := BearerToken{AccessToken: "secret", Expiry: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	var logs bytes.Buffer
+	slog.New(slog.NewTextHandler(&logs, nil)).Info("token", "token", token)
+
+	assert.Check(t, cmp.Equal(fmt.Sprint(token), "[REDACTED] (expires 2024-01-02T03:04:05Z)"))
+	assert.Check(t, cmp.Equal(fmt.Sprint(BearerToken{AccessToken: "secret"}), "[REDACTED]"))
+	assert.Check(t, cmp.Equal(fmt.Sprintf("%#v", token), `cassh.BearerToken{AccessToken:"[REDACTED]", Expiry:2024-01-02T03:04:05Z}`))
+	assert.Check(t, cmp.Contains(logs.String(), "token.access_token=[REDACTED]"))
+	assert.Check(t, !strings.Contains(logs.String(), "secret"))
+}
+
+func Test_sessionAuthBearer(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	authenticate := func(t *testing.T, auth *sessionAuthBearer) (string, error) {
+		req, err := http.NewRequest(http.MethodPost, "http://cassh.local/client", http.NoBody)
+		assert.NilError(t, err)
+		err = auth.Authenticate(context.Background(), req, nil)
+		return req.Header.Get("Authorization"), err
+	}
+
+	t.Run("valid token is used", func(t *testing.T) {
+		auth := newSessionAuthBearer(&BearerToken{AccessToken: "token", Expiry: now.Add(time.Hour)}, func(context.Context) (*BearerToken, error) {
+			return nil, errors.New("boom")
+		})
+		auth.now = func() time.Time { return now }
+
+		header, err := authenticate(t, auth)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(header, "Bearer token"))
+	})
+
+	t.Run("token is refreshed when missing or about to expire", func(t *testing.T) {
+		var refreshed int
+		auth := newSessionAuthBearer(new(BearerToken), func(context.Context) (*BearerToken, error) {
+			refreshed++
+			return &BearerToken{AccessToken: fmt.Sprintf("token-%d", refreshed), Expiry: now.Add(time.Minute)}, nil
+		})
+		auth.now = func() time.Time { return now }
+
+		header, err := authenticate(t, auth)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(header, "Bearer token-1"))
+
+		header, err = authenticate(t, auth)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(header, "Bearer token-1"))
+
+		now = now.Add(time.Minute - bearerTokenExpiryLeeway)
+		header, err = authenticate(t, auth)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(header, "Bearer token-2"))
+	})
+
+	t.Run("expired token without refresh", func(t *testing.T) {
+		auth := newSessionAuthBearer(&BearerToken{AccessToken: "token", Expiry: now}, nil)
+		auth.now = func() time.Time { return now }
+
+		_, err := authenticate(t, auth)
+		assert.Check(t, errors.Is(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "bearer token expired and cannot be refreshed"))
+	})
+
+	t.Run("refresh failures", func(t *testing.T) {
+		auth := newSessionAuthBearer(nil, func(context.Context) (*BearerToken, error) { return nil, errors.New("boom") })
+		_, err := authenticate(t, auth)
+		assert.Check(t, errors.Is(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "unable to refresh bearer token: boom"))
+
+		auth = newSessionAuthBearer(nil, func(context.Context) (*BearerToken, error) { return new(BearerToken), nil })
+		_, err = authenticate(t, auth)
+		assert.Check(t, errors.Is(err, ErrCredentialUnavailable))
+		assert.Check(t, cmp.ErrorContains(err, "refreshed bearer token is empty"))
+	})
+
+	t.Run("string", func(t *testing.T) {
+		auth := newSessionAuthBearer(&BearerToken{AccessToken: "secret"}, nil)
+
+		var logs bytes.Buffer
+		slog.New(slog.NewTextHandler(&logs, nil)).Info("session", "auth", auth)
+
+		assert.Check(t, cmp.Equal(fmt.Sprint(auth), "bearer"))
+		assert.Check(t, cmp.Equal(fmt.Sprintf("%#v", auth), "cassh.sessionAuthBearer{}"))
+		assert.Check(t, cmp.Contains(logs.String(), "auth.mechanism=bearer"))
+		assert.Check(t, !strings.Contains(logs.String(), "secret"))
+	})
+}
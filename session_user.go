@@ -4,7 +4,9 @@ import (
 	"context"
 	"net/http"
 	"net/url"
-	"time"
+
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/krostar/httpclient"
 )
@@ -16,18 +18,24 @@ func (c *Client) SessionUser(username Username, opts ...SessionUserOption) *Sess
 		opt(o)
 	}
 	return &SessionUser{
-		api:            c.api.Clone(),
-		serverTimezone: c.serverTimezone,
-		username:       username,
-		authMechanism:  o.authMechanism,
+		api:               c.api.Clone().WithRequestOverrideFunc(authenticateRequest(o.authMechanism)),
+		serverTimezone:    c.serverTimezone,
+		serverVersion:     c.serverVersion,
+		authorities:       c.certificateAuthorities,
+		keyRevocationList: c.KeyRevocationList,
+		username:          username,
+		authMechanism:     o.authMechanism,
 	}
 }
 
 // SessionUser stores attributes useful to make user related requests to the CASSH server.
 type SessionUser struct {
-	api            *httpclient.API
-	serverTimezone *time.Location
-	authMechanism  SessionAuth
+	api               *httpclient.API
+	serverTimezone    *serverTimezone
+	serverVersion     *serverVersion
+	authorities       func(ctx context.Context, signatureKey ssh.PublicKey) (authorityPins, error)
+	keyRevocationList func(ctx context.Context) (*krl.KRL, error)
+	authMechanism     SessionAuthenticator
 
 	username Username
 }
@@ -35,22 +43,34 @@ type SessionUser struct {
 func (s *SessionUser) createRequestParameters() url.Values {
 	parameters := make(url.Values)
 	parameters.Set("username", s.username.String())
-
-	s.authMechanism.ExtendRequestParameters(parameters)
-
 	return parameters
 }
 
 // Status returns the current user status.
 func (s *SessionUser) Status(ctx context.Context) (*UserStatus, error) {
+	response, err := s.statusResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := dtoUserStatusResponse(*response, s.serverTimezone.location(), s.serverVersion.decoding())
+	if err != nil {
+		return nil, s.serverVersion.decodingError(err)
+	}
+
+	return status, nil
+}
+
+// statusResponse returns the current user status, as sent by the server.
+func (s *SessionUser) statusResponse(ctx context.Context) (*apiUserStatusResponse, error) {
 	var response apiUserStatusResponse
 
 	if err := s.api.
-		Do(ctx, s.api.Post("/client/status").SendForm(s.createRequestParameters())).
+		Do(withUserOperation(ctx, operationUserStatus, s.username), s.api.Post("/client/status").SendForm(s.createRequestParameters())).
 		ReceiveJSON(http.StatusOK, &response).
 		Error(); err != nil {
 		return nil, err
 	}
 
-	return dtoUserStatusResponse(response, s.serverTimezone)
+	return &response, nil
 }
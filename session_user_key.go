@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
+	"github.com/stripe/krl"
 	"golang.org/x/crypto/ssh"
 
 	"github.com/krostar/httpclient"
@@ -17,16 +20,29 @@ import (
 // Key allows the manipulation of the user key.
 func (s *SessionUser) Key(key ssh.PublicKey) *SessionUserKey {
 	return &SessionUserKey{
-		api:                           s.api.Clone(),
+		api:                           s.api.Clone().WithRequestOverrideFunc(authenticateRequest(s.authMechanism)),
+		authMechanism:                 s.authMechanism,
 		key:                           key,
+		username:                      s.username,
+		authorities:                   s.authorities,
+		keyRevocationList:             s.keyRevocationList,
+		userStatus:                    s.statusResponse,
+		serverTimezone:                s.serverTimezone,
 		parentCreateRequestParameters: s.createRequestParameters,
 	}
 }
 
 // SessionUserKey stores attributes useful to make requests related to user's keys, to the CASSH server.
 type SessionUserKey struct {
-	api *httpclient.API
-	key ssh.PublicKey
+	api           *httpclient.API
+	authMechanism SessionAuthenticator
+	key           ssh.PublicKey
+	username      Username
+
+	authorities       func(ctx context.Context, signatureKey ssh.PublicKey) (authorityPins, error)
+	keyRevocationList func(ctx context.Context) (*krl.KRL, error)
+	userStatus        func(ctx context.Context) (*apiUserStatusResponse, error)
+	serverTimezone    *serverTimezone
 
 	parentCreateRequestParameters func() url.Values
 }
@@ -39,10 +55,12 @@ func (s *SessionUserKey) createRequestParameters() url.Values {
 
 // Set sets the user key.
 func (s *SessionUserKey) Set(ctx context.Context) error {
-	return s.api.Execute(ctx, s.api.Put("/client").SendForm(s.createRequestParameters()))
+	return s.api.Execute(withUserOperation(ctx, operationUserKeySet, s.username), s.api.Put("/client").SendForm(s.createRequestParameters()))
 }
 
 // Sign returns a certificate signed by the CASSH server.
+// Unless SessionUserKeySignOptionSkipVerification is used, the certificate is verified before being returned,
+// and a CertificateVerificationError is returned if it cannot be trusted.
 func (s *SessionUserKey) Sign(ctx context.Context, opts ...SessionUserKeySignOption) (*ssh.Certificate, error) {
 	o := sessionUserKeySignOptionsDefault()
 	for _, opt := range opts {
@@ -58,15 +76,84 @@ func (s *SessionUserKey) Sign(ctx context.Context, opts ...SessionUserKeySignOpt
 	var certificate ssh.Certificate
 
 	if err := s.api.
-		Do(ctx, s.api.Post("/client").SendForm(requestParameters)).
+		Do(withUserOperation(ctx, operationUserKeySign, s.username), s.api.Post("/client").SendForm(requestParameters)).
 		OnStatus(http.StatusOK, s.signParseSuccessResponse(&certificate)).
 		Error(); err != nil {
 		return nil, err
 	}
 
+	var status *apiUserStatusResponse
+
+	if !o.skipVerification {
+		response, err := s.verifyCertificate(ctx, &certificate, o)
+		if err != nil {
+			return nil, err
+		}
+		status = response
+	}
+
+	if status == nil && s.serverTimezone.needsObservation() {
+		// observation is best effort, the certificate is valid regardless
+		if response, err := s.userStatus(ctx); err == nil {
+			status = response
+		}
+	}
+
+	if status != nil {
+		s.observeServerTimezone(&certificate, status)
+	}
+
 	return &certificate, nil
 }
 
+// observeServerTimezone compares the expiration of the freshly signed certificate with the one returned by the server.
+func (s *SessionUserKey) observeServerTimezone(certificate *ssh.Certificate, response *apiUserStatusResponse) {
+	if parseKeyState(response.Status) != KeyStateActive || certificate.ValidBefore == ssh.CertTimeInfinity || certificate.ValidBefore > math.MaxInt64 {
+		return
+	}
+	s.serverTimezone.observe(response.Expiration, time.Unix(int64(certificate.ValidBefore), 0))
+}
+
+// verifyCertificate verifies the freshly signed certificate, and returns the user status when it was needed to.
+func (s *SessionUserKey) verifyCertificate(ctx context.Context, certificate *ssh.Certificate, o *sessionUserKeySignOptions) (*apiUserStatusResponse, error) {
+	expected := certificateExpectations{
+		authorities: authorityPins{keys: o.authorities},
+		key:         s.key,
+		username:    s.username,
+		now:         o.now(),
+		clockSkew:   o.clockSkew,
+	}
+
+	if expected.authorities.empty() {
+		authorities, err := s.authorities(ctx, certificate.SignatureKey)
+		if err != nil {
+			return nil, err
+		}
+		expected.authorities = authorities
+	}
+
+	var status *apiUserStatusResponse
+
+	if o.verifyPrincipals {
+		response, err := s.userStatus(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get user status to verify certificate principals: %w", err)
+		}
+		status = response
+
+		expected.principals = make(Principals, 0, len(response.Principals))
+		for _, principal := range response.Principals {
+			expected.principals = append(expected.principals, Principal(principal))
+		}
+	}
+
+	if err := verifyCertificate(certificate, expected); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
 func (*SessionUserKey) signParseSuccessResponse(certificate *ssh.Certificate) httpclient.ResponseHandler {
 	return func(resp *http.Response) error {
 		body, err := io.ReadAll(resp.Body)
@@ -1,16 +1,30 @@
 package cassh
 
+import (
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
 // SessionUserKeySignOption defines the signature of all options usable on SessionUserKeySign.
 type SessionUserKeySignOption func(o *sessionUserKeySignOptions)
 
 func sessionUserKeySignOptionsDefault() *sessionUserKeySignOptions {
 	return &sessionUserKeySignOptions{
-		force: false,
+		force:     false,
+		clockSkew: 5 * time.Minute,
+		now:       time.Now,
 	}
 }
 
 type sessionUserKeySignOptions struct {
 	force bool
+
+	skipVerification bool
+	authorities      []ssh.PublicKey
+	verifyPrincipals bool
+	clockSkew        time.Duration
+	now              func() time.Time
 }
 
 // SessionUserKeySignOptionForce sets the force attribute to the sign request.
@@ -19,3 +33,34 @@ func SessionUserKeySignOptionForce() SessionUserKeySignOption {
 		o.force = true
 	}
 }
+
+// SessionUserKeySignOptionSkipVerification disables the verification of the signed certificate.
+func SessionUserKeySignOptionSkipVerification() SessionUserKeySignOption {
+	return func(o *sessionUserKeySignOptions) {
+		o.skipVerification = true
+	}
+}
+
+// SessionUserKeySignOptionAuthority sets the authority keys the certificate must be signed with.
+// By default, the authority public key is fetched from the CASSH server.
+func SessionUserKeySignOptionAuthority(authorities ...ssh.PublicKey) SessionUserKeySignOption {
+	return func(o *sessionUserKeySignOptions) {
+		o.authorities = authorities
+	}
+}
+
+// SessionUserKeySignOptionVerifyPrincipals checks the certificate principals are the ones of the user status.
+// It requires an additional request to get the user status.
+func SessionUserKeySignOptionVerifyPrincipals() SessionUserKeySignOption {
+	return func(o *sessionUserKeySignOptions) {
+		o.verifyPrincipals = true
+	}
+}
+
+// SessionUserKeySignOptionClockSkew sets the tolerated difference between client and server clocks
+// when checking the certificate validity period. It defaults to 5 minutes.
+func SessionUserKeySignOptionClockSkew(clockSkew time.Duration) SessionUserKeySignOption {
+	return func(o *sessionUserKeySignOptions) {
+		o.clockSkew = clockSkew
+	}
+}
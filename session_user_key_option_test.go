@@ -1,9 +1,14 @@
 package cassh
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"testing"
+	"time"
 
+	"golang.org/x/crypto/ssh"
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 )
 
 func Test_SessionUserKeySignOptionForce(t *testing.T) {
@@ -12,3 +17,36 @@ func Test_SessionUserKeySignOptionForce(t *testing.T) {
 	SessionUserKeySignOptionForce()(opts)
 	assert.Check(t, opts.force)
 }
+
+func Test_SessionUserKeySignOptionSkipVerification(t *testing.T) {
+	opts := sessionUserKeySignOptionsDefault()
+	assert.Check(t, !opts.skipVerification)
+	SessionUserKeySignOptionSkipVerification()(opts)
+	assert.Check(t, opts.skipVerification)
+}
+
+func Test_SessionUserKeySignOptionAuthority(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	assert.NilError(t, err)
+
+	opts := sessionUserKeySignOptionsDefault()
+	assert.Check(t, cmp.Len(opts.authorities, 0))
+	SessionUserKeySignOptionAuthority(signer.PublicKey())(opts)
+	assert.Check(t, cmp.Len(opts.authorities, 1))
+}
+
+func Test_SessionUserKeySignOptionVerifyPrincipals(t *testing.T) {
+	opts := sessionUserKeySignOptionsDefault()
+	assert.Check(t, !opts.verifyPrincipals)
+	SessionUserKeySignOptionVerifyPrincipals()(opts)
+	assert.Check(t, opts.verifyPrincipals)
+}
+
+func Test_SessionUserKeySignOptionClockSkew(t *testing.T) {
+	opts := sessionUserKeySignOptionsDefault()
+	assert.Check(t, cmp.Equal(opts.clockSkew, 5*time.Minute))
+	SessionUserKeySignOptionClockSkew(time.Minute)(opts)
+	assert.Check(t, cmp.Equal(opts.clockSkew, time.Minute))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"math/big"
 	"net/http"
 	"net/url"
@@ -80,7 +81,7 @@ func Test_SessionUserKey_Set(t *testing.T) {
 				return nil
 			},
 			check: func(err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -93,7 +94,9 @@ func Test_SessionUserKey_Sign(t *testing.T) {
 		cert := &ssh.Certificate{
 			Nonce:           []byte{}, // To pass reflect.DeepEqual after marshal & parse, this must be non-nil.
 			Key:             privKey.PublicKey(),
-			CertType:        ssh.HostCert,
+			CertType:        ssh.UserCert,
+			KeyId:           "awesomeuser",
+			ValidBefore:     ssh.CertTimeInfinity,
 			ValidPrincipals: []string{"foo", "bar"},
 			Permissions: ssh.Permissions{ // To pass reflect.DeepEqual after marshal & parse, this must be non-nil.
 				CriticalOptions: make(map[string]string),
@@ -114,6 +117,7 @@ func Test_SessionUserKey_Sign(t *testing.T) {
 	privKey, err := sshx.WrapPrivateKey(rsaPrivKey)
 	assert.NilError(t, err)
 	caCert := createCert(t, privKey, privKey.Signer())
+	hostCert := createCert(t, privKey, privKey.Signer(), func(cert *ssh.Certificate) { cert.CertType = ssh.HostCert })
 
 	srv := httpclienttest.NewServer(func(u url.URL, httpDoer httpclient.Doer, checkCallback any) error {
 		client, err := NewClient(u.String(), ClientOptionHTTPClient(httpDoer), ClientOptionTolerateInsecureProtocols())
@@ -125,7 +129,10 @@ func Test_SessionUserKey_Sign(t *testing.T) {
 			SessionUser("awesomeuser", SessionUserOptionAuthenticationMechanismForTesting()).
 			Key(privKey.PublicKey())
 
-		certificate, err := session.Sign(context.Background(), SessionUserKeySignOptionForce())
+		certificate, err := session.Sign(context.Background(),
+			SessionUserKeySignOptionForce(),
+			SessionUserKeySignOptionAuthority(privKey.PublicKey()),
+		)
 		(checkCallback.(func(*ssh.Certificate, error)))(certificate, err)
 
 		return nil
@@ -186,6 +193,21 @@ func Test_SessionUserKey_Sign(t *testing.T) {
 				assert.Check(t, cmp.ErrorContains(err, "authorized key is not a certificate"))
 			},
 		},
+		"ko - certificate verification": {
+			matcher: reqMatcher,
+			writer: func(rw http.ResponseWriter) error {
+				rw.WriteHeader(http.StatusOK)
+				_, err := rw.Write(ssh.MarshalAuthorizedKey(hostCert))
+				return err
+			},
+			check: func(cert *ssh.Certificate, err error) {
+				var verificationErr *CertificateVerificationError
+				assert.Check(t, errors.As(err, &verificationErr))
+				assert.Check(t, cmp.ErrorIs(err, ErrCertificateVerification))
+				assert.Check(t, cmp.ErrorContains(err, "expected a user certificate"))
+				assert.Check(t, cert == nil)
+			},
+		},
 		"ko - unsuficient privileges": {
 			matcher: reqMatcher,
 			writer: func(rw http.ResponseWriter) error {
@@ -194,6 +216,20 @@ func Test_SessionUserKey_Sign(t *testing.T) {
 			},
 			check: func(cert *ssh.Certificate, err error) { assert.Check(t, cmp.ErrorIs(err, ErrInsufficientPrivileges)) },
 		},
+		"ko - key pending": {
+			matcher: reqMatcher,
+			writer: func(rw http.ResponseWriter) error {
+				rw.WriteHeader(http.StatusForbidden)
+				_, err := rw.Write([]byte("Status: PENDING"))
+				return err
+			},
+			check: func(cert *ssh.Certificate, err error) {
+				var apiErr *APIError
+				assert.Check(t, errors.As(err, &apiErr))
+				assert.Check(t, cmp.ErrorIs(err, ErrKeyPending))
+				assert.Check(t, cmp.ErrorContains(err, "request POST /client failed with status 403: Status: PENDING"))
+			},
+		},
 		"ko - unhandled status": {
 			matcher: reqMatcher,
 			writer: func(rw http.ResponseWriter) error {
@@ -201,7 +237,7 @@ func Test_SessionUserKey_Sign(t *testing.T) {
 				return nil
 			},
 			check: func(cert *ssh.Certificate, err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -5,20 +5,52 @@ type SessionUserOption func(o *sessionUserOptions)
 
 func sessionUserOptionsDefaults() *sessionUserOptions {
 	return &sessionUserOptions{
-		authMechanism: new(sessionAuthNoop),
+		authMechanism: NewSessionAuthenticator(new(sessionAuthNoop)),
 	}
 }
 
 type sessionUserOptions struct {
-	authMechanism SessionAuth
+	authMechanism SessionAuthenticator
+}
+
+// SessionUserOptionAuthenticationMechanism sets the provided authentication mechanism for the entire session.
+// Use NewSessionAuthenticator to provide a SessionAuth.
+func SessionUserOptionAuthenticationMechanism(auth SessionAuthenticator) SessionUserOption {
+	return func(o *sessionUserOptions) {
+		o.authMechanism = auth
+	}
 }
 
 // SessionUserOptionAuthenticationMechanismLDAP sets the authentication mechanism to LDAP for the entire session.
 func SessionUserOptionAuthenticationMechanismLDAP(ldapName, ldapPassword string) SessionUserOption {
 	return func(o *sessionUserOptions) {
-		o.authMechanism = &sessionAuthLDAP{
+		o.authMechanism = NewSessionAuthenticator(&sessionAuthLDAP{
 			name:     ldapName,
 			password: ldapPassword,
-		}
+		})
+	}
+}
+
+// SessionUserOptionAuthenticationMechanismLDAPCredential sets the authentication mechanism to LDAP for the entire session,
+// the credential being asked to the provider on each request.
+func SessionUserOptionAuthenticationMechanismLDAPCredential(provider CredentialProvider) SessionUserOption {
+	return func(o *sessionUserOptions) {
+		o.authMechanism = &sessionAuthLDAPCredential{provider: provider}
+	}
+}
+
+// SessionUserOptionAuthenticationMechanismHeader sets the authentication mechanism to a static header for the entire session,
+// like an API key expected by a proxy in front of the CASSH server.
+func SessionUserOptionAuthenticationMechanismHeader(name, value string) SessionUserOption {
+	return func(o *sessionUserOptions) {
+		o.authMechanism = &sessionAuthHeader{name: name, value: value}
+	}
+}
+
+// SessionUserOptionAuthenticationMechanismBearer sets the authentication mechanism to a bearer token for the entire session.
+// The refresh function, if not nil, is called to get a new token when the current one is missing or about to expire.
+func SessionUserOptionAuthenticationMechanismBearer(token BearerToken, refresh BearerTokenRefreshFunc) SessionUserOption {
+	return func(o *sessionUserOptions) {
+		o.authMechanism = newSessionAuthBearer(&token, refresh)
 	}
 }
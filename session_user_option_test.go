@@ -1,13 +1,17 @@
 package cassh
 
 import (
+	"context"
+	"net/http"
+	"net/url"
 	"testing"
 
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 )
 
 func SessionUserOptionAuthenticationMechanismForTesting() SessionUserOption {
-	return func(o *sessionUserOptions) { o.authMechanism = new(sessionAuthTesting) }
+	return func(o *sessionUserOptions) { o.authMechanism = NewSessionAuthenticator(new(sessionAuthTesting)) }
 }
 
 func Test_SessionUserOptionAuthenticationMechanismLDAP(t *testing.T) {
@@ -17,3 +21,35 @@ func Test_SessionUserOptionAuthenticationMechanismLDAP(t *testing.T) {
 	SessionUserOptionAuthenticationMechanismLDAP("user", "pwd")(opts)
 	assert.Check(t, opts.authMechanism != nil)
 }
+
+func Test_SessionUserOptionAuthenticationMechanismLDAPCredential(t *testing.T) {
+	opts := sessionUserOptionsDefaults()
+	opts.authMechanism = nil
+	SessionUserOptionAuthenticationMechanismLDAPCredential(CredentialProviderStatic("user", "pwd"))(opts)
+	assert.Check(t, opts.authMechanism != nil)
+}
+
+func Test_SessionUserOptionAuthenticationMechanism(t *testing.T) {
+	opts := sessionUserOptionsDefaults()
+	opts.authMechanism = nil
+	SessionUserOptionAuthenticationMechanism(SessionAuthenticatorFunc(func(context.Context, *http.Request, url.Values) error { return nil }))(opts)
+	assert.Check(t, opts.authMechanism != nil)
+}
+
+func Test_SessionUserOptionAuthenticationMechanismHeader(t *testing.T) {
+	opts := sessionUserOptionsDefaults()
+	SessionUserOptionAuthenticationMechanismHeader("X-Api-Key", "secret")(opts)
+	auth, ok := opts.authMechanism.(*sessionAuthHeader)
+	assert.Assert(t, ok)
+	assert.Check(t, cmp.Equal(*auth, sessionAuthHeader{name: "X-Api-Key", value: "secret"}))
+}
+
+func Test_SessionUserOptionAuthenticationMechanismBearer(t *testing.T) {
+	opts := sessionUserOptionsDefaults()
+	SessionUserOptionAuthenticationMechanismBearer(BearerToken{AccessToken: "token"}, nil)(opts)
+
+	auth, ok := opts.authMechanism.(*sessionAuthBearer)
+	assert.Assert(t, ok)
+	assert.Check(t, cmp.Equal(auth.token.AccessToken, "token"))
+	assert.Check(t, auth.refresh == nil)
+}
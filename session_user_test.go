@@ -68,11 +68,16 @@ func Test_SessionUser_Status(t *testing.T) {
 			check: func(status *UserStatus, err error) {
 				assert.Check(t, err == nil)
 				assert.DeepEqual(t, status, &UserStatus{
-					Name:          "foobar",
-					RealName:      "foo.bar@foo.b-ar",
-					KeyState:      KeyStateActive,
-					KeyExpiration: now.Add(time.Hour),
-					KeyPrincipals: Principals{"foo", "bar", "foobar"},
+					Name:           "foobar",
+					RealName:       "foo.bar@foo.b-ar",
+					KeyState:       KeyStateActive,
+					KeyExpiration:  now.Add(time.Hour),
+					KeyExpiry:      6 * time.Hour,
+					KeyPrincipals:  Principals{"foo", "bar", "foobar"},
+					KeyFingerprint: "SHA512:3423jhb",
+					KeyAlgorithm:   "RSA",
+					KeyBits:        8192,
+					KeyStrength:    KeyStrengthHigh,
 				})
 			},
 		},
@@ -91,7 +96,7 @@ func Test_SessionUser_Status(t *testing.T) {
 				return nil
 			},
 			check: func(_ *UserStatus, err error) {
-				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: unhandled request status"))
+				assert.Check(t, cmp.ErrorContains(err, "failed with status 500: Internal Server Error"))
 			},
 		},
 	} {
@@ -0,0 +1,181 @@
+// Package sshagent loads keys signed by CASSH into a running ssh agent,
+// and lists the keys of the agent to pick which one to sign.
+package sshagent
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ErrKeyNotFound is returned when no key of the agent matches the requested one.
+var ErrKeyNotFound = errors.New("key not found in agent")
+
+// Agent manipulates keys and certificates of an ssh agent.
+type Agent struct {
+	agent agent.Agent
+	now   func() time.Time
+}
+
+// New creates an Agent on top of the provided agent.
+func New(a agent.Agent) *Agent {
+	return &Agent{agent: a, now: time.Now}
+}
+
+// Dial connects to the agent listening on the unix socket set in the SSH_AUTH_SOCK environment variable.
+// The returned function closes the connection to the agent.
+func Dial() (*Agent, func() error, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, errors.New("SSH_AUTH_SOCK is not set, is an ssh agent running?")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to connect to agent: %v", err)
+	}
+
+	return New(agent.NewClient(conn)), conn.Close, nil
+}
+
+// AddCertificate adds the private key along with its certificate to the agent.
+// The agent forgets about it once the certificate expires.
+// Certificates of the same key previously added to the agent are removed once the new one is added,
+// so they are kept when it cannot be.
+func (a *Agent) AddCertificate(privateKey crypto.Signer, certificate *ssh.Certificate) error {
+	publicKey, err := ssh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		return fmt.Errorf("unable to get public key of private key: %v", err)
+	}
+
+	if !bytes.Equal(publicKey.Marshal(), certificate.Key.Marshal()) {
+		return errors.New("certificate does not match private key")
+	}
+
+	lifetime, err := a.certificateLifetime(certificate)
+	if err != nil {
+		return err
+	}
+
+	if err := a.agent.Add(agent.AddedKey{
+		PrivateKey:   privateKey,
+		Certificate:  certificate,
+		Comment:      certificate.KeyId,
+		LifetimeSecs: lifetime,
+	}); err != nil {
+		return fmt.Errorf("unable to add certificate to agent: %v", err)
+	}
+
+	return a.removeCertificates(publicKey, certificate)
+}
+
+func (a *Agent) certificateLifetime(certificate *ssh.Certificate) (uint32, error) {
+	if certificate.ValidBefore == ssh.CertTimeInfinity {
+		return 0, nil
+	}
+
+	if certificate.ValidBefore > math.MaxInt64 {
+		return 0, nil
+	}
+
+	lifetime := time.Unix(int64(certificate.ValidBefore), 0).Sub(a.now()) / time.Second
+	switch {
+	case lifetime <= 0:
+		return 0, errors.New("certificate already expired")
+	case lifetime > math.MaxUint32:
+		return 0, nil
+	default:
+		return uint32(lifetime), nil
+	}
+}
+
+// removeCertificates removes the certificates of the public key held by the agent, except the kept one.
+func (a *Agent) removeCertificates(publicKey ssh.PublicKey, kept *ssh.Certificate) error {
+	keys, err := a.agent.List()
+	if err != nil {
+		return fmt.Errorf("unable to list agent keys: %v", err)
+	}
+
+	for _, key := range keys {
+		if bytes.Equal(key.Marshal(), kept.Marshal()) {
+			continue
+		}
+
+		parsed, err := ssh.ParsePublicKey(key.Marshal())
+		if err != nil {
+			continue
+		}
+
+		if certificate, ok := parsed.(*ssh.Certificate); ok && bytes.Equal(certificate.Key.Marshal(), publicKey.Marshal()) {
+			if err := a.agent.Remove(key); err != nil {
+				return fmt.Errorf("unable to remove previous certificate from agent: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PublicKey is a public key held by the agent.
+type PublicKey struct {
+	ssh.PublicKey
+	Comment string
+}
+
+// PublicKeys lists the public keys held by the agent, certificates excluded.
+func (a *Agent) PublicKeys() ([]PublicKey, error) {
+	keys, err := a.agent.List()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list agent keys: %v", err)
+	}
+
+	var publicKeys []PublicKey
+	for _, key := range keys {
+		if strings.Contains(key.Format, "-cert-v01@openssh.com") {
+			continue
+		}
+
+		parsed, err := ssh.ParsePublicKey(key.Marshal())
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse agent key %s: %v", key.Comment, err)
+		}
+
+		publicKeys = append(publicKeys, PublicKey{PublicKey: parsed, Comment: key.Comment})
+	}
+
+	return publicKeys, nil
+}
+
+// FindPublicKey returns the public key of the agent matching the provided selector,
+// which is either a SHA256 fingerprint (as printed by ssh-add -l) or a key comment.
+// With an empty selector, the agent must hold exactly one key.
+func (a *Agent) FindPublicKey(selector string) (*PublicKey, error) {
+	publicKeys, err := a.PublicKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if selector == "" {
+		if len(publicKeys) != 1 {
+			return nil, fmt.Errorf("%w: agent holds %d keys, select one", ErrKeyNotFound, len(publicKeys))
+		}
+		return &publicKeys[0], nil
+	}
+
+	for i, publicKey := range publicKeys {
+		if ssh.FingerprintSHA256(publicKey) == selector || publicKey.Comment == selector {
+			return &publicKeys[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no key matches %q", ErrKeyNotFound, selector)
+}
@@ -0,0 +1,187 @@
+package sshagent
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func newTestKey(t *testing.T) (ed25519.PrivateKey, ssh.PublicKey) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	publicKey, err := ssh.NewPublicKey(privateKey.Public())
+	assert.NilError(t, err)
+	return privateKey, publicKey
+}
+
+func newTestCertificate(t *testing.T, key ssh.PublicKey, validBefore uint64) *ssh.Certificate {
+	authorityKey, _ := newTestKey(t)
+	authority, err := ssh.NewSignerFromKey(authorityKey)
+	assert.NilError(t, err)
+
+	certificate := &ssh.Certificate{
+		Key:         key,
+		KeyId:       "john",
+		CertType:    ssh.UserCert,
+		ValidBefore: validBefore,
+	}
+	assert.NilError(t, certificate.SignCert(rand.Reader, authority))
+	return certificate
+}
+
+func Test_Dial(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	_, _, err := Dial()
+	assert.Check(t, cmp.ErrorContains(err, "SSH_AUTH_SOCK is not set"))
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	_, _, err = Dial()
+	assert.Check(t, cmp.ErrorContains(err, "unable to connect to agent"))
+
+	listener, err := net.Listen("unix", socket)
+	assert.NilError(t, err)
+	defer listener.Close()
+
+	keyring := agent.NewKeyring()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	privateKey, _ := newTestKey(t)
+	assert.NilError(t, keyring.Add(agent.AddedKey{PrivateKey: privateKey, Comment: "john@laptop"}))
+
+	a, closeAgent, err := Dial()
+	assert.NilError(t, err)
+	defer func() { assert.Check(t, closeAgent()) }()
+
+	publicKeys, err := a.PublicKeys()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(publicKeys, 1))
+}
+
+func Test_Agent_AddCertificate(t *testing.T) {
+	now := time.Now()
+	keyring := agent.NewKeyring()
+	a := New(keyring)
+	a.now = func() time.Time { return now }
+
+	privateKey, publicKey := newTestKey(t)
+	assert.NilError(t, keyring.Add(agent.AddedKey{PrivateKey: privateKey, Comment: "john@laptop"}))
+
+	first := newTestCertificate(t, publicKey, uint64(now.Add(time.Hour).Unix()))
+	assert.NilError(t, a.AddCertificate(privateKey, first))
+
+	second := newTestCertificate(t, publicKey, uint64(now.Add(2*time.Hour).Unix()))
+	assert.NilError(t, a.AddCertificate(privateKey, second))
+
+	keys, err := keyring.List()
+	assert.NilError(t, err)
+	assert.Assert(t, cmp.Len(keys, 2), "previous certificate must be replaced")
+	assert.Check(t, cmp.DeepEqual(keys[0].Marshal(), publicKey.Marshal()))
+	assert.Check(t, cmp.DeepEqual(keys[1].Marshal(), second.Marshal()))
+	assert.Check(t, cmp.Equal(keys[1].Comment, "john"))
+
+	signers, err := keyring.Signers()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(signers, 2), "certificate must be usable to authenticate")
+
+	t.Run("ko", func(t *testing.T) {
+		otherKey, _ := newTestKey(t)
+		err := a.AddCertificate(otherKey, second)
+		assert.Check(t, cmp.ErrorContains(err, "certificate does not match private key"))
+
+		expired := newTestCertificate(t, publicKey, uint64(now.Add(-time.Second).Unix()))
+		err = a.AddCertificate(privateKey, expired)
+		assert.Check(t, cmp.ErrorContains(err, "certificate already expired"))
+	})
+
+	t.Run("previous certificate kept when adding fails", func(t *testing.T) {
+		locked := New(lockedTestKeyring{Agent: keyring})
+		locked.now = a.now
+
+		third := newTestCertificate(t, publicKey, uint64(now.Add(3*time.Hour).Unix()))
+		err := locked.AddCertificate(privateKey, third)
+		assert.Check(t, cmp.ErrorContains(err, "unable to add certificate to agent"))
+
+		keys, err := keyring.List()
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Len(keys, 2))
+		assert.Check(t, cmp.DeepEqual(keys[1].Marshal(), second.Marshal()))
+	})
+}
+
+// lockedTestKeyring is an agent refusing to add keys, like a locked one.
+type lockedTestKeyring struct{ agent.Agent }
+
+func (lockedTestKeyring) Add(agent.AddedKey) error { return errors.New("agent locked") }
+
+func Test_Agent_certificateLifetime(t *testing.T) {
+	now := time.Now()
+	a := New(agent.NewKeyring())
+	a.now = func() time.Time { return now }
+
+	for validBefore, expected := range map[uint64]uint32{
+		ssh.CertTimeInfinity:                               0,
+		uint64(now.Add(time.Hour).Unix()):                  uint32(time.Hour / time.Second),
+		uint64(now.Add(200 * 365 * 24 * time.Hour).Unix()): 0,
+	} {
+		lifetime, err := a.certificateLifetime(&ssh.Certificate{ValidBefore: validBefore})
+		assert.NilError(t, err)
+		assert.Check(t, lifetime == expected || lifetime == expected-1, "%d: %d", validBefore, lifetime)
+	}
+}
+
+func Test_Agent_FindPublicKey(t *testing.T) {
+	keyring := agent.NewKeyring()
+	a := New(keyring)
+
+	_, err := a.FindPublicKey("")
+	assert.Check(t, cmp.ErrorIs(err, ErrKeyNotFound))
+
+	firstPrivateKey, firstPublicKey := newTestKey(t)
+	assert.NilError(t, keyring.Add(agent.AddedKey{PrivateKey: firstPrivateKey, Comment: "first"}))
+	assert.NilError(t, keyring.Add(agent.AddedKey{
+		PrivateKey:  firstPrivateKey,
+		Certificate: newTestCertificate(t, firstPublicKey, ssh.CertTimeInfinity),
+	}))
+
+	publicKey, err := a.FindPublicKey("")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(publicKey.Comment, "first"))
+
+	secondPrivateKey, secondPublicKey := newTestKey(t)
+	assert.NilError(t, keyring.Add(agent.AddedKey{PrivateKey: secondPrivateKey, Comment: "second"}))
+
+	_, err = a.FindPublicKey("")
+	assert.Check(t, cmp.ErrorIs(err, ErrKeyNotFound))
+
+	publicKey, err = a.FindPublicKey("second")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(publicKey.Marshal(), secondPublicKey.Marshal()))
+
+	publicKey, err = a.FindPublicKey(ssh.FingerprintSHA256(firstPublicKey))
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(publicKey.Comment, "first"))
+
+	_, err = a.FindPublicKey("third")
+	assert.Check(t, cmp.ErrorIs(err, ErrKeyNotFound))
+}
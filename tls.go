@@ -0,0 +1,157 @@
+package cassh
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/krostar/httpclient"
+)
+
+// withTLS returns a copy of the http client whose transport uses the TLS options.
+// Only *http.Client using an *http.Transport, like http.DefaultClient, can be configured,
+// possibly wrapped with DoerWrapRedacted.
+func withTLS(doer httpclient.Doer, o *tlsOptions) (httpclient.Doer, error) {
+	if redacting, ok := doer.(*redactingDoer); ok {
+		wrapped, err := withTLS(redacting.wrapped, o)
+		if err != nil {
+			return nil, err
+		}
+		return DoerWrapRedacted(wrapped, redacting.inspector), nil
+	}
+
+	client, ok := doer.(*http.Client)
+	if !ok {
+		return nil, fmt.Errorf("unable to configure tls of %T, only *http.Client can be configured", doer)
+	}
+
+	transport := http.DefaultTransport
+	if client.Transport != nil {
+		transport = client.Transport
+	}
+
+	httpTransport, ok := transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unable to configure tls of %T, only *http.Transport can be configured", transport)
+	}
+
+	tlsConfig, err := newTLSConfig(httpTransport.TLSClientConfig, o)
+	if err != nil {
+		return nil, err
+	}
+
+	httpTransport = httpTransport.Clone()
+	httpTransport.TLSClientConfig = tlsConfig
+
+	clone := *client
+	clone.Transport = httpTransport
+
+	return &clone, nil
+}
+
+// newTLSConfig returns a copy of the provided TLS configuration, which can be nil, with the TLS options applied.
+func newTLSConfig(base *tls.Config, o *tlsOptions) (*tls.Config, error) {
+	tlsConfig := new(tls.Config)
+	if base != nil {
+		tlsConfig = base.Clone()
+	}
+
+	if tlsConfig.MinVersion < o.minVersion {
+		tlsConfig.MinVersion = o.minVersion
+	}
+
+	switch {
+	case o.certificate != nil:
+		tlsConfig.Certificates = []tls.Certificate{*o.certificate}
+		tlsConfig.GetClientCertificate = nil
+	case o.certificatePath != "":
+		reloader := &tlsCertificateReloader{certificatePath: o.certificatePath, keyPath: o.certificateKeyPath}
+		if _, err := reloader.GetClientCertificate(nil); err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = nil
+		tlsConfig.GetClientCertificate = reloader.GetClientCertificate
+	}
+
+	switch {
+	case o.rootCAs != nil:
+		tlsConfig.RootCAs = o.rootCAs
+	case o.rootCAsPath != "":
+		raw, err := os.ReadFile(o.rootCAsPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read root certificate authorities: %v", err)
+		}
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(raw) {
+			return nil, fmt.Errorf("no certificate found in root certificate authorities file %s", o.rootCAsPath)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	return tlsConfig, nil
+}
+
+// tlsCertificateReloader provides the client certificate, loaded again when its files change.
+type tlsCertificateReloader struct {
+	certificatePath string
+	keyPath         string
+
+	m                      sync.Mutex
+	certificate            *tls.Certificate
+	certificateFileVersion tlsCertificateFileVersion
+}
+
+// tlsCertificateFileVersion identifies the version of the certificate and key files.
+type tlsCertificateFileVersion struct {
+	certificateModTime time.Time
+	certificateSize    int64
+	keyModTime         time.Time
+	keySize            int64
+}
+
+// GetClientCertificate implements tls.Config GetClientCertificate.
+func (r *tlsCertificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	version, err := r.fileVersion()
+	if err == nil && r.certificate != nil && version == r.certificateFileVersion {
+		return r.certificate, nil
+	}
+
+	if err == nil {
+		var certificate tls.Certificate
+		if certificate, err = tls.LoadX509KeyPair(r.certificatePath, r.keyPath); err == nil {
+			r.certificate = &certificate
+			r.certificateFileVersion = version
+			return r.certificate, nil
+		}
+	}
+
+	if r.certificate != nil {
+		return r.certificate, nil
+	}
+
+	return nil, fmt.Errorf("unable to load client certificate: %v", err)
+}
+
+func (r *tlsCertificateReloader) fileVersion() (tlsCertificateFileVersion, error) {
+	certificateInfo, certificateErr := os.Stat(r.certificatePath)
+	keyInfo, keyErr := os.Stat(r.keyPath)
+	if err := errors.Join(certificateErr, keyErr); err != nil {
+		return tlsCertificateFileVersion{}, err
+	}
+
+	return tlsCertificateFileVersion{
+		certificateModTime: certificateInfo.ModTime(),
+		certificateSize:    certificateInfo.Size(),
+		keyModTime:         keyInfo.ModTime(),
+		keySize:            keyInfo.Size(),
+	}, nil
+}
@@ -0,0 +1,70 @@
+package cassh
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// TLSOption defines the signature of all options usable on ClientOptionTLS.
+type TLSOption func(o *tlsOptions)
+
+type tlsOptions struct {
+	minVersion uint16
+
+	certificate        *tls.Certificate
+	certificatePath    string
+	certificateKeyPath string
+	rootCAs            *x509.CertPool
+	rootCAsPath        string
+}
+
+func tlsOptionsDefaults() *tlsOptions {
+	return &tlsOptions{
+		minVersion: tls.VersionTLS12,
+	}
+}
+
+// TLSOptionClientCertificateFiles sets the PEM encoded client certificate and key files presented to the CASSH server.
+// The files are loaded again on the next TLS handshake when they change, to pick up renewed certificates;
+// if they cannot be loaded, for instance while being replaced, the previous certificate is used.
+func TLSOptionClientCertificateFiles(certificatePath, keyPath string) TLSOption {
+	return func(o *tlsOptions) {
+		o.certificate = nil
+		o.certificatePath = certificatePath
+		o.certificateKeyPath = keyPath
+	}
+}
+
+// TLSOptionClientCertificate sets the client certificate presented to the CASSH server.
+func TLSOptionClientCertificate(certificate tls.Certificate) TLSOption {
+	return func(o *tlsOptions) {
+		o.certificate = &certificate
+		o.certificatePath = ""
+		o.certificateKeyPath = ""
+	}
+}
+
+// TLSOptionRootCAsFile sets the PEM encoded bundle of certificate authorities trusted to verify the CASSH server certificate,
+// instead of the system ones.
+func TLSOptionRootCAsFile(path string) TLSOption {
+	return func(o *tlsOptions) {
+		o.rootCAs = nil
+		o.rootCAsPath = path
+	}
+}
+
+// TLSOptionRootCAs sets the certificate authorities trusted to verify the CASSH server certificate, instead of the system ones.
+func TLSOptionRootCAs(rootCAs *x509.CertPool) TLSOption {
+	return func(o *tlsOptions) {
+		o.rootCAs = rootCAs
+		o.rootCAsPath = ""
+	}
+}
+
+// TLSOptionMinVersion sets the minimum TLS version accepted, like tls.VersionTLS13. It defaults to TLS 1.2.
+// It never lowers the minimum version set by the TLS configuration of the provided http client.
+func TLSOptionMinVersion(version uint16) TLSOption {
+	return func(o *tlsOptions) {
+		o.minVersion = version
+	}
+}
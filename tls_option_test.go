@@ -0,0 +1,53 @@
+package cassh
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func Test_tlsOptionsDefaults(t *testing.T) {
+	opts := tlsOptionsDefaults()
+	assert.Check(t, cmp.Equal(opts.minVersion, uint16(tls.VersionTLS12)))
+	assert.Check(t, opts.certificate == nil)
+	assert.Check(t, cmp.Equal(opts.certificatePath, ""))
+	assert.Check(t, opts.rootCAs == nil)
+	assert.Check(t, cmp.Equal(opts.rootCAsPath, ""))
+}
+
+func Test_TLSOptionClientCertificate(t *testing.T) {
+	opts := tlsOptionsDefaults()
+
+	TLSOptionClientCertificateFiles("/cert.pem", "/key.pem")(opts)
+	assert.Check(t, cmp.Equal(opts.certificatePath, "/cert.pem"))
+	assert.Check(t, cmp.Equal(opts.certificateKeyPath, "/key.pem"))
+
+	TLSOptionClientCertificate(tls.Certificate{OCSPStaple: []byte("staple")})(opts)
+	assert.Assert(t, opts.certificate != nil)
+	assert.Check(t, cmp.Equal(string(opts.certificate.OCSPStaple), "staple"))
+	assert.Check(t, cmp.Equal(opts.certificatePath, ""), "last option wins")
+
+	TLSOptionClientCertificateFiles("/cert.pem", "/key.pem")(opts)
+	assert.Check(t, opts.certificate == nil, "last option wins")
+}
+
+func Test_TLSOptionRootCAs(t *testing.T) {
+	opts := tlsOptionsDefaults()
+
+	TLSOptionRootCAsFile("/ca.pem")(opts)
+	assert.Check(t, cmp.Equal(opts.rootCAsPath, "/ca.pem"))
+
+	pool := x509.NewCertPool()
+	TLSOptionRootCAs(pool)(opts)
+	assert.Check(t, opts.rootCAs == pool)
+	assert.Check(t, cmp.Equal(opts.rootCAsPath, ""), "last option wins")
+}
+
+func Test_TLSOptionMinVersion(t *testing.T) {
+	opts := tlsOptionsDefaults()
+	TLSOptionMinVersion(tls.VersionTLS13)(opts)
+	assert.Check(t, cmp.Equal(opts.minVersion, uint16(tls.VersionTLS13)))
+}
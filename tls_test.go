@@ -0,0 +1,331 @@
+package cassh
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/krostar/httpclient"
+)
+
+type tlsTestAuthority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	serial      int64
+}
+
+func newTLSTestAuthority(t *testing.T) *tlsTestAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cassh test authority"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NilError(t, err)
+	certificate, err := x509.ParseCertificate(raw)
+	assert.NilError(t, err)
+
+	return &tlsTestAuthority{certificate: certificate, key: key, serial: 1}
+}
+
+func (a *tlsTestAuthority) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.certificate.Raw})
+}
+
+func (a *tlsTestAuthority) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.certificate)
+	return pool
+}
+
+// issue returns the PEM encoded certificate and key of a new server or client certificate.
+func (a *tlsTestAuthority) issue(t *testing.T, commonName string, isServer bool) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+
+	a.serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(a.serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if isServer {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, a.certificate, key.Public(), a.key)
+	assert.NilError(t, err)
+	rawKey, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: rawKey})
+}
+
+// writeClientCertificate writes a new client certificate, making sure its files are seen as modified.
+func (a *tlsTestAuthority) writeClientCertificate(t *testing.T, commonName, certificatePath, keyPath string, modTime time.Time) {
+	certificate, key := a.issue(t, commonName, false)
+	assert.NilError(t, os.WriteFile(certificatePath, certificate, 0o600))
+	assert.NilError(t, os.WriteFile(keyPath, key, 0o600))
+	assert.NilError(t, os.Chtimes(certificatePath, modTime, modTime))
+	assert.NilError(t, os.Chtimes(keyPath, modTime, modTime))
+}
+
+// newTLSTestServer starts a server requiring client certificates signed by the authority,
+// and returns a function giving the common name of the last client certificate.
+func newTLSTestServer(t *testing.T, authority *tlsTestAuthority, setups ...func(*tls.Config)) (*httptest.Server, func() string) {
+	var (
+		m          sync.Mutex
+		commonName string
+	)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		commonName = r.TLS.PeerCertificates[0].Subject.CommonName
+		m.Unlock()
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	certificate, key := authority.issue(t, "cassh.local", true)
+	serverCertificate, err := tls.X509KeyPair(certificate, key)
+	assert.NilError(t, err)
+
+	srv.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{serverCertificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    authority.pool(),
+	}
+	for _, setup := range setups {
+		setup(srv.TLS)
+	}
+
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv, func() string {
+		m.Lock()
+		defer m.Unlock()
+		return commonName
+	}
+}
+
+func Test_Client_tls(t *testing.T) {
+	ctx := context.Background()
+
+	authority := newTLSTestAuthority(t)
+	srv, lastCommonName := newTLSTestServer(t, authority)
+
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	certificatePath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client.key")
+	assert.NilError(t, os.WriteFile(caPath, authority.pem(), 0o600))
+	authority.writeClientCertificate(t, "client-1", certificatePath, keyPath, time.Now().Add(-time.Minute))
+
+	// connections are not reused to make a new handshake on each request
+	newHTTPClient := func() *http.Client {
+		return &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	}
+
+	t.Run("client certificate files are reloaded", func(t *testing.T) {
+		client, err := NewClient(srv.URL,
+			ClientOptionHTTPClient(newHTTPClient()),
+			ClientOptionTLS(TLSOptionClientCertificateFiles(certificatePath, keyPath), TLSOptionRootCAsFile(caPath)),
+		)
+		assert.NilError(t, err)
+
+		assert.NilError(t, client.Ping(ctx))
+		assert.Check(t, cmp.Equal(lastCommonName(), "client-1"))
+
+		authority.writeClientCertificate(t, "client-2", certificatePath, keyPath, time.Now())
+		assert.NilError(t, client.Ping(ctx))
+		assert.Check(t, cmp.Equal(lastCommonName(), "client-2"))
+
+		assert.NilError(t, os.WriteFile(certificatePath, []byte("being replaced"), 0o600))
+		assert.NilError(t, client.Ping(ctx))
+		assert.Check(t, cmp.Equal(lastCommonName(), "client-2"), "previous certificate must be used until the new one is valid")
+
+		authority.writeClientCertificate(t, "client-3", certificatePath, keyPath, time.Now().Add(time.Minute))
+		assert.NilError(t, client.Ping(ctx))
+		assert.Check(t, cmp.Equal(lastCommonName(), "client-3"))
+	})
+
+	t.Run("client certificate", func(t *testing.T) {
+		certificate, key := authority.issue(t, "client-static", false)
+		keyPair, err := tls.X509KeyPair(certificate, key)
+		assert.NilError(t, err)
+
+		client, err := NewClient(srv.URL,
+			ClientOptionHTTPClient(newHTTPClient()),
+			ClientOptionTLS(TLSOptionClientCertificate(keyPair), TLSOptionRootCAs(authority.pool())),
+		)
+		assert.NilError(t, err)
+
+		assert.NilError(t, client.Ping(ctx))
+		assert.Check(t, cmp.Equal(lastCommonName(), "client-static"))
+	})
+
+	t.Run("redacted doer", func(t *testing.T) {
+		certificate, key := authority.issue(t, "client-redacted", false)
+		keyPair, err := tls.X509KeyPair(certificate, key)
+		assert.NilError(t, err)
+
+		inspector := new(tlsTestInspector)
+		doer := DoerWrapRedacted(newHTTPClient(), func(doer httpclient.Doer) httpclient.Doer {
+			inspector.doer = doer
+			return inspector
+		})
+
+		client, err := NewClient(srv.URL,
+			ClientOptionHTTPClient(doer),
+			ClientOptionTLS(TLSOptionClientCertificate(keyPair), TLSOptionRootCAs(authority.pool())),
+		)
+		assert.NilError(t, err)
+
+		assert.NilError(t, client.Ping(ctx))
+		assert.Check(t, cmp.Equal(lastCommonName(), "client-redacted"))
+		assert.Check(t, cmp.Equal(inspector.requests, 1), "requests must still be inspected")
+	})
+
+	t.Run("ko without client certificate", func(t *testing.T) {
+		client, err := NewClient(srv.URL, ClientOptionHTTPClient(newHTTPClient()), ClientOptionTLS(TLSOptionRootCAs(authority.pool())))
+		assert.NilError(t, err)
+		assert.Check(t, client.Ping(ctx) != nil)
+	})
+
+	t.Run("ko untrusted server", func(t *testing.T) {
+		client, err := NewClient(srv.URL, ClientOptionHTTPClient(newHTTPClient()), ClientOptionTLS(TLSOptionClientCertificateFiles(certificatePath, keyPath)))
+		assert.NilError(t, err)
+		assert.Check(t, cmp.ErrorContains(client.Ping(ctx), "certificate signed by unknown authority"))
+	})
+
+	t.Run("ko min version", func(t *testing.T) {
+		srv, _ := newTLSTestServer(t, authority, func(cfg *tls.Config) { cfg.MaxVersion = tls.VersionTLS12 })
+
+		client, err := NewClient(srv.URL,
+			ClientOptionHTTPClient(newHTTPClient()),
+			ClientOptionTLS(TLSOptionClientCertificateFiles(certificatePath, keyPath), TLSOptionRootCAsFile(caPath), TLSOptionMinVersion(tls.VersionTLS13)),
+		)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.ErrorContains(client.Ping(ctx), "protocol version"))
+	})
+}
+
+// tlsTestInspector counts the requests it sees.
+type tlsTestInspector struct {
+	doer     httpclient.Doer
+	requests int
+}
+
+func (i *tlsTestInspector) Do(req *http.Request) (*http.Response, error) {
+	i.requests++
+	return i.doer.Do(req)
+}
+
+func Test_withTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("keeps the http client configuration", func(t *testing.T) {
+		original := &http.Client{
+			Timeout:   time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{ServerName: "cassh.local", MinVersion: tls.VersionTLS12}},
+		}
+
+		doer, err := withTLS(original, &tlsOptions{minVersion: tls.VersionTLS13})
+		assert.NilError(t, err)
+
+		client := doer.(*http.Client)
+		assert.Check(t, client != original)
+		assert.Check(t, cmp.Equal(client.Timeout, time.Second))
+
+		tlsConfig := client.Transport.(*http.Transport).TLSClientConfig
+		assert.Check(t, cmp.Equal(tlsConfig.ServerName, "cassh.local"))
+		assert.Check(t, cmp.Equal(tlsConfig.MinVersion, uint16(tls.VersionTLS13)))
+		assert.Check(t, cmp.Equal(original.Transport.(*http.Transport).TLSClientConfig.MinVersion, uint16(tls.VersionTLS12)), "original must not be modified")
+	})
+
+	t.Run("keeps a higher min version", func(t *testing.T) {
+		original := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS13}}}
+
+		doer, err := withTLS(original, tlsOptionsDefaults())
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(doer.(*http.Client).Transport.(*http.Transport).TLSClientConfig.MinVersion, uint16(tls.VersionTLS13)))
+	})
+
+	t.Run("default client", func(t *testing.T) {
+		doer, err := withTLS(http.DefaultClient, tlsOptionsDefaults())
+		assert.NilError(t, err)
+		assert.Check(t, doer != http.DefaultClient)
+		assert.Check(t, http.DefaultClient.Transport == nil, "default client must not be modified")
+	})
+
+	for name, test := range map[string]struct {
+		doer httpclient.Doer
+		opts *tlsOptions
+		err  string
+	}{
+		"ko doer": {
+			doer: new(redactionTestDoer),
+			opts: tlsOptionsDefaults(),
+			err:  "unable to configure tls of *cassh.redactionTestDoer, only *http.Client can be configured",
+		},
+		"ko transport": {
+			doer: &http.Client{Transport: http.NewFileTransport(http.Dir(dir))},
+			opts: tlsOptionsDefaults(),
+			err:  "only *http.Transport can be configured",
+		},
+		"ko client certificate": {
+			doer: http.DefaultClient,
+			opts: &tlsOptions{certificatePath: filepath.Join(dir, "nope.pem"), certificateKeyPath: filepath.Join(dir, "nope.key")},
+			err:  "unable to load client certificate",
+		},
+		"ko root CAs file": {
+			doer: http.DefaultClient,
+			opts: &tlsOptions{rootCAsPath: filepath.Join(dir, "nope.pem")},
+			err:  "unable to read root certificate authorities",
+		},
+		"ko root CAs content": {
+			doer: http.DefaultClient,
+			opts: &tlsOptions{rootCAsPath: "tls_test.go"},
+			err:  "no certificate found in root certificate authorities file tls_test.go",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewClient("https://cassh.local", ClientOptionHTTPClient(test.doer), func(o *clientOptions) { o.tls = test.opts })
+			assert.Check(t, cmp.ErrorContains(err, test.err))
+		})
+	}
+}